@@ -0,0 +1,281 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aaronriekenberg/go-api/config"
+	"github.com/aaronriekenberg/go-api/handlers/command"
+	"github.com/aaronriekenberg/go-api/request"
+	"github.com/aaronriekenberg/go-api/version"
+)
+
+const (
+	exitCodeSuccess = 0
+	exitCodeFailure = 1
+	exitCodeUsage   = 2
+)
+
+// errUsage is returned for a bad command line after the usage has been printed.
+var errUsage = errors.New("usage error")
+
+type subcommand struct {
+	name        string
+	arguments   string
+	description string
+	run         func(args []string) error
+}
+
+func subcommands() []subcommand {
+	return []subcommand{
+		{
+			name:        "serve",
+			arguments:   "[flags] <config>",
+			description: "run the server",
+			run:         serveCommand,
+		},
+		{
+			name:        "validate",
+			arguments:   "<config>",
+			description: "check a config file and report every problem",
+			run:         validateCommand,
+		},
+		{
+			name:        "print-config",
+			arguments:   "<config>",
+			description: "print the merged configuration and the source of each value",
+			run:         printConfigCommand,
+		},
+		{
+			name:        "print-default-config",
+			description: "print a starting configuration",
+			run:         printDefaultConfigCommand,
+		},
+		{
+			name:        "version",
+			description: "print build information",
+			run:         versionCommand,
+		},
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: go-api <command> [flags] [arguments]\n\ncommands:\n")
+
+	tabWriter := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	for _, subcommand := range subcommands() {
+		fmt.Fprintf(tabWriter, "  %s %s\t%s\n", subcommand.name, subcommand.arguments, subcommand.description)
+	}
+	tabWriter.Flush()
+
+	fmt.Fprintf(w, "\nRun go-api <command> -h for the flags of a command.\n")
+}
+
+// run runs the subcommand named by args[0] and returns the exit code.
+func run(args []string) int {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return exitCodeUsage
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage(os.Stdout)
+		return exitCodeSuccess
+
+	case "-version", "--version":
+		args = []string{"version"}
+	}
+
+	index := slices.IndexFunc(subcommands(), func(subcommand subcommand) bool {
+		return subcommand.name == args[0]
+	})
+	if index < 0 {
+		fmt.Fprintf(os.Stderr, "go-api: unknown command %q\n\n", args[0])
+		usage(os.Stderr)
+		return exitCodeUsage
+	}
+
+	subcommand := subcommands()[index]
+
+	err := subcommand.run(args[1:])
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return exitCodeSuccess
+
+	case errors.Is(err, errUsage):
+		return exitCodeUsage
+
+	default:
+		fmt.Fprintf(os.Stderr, "go-api %s: %v\n", subcommand.name, err)
+		return exitCodeFailure
+	}
+}
+
+func newFlagSet(
+	name string,
+	arguments string,
+) *flag.FlagSet {
+	flagSet := flag.NewFlagSet(name, flag.ContinueOnError)
+
+	flagSet.Usage = func() {
+		fmt.Fprintf(flagSet.Output(), "usage: %s\n", strings.TrimSpace("go-api "+name+" "+arguments))
+		flagSet.PrintDefaults()
+	}
+
+	return flagSet
+}
+
+// parseArgs parses args and checks there are numArgs arguments after the flags.
+func parseArgs(
+	flagSet *flag.FlagSet,
+	args []string,
+	numArgs int,
+) error {
+	if err := flagSet.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+
+	if flagSet.NArg() != numArgs {
+		fmt.Fprintf(flagSet.Output(), "expected %d argument(s), got %d\n", numArgs, flagSet.NArg())
+		flagSet.Usage()
+		return errUsage
+	}
+
+	return nil
+}
+
+type logFormat string
+
+const (
+	logFormatJSON logFormat = "json"
+	logFormatText logFormat = "text"
+)
+
+func serveCommand(args []string) error {
+	flagSet := newFlagSet("serve", "[flags] <config>")
+
+	logLevel := slog.LevelInfo
+	flagSet.TextVar(&logLevel, "log-level", logLevel, "log `level`: debug, info, warn or error")
+
+	format := logFormatJSON
+	flagSet.Func("log-format", "log `format`: json or text (default json)", func(s string) error {
+		switch s {
+		case string(logFormatJSON), string(logFormatText):
+			format = logFormat(s)
+			return nil
+		default:
+			return fmt.Errorf("must be json or text")
+		}
+	})
+
+	if err := parseArgs(flagSet, args, 1); err != nil {
+		return err
+	}
+
+	return serve(flagSet.Arg(0), logLevel, format)
+}
+
+func validateCommand(args []string) error {
+	flagSet := newFlagSet("validate", "<config>")
+	if err := parseArgs(flagSet, args, 1); err != nil {
+		return err
+	}
+
+	configFile := flagSet.Arg(0)
+
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+
+	layeredConfiguration, diagnostics := config.ValidateLayeredConfiguration(configFile, os.Environ())
+
+	// creating the command handlers checks the merged configuration further,
+	// including command parameters, parsers, schedules and composite commands
+	if layeredConfiguration != nil && len(diagnostics) == 0 {
+		err := validateCommands(layeredConfiguration.Configuration)
+		if err != nil {
+			diagnostics = append(diagnostics, config.Diagnostic{
+				File:    configFile,
+				Message: err.Error(),
+			})
+		}
+	}
+
+	for _, diagnostic := range diagnostics {
+		fmt.Fprintln(os.Stderr, diagnostic)
+	}
+
+	if len(diagnostics) > 0 {
+		return fmt.Errorf("%s: %d problem(s) found", configFile, len(diagnostics))
+	}
+
+	fmt.Printf("%s: OK\n", configFile)
+	return nil
+}
+
+func validateCommands(configuration *config.Configuration) error {
+	_, err := command.NewCommands(
+		configuration.CommandConfiguration,
+		request.NewExternalCheck(configuration.RequestConfiguration),
+		nil,
+	)
+	return err
+}
+
+func printConfigCommand(args []string) error {
+	flagSet := newFlagSet("print-config", "<config>")
+	if err := parseArgs(flagSet, args, 1); err != nil {
+		return err
+	}
+
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+
+	layeredConfiguration, err := config.ReadLayeredConfiguration(flagSet.Arg(0), os.Environ())
+	if err != nil {
+		return err
+	}
+
+	for _, file := range layeredConfiguration.Files {
+		fmt.Printf("# file %s sha256 %s\n", file.Path, file.SHA256)
+	}
+
+	for _, value := range layeredConfiguration.Values {
+		fmt.Printf("%s = %s # %s\n", value.Path, value.Value, value.Source)
+	}
+
+	return nil
+}
+
+func printDefaultConfigCommand(args []string) error {
+	flagSet := newFlagSet("print-default-config", "")
+	if err := parseArgs(flagSet, args, 0); err != nil {
+		return err
+	}
+
+	fmt.Print(config.DefaultConfigurationTOML)
+	return nil
+}
+
+func versionCommand(args []string) error {
+	flagSet := newFlagSet("version", "")
+	if err := parseArgs(flagSet, args, 0); err != nil {
+		return err
+	}
+
+	buildInfoMap := version.BuildInfoMap()
+	for _, key := range slices.Sorted(maps.Keys(buildInfoMap)) {
+		fmt.Printf("%s=%s\n", key, buildInfoMap[key])
+	}
+
+	return nil
+}
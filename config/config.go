@@ -4,37 +4,71 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
-	"sync"
-
-	"github.com/BurntSushi/toml"
+	"sync/atomic"
 )
 
-var Instance = sync.OnceValue(readConfiguration)
+var (
+	configFile string
+	instance   atomic.Pointer[LayeredConfiguration]
+)
 
-func readConfiguration() *Configuration {
+// Load reads and validates the config file and makes it the configuration returned by Instance.
+// ReadConfiguration reads the same file again when the configuration is reloaded.
+func Load(file string) error {
+	configFile = file
 
-	if len(os.Args) != 2 {
-		panic("config file required as command line arument")
+	layeredConfiguration, err := ReadConfiguration()
+	if err != nil {
+		return err
 	}
 
-	configFile := os.Args[1]
+	instance.Store(layeredConfiguration)
+	return nil
+}
+
+// Instance is the current configuration, set by Load and replaced by SetInstance when
+// the configuration is reloaded.
+func Instance() *Configuration {
+	return instance.Load().Configuration
+}
+
+// LayeredInstance is the current configuration with the files it was read from.
+func LayeredInstance() *LayeredConfiguration {
+	return instance.Load()
+}
+
+func SetInstance(layeredConfiguration *LayeredConfiguration) {
+	instance.Store(layeredConfiguration)
+}
+
+// ReadConfiguration reads and validates the config file and the layers above it, it does not change Instance.
+// The error is a *ValidationError if the configuration has problems.  A command not found in PATH is not one,
+// only its runs fail, so the validate command reports it.
+func ReadConfiguration() (*LayeredConfiguration, error) {
 
 	logger := slog.Default().With("configFile", configFile)
 
 	logger.Info("begin readConfiguration")
 
-	var configuration Configuration
-	_, err := toml.DecodeFile(configFile, &configuration)
+	layeredConfiguration, diagnostics := validateLayeredConfiguration(configFile, os.Environ(), false)
+	if len(diagnostics) > 0 {
+		for _, diagnostic := range diagnostics {
+			logger.Error("configuration problem",
+				"diagnostic", diagnostic.String(),
+			)
+		}
+		return nil, &ValidationError{Diagnostics: diagnostics}
+	}
+
+	redactedConfiguration, err := MarshalRedacted(layeredConfiguration.Configuration)
 	if err != nil {
-		logger.Error("toml.DecodeFile error",
-			"error", err,
-		)
-		panic(fmt.Errorf("readConfiguration error: %w", err))
+		return nil, fmt.Errorf("MarshalRedacted error: %w", err)
 	}
 
 	logger.Info("end readConfiguration",
-		"configuration", &configuration,
+		"files", layeredConfiguration.Files,
+		"configuration", redactedConfiguration,
 	)
 
-	return &configuration
+	return layeredConfiguration, nil
 }
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLoadRejectsInvalidConfiguration(t *testing.T) {
+	configFile := writeConfig(t, strings.Replace(validConfig, `maxConcurrentCommands = 10`, `maxConcurrentCommands = 0`, 1))
+
+	err := Load(configFile)
+
+	var validationError *ValidationError
+	if !errors.As(err, &validationError) {
+		t.Fatalf("Load error = %v, want *ValidationError", err)
+	}
+
+	if len(validationError.Diagnostics) != 1 || validationError.Diagnostics[0].Path != "CommandConfiguration.MaxConcurrentCommands" {
+		t.Fatalf("Diagnostics = %v, want one for CommandConfiguration.MaxConcurrentCommands", validationError.Diagnostics)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	// a missing command only fails its runs
+	configFile := writeConfig(t, strings.Replace(validConfig, `command = "true"`, `command = "/nonexistent/true"`, 1))
+
+	if err := Load(configFile); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+
+	if got := Instance().ServerConfiguration.APIContext; got != "/api/v1" {
+		t.Fatalf("APIContext = %q, want /api/v1", got)
+	}
+}
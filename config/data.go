@@ -6,15 +6,139 @@ import (
 	"time"
 )
 
+// TLSConfiguration serves a listener over TLS.  The certificate is reloaded when CertFile or KeyFile change.
+type TLSConfiguration struct {
+	CertFile string
+	KeyFile  string
+	// MinVersion is "1.2" or "1.3", default "1.2".
+	MinVersion string
+	// CipherSuites are crypto/tls names of TLS 1.2 cipher suites, default Go's.
+	// TLS 1.3 cipher suites are not configurable.
+	CipherSuites []string
+	// ALPNProtocols in order of preference, default "h2" then "http/1.1".
+	// HTTP/2 is only served if "h2" is included.
+	ALPNProtocols []string
+}
+
+// NetworkSystemd is the Network of a listener on a socket passed by systemd socket activation,
+// its ListenAddress is the socket's FileDescriptorName.
+const NetworkSystemd = "systemd"
+
+// HTTP2Configuration sets the http.HTTP2Config fields of the same names.
+// Zero values use Go's defaults.
+type HTTP2Configuration struct {
+	MaxConcurrentStreams          int
+	MaxDecoderHeaderTableSize     int
+	MaxEncoderHeaderTableSize     int
+	MaxReadFrameSize              int
+	MaxReceiveBufferPerConnection int
+	MaxReceiveBufferPerStream     int
+	// SendPingTimeoutDuration enables pinging connections that received no frame for this long.
+	SendPingTimeoutDuration  time.Duration
+	PingTimeoutDuration      time.Duration
+	WriteByteTimeoutDuration time.Duration
+}
+
+func (h *HTTP2Configuration) MarshalJSONTo(enc *jsontext.Encoder) error {
+	type Alias HTTP2Configuration
+	return json.MarshalEncode(enc, &struct {
+		SendPingTimeoutDuration  string
+		PingTimeoutDuration      string
+		WriteByteTimeoutDuration string
+		*Alias
+	}{
+		SendPingTimeoutDuration:  h.SendPingTimeoutDuration.String(),
+		PingTimeoutDuration:      h.PingTimeoutDuration.String(),
+		WriteByteTimeoutDuration: h.WriteByteTimeoutDuration.String(),
+		Alias:                    (*Alias)(h),
+	})
+}
+
+// ServerLimitsConfiguration sets the http.Server limits of a listener.
+// Zero values use the defaults in WithDefaults.
+type ServerLimitsConfiguration struct {
+	ReadTimeoutDuration       time.Duration
+	ReadHeaderTimeoutDuration time.Duration
+	WriteTimeoutDuration      time.Duration
+	IdleTimeoutDuration       time.Duration
+	MaxHeaderBytes            int
+	HTTP2                     HTTP2Configuration
+}
+
+func (l *ServerLimitsConfiguration) MarshalJSONTo(enc *jsontext.Encoder) error {
+	type Alias ServerLimitsConfiguration
+	return json.MarshalEncode(enc, &struct {
+		ReadTimeoutDuration       string
+		ReadHeaderTimeoutDuration string
+		WriteTimeoutDuration      string
+		IdleTimeoutDuration       string
+		*Alias
+	}{
+		ReadTimeoutDuration:       l.ReadTimeoutDuration.String(),
+		ReadHeaderTimeoutDuration: l.ReadHeaderTimeoutDuration.String(),
+		WriteTimeoutDuration:      l.WriteTimeoutDuration.String(),
+		IdleTimeoutDuration:       l.IdleTimeoutDuration.String(),
+		Alias:                     (*Alias)(l),
+	})
+}
+
 type ServerListenerConfiguration struct {
+	// Network is "tcp", "tcp4", "tcp6", "unix" or NetworkSystemd.
 	Network       string
 	ListenAddress string
 	H2CEnabled    bool
+	TLS           *TLSConfiguration
+	Limits        ServerLimitsConfiguration
 }
 
+// Defaults for the ServerConfiguration shutdown timeouts that are not set.
+const (
+	DefaultShutdownTimeout        = 30 * time.Second
+	DefaultCommandShutdownTimeout = 10 * time.Second
+)
+
 type ServerConfiguration struct {
 	Listeners  []ServerListenerConfiguration
 	APIContext string
+	// On SIGTERM or SIGINT /health reports draining for DrainDelayDuration before the listeners
+	// are closed, so load balancers stop sending requests.  Default no delay.
+	DrainDelayDuration time.Duration
+	// ShutdownTimeoutDuration then bounds the wait for active requests.  Default DefaultShutdownTimeout.
+	ShutdownTimeoutDuration time.Duration
+	// CommandShutdownTimeoutDuration then bounds the wait for running commands,
+	// commands still running after it are killed.  Default DefaultCommandShutdownTimeout.
+	CommandShutdownTimeoutDuration time.Duration
+}
+
+func (s *ServerConfiguration) MarshalJSONTo(enc *jsontext.Encoder) error {
+	type Alias ServerConfiguration
+	return json.MarshalEncode(enc, &struct {
+		DrainDelayDuration             string
+		ShutdownTimeoutDuration        string
+		CommandShutdownTimeoutDuration string
+		*Alias
+	}{
+		DrainDelayDuration:             s.DrainDelayDuration.String(),
+		ShutdownTimeoutDuration:        s.ShutdownTimeoutDuration.String(),
+		CommandShutdownTimeoutDuration: s.CommandShutdownTimeoutDuration.String(),
+		Alias:                          (*Alias)(s),
+	})
+}
+
+// ShutdownTimeout is ShutdownTimeoutDuration or DefaultShutdownTimeout if it is not set.
+func (s *ServerConfiguration) ShutdownTimeout() time.Duration {
+	if s.ShutdownTimeoutDuration == 0 {
+		return DefaultShutdownTimeout
+	}
+	return s.ShutdownTimeoutDuration
+}
+
+// CommandShutdownTimeout is CommandShutdownTimeoutDuration or DefaultCommandShutdownTimeout if it is not set.
+func (s *ServerConfiguration) CommandShutdownTimeout() time.Duration {
+	if s.CommandShutdownTimeoutDuration == 0 {
+		return DefaultCommandShutdownTimeout
+	}
+	return s.CommandShutdownTimeoutDuration
 }
 
 type RequestConfiguration struct {
@@ -33,19 +157,186 @@ type RequestLoggingConfiguration struct {
 	MaxBackups       int
 }
 
+// ExecutionConfiguration controls the environment and limits of command processes.
+// Zero values mean "not set": the process inherits from the server.
+type ExecutionConfiguration struct {
+	// EnvAllowlist names server environment variables passed to commands.  Empty passes all.
+	EnvAllowlist []string
+	// EnvOverrides may hold credentials, its values are redacted when the configuration is shown.
+	EnvOverrides            map[string]string `secret:"true"`
+	WorkingDirectory        string
+	UID                     *uint32
+	GID                     *uint32
+	NiceLevel               int
+	RlimitCPUSeconds        uint64
+	RlimitAddressSpaceBytes uint64
+	RlimitOpenFiles         uint64
+	// KillGracePeriodDuration is the time between SIGTERM and SIGKILL to the
+	// command's process group on timeout.
+	KillGracePeriodDuration time.Duration
+}
+
+func (e *ExecutionConfiguration) MarshalJSONTo(enc *jsontext.Encoder) error {
+	type Alias ExecutionConfiguration
+	return json.MarshalEncode(enc, &struct {
+		KillGracePeriodDuration string
+		*Alias
+	}{
+		KillGracePeriodDuration: e.KillGracePeriodDuration.String(),
+		Alias:                   (*Alias)(e),
+	})
+}
+
+// CommandParameter is a named, validated value substituted for "{Name}" in CommandInfo.Args.
+// It needs at least one of Enum, Regex, MinValue and MaxValue.  A parameter with no Default is required.
+// Every "{name}" in CommandInfo.Args must be a parameter.
+type CommandParameter struct {
+	Name        string
+	Description string
+	Enum        []string
+	Regex       string
+	MinValue    *int64
+	MaxValue    *int64
+	Default     *string
+}
+
+// ScheduleConfiguration runs a command in the background either every IntervalDuration
+// or at the times matched by Cron, a standard 5 field cron expression in local time.
+// The last HistorySize results are kept.
+type ScheduleConfiguration struct {
+	IntervalDuration time.Duration
+	Cron             string
+	HistorySize      int
+}
+
+func (s *ScheduleConfiguration) MarshalJSONTo(enc *jsontext.Encoder) error {
+	type Alias ScheduleConfiguration
+	return json.MarshalEncode(enc, &struct {
+		IntervalDuration string
+		*Alias
+	}{
+		IntervalDuration: s.IntervalDuration.String(),
+		Alias:            (*Alias)(s),
+	})
+}
+
+// ParserConfiguration turns a command's stdout into parsed_output.
+// Either Builtin names a built-in parser, or Type is one of:
+//   - "table": whitespace separated columns named by a header line, after SkipLines lines.
+//     MaxColumns > 0 limits the columns, the last one taking the rest of the line.
+//   - "key_value": one "key<Separator>value" per line, Separator defaults to ":".
+//   - "regex": every match of Regex, named groups become fields.
+//   - "json": stdout is already JSON.
+type ParserConfiguration struct {
+	Builtin    string
+	Type       string
+	SkipLines  int
+	MaxColumns int
+	Separator  string
+	Regex      string
+}
+
+// ReservedCommandIDs are the paths under /commands that are not commands, so no command can use them as its ID.
+var ReservedCommandIDs = []string{"limits", "stats", "tags"}
+
 type CommandInfo struct {
 	ID           string
 	InternalOnly bool
 	Description  string
+	Tags         []string
 	Command      string
 	Args         []string
+	Parameters   []CommandParameter
+	CacheTTL     time.Duration
+	Schedule     *ScheduleConfiguration
+	Parser       *ParserConfiguration
+
+	// Optional overrides of the CommandConfiguration values, layered under the global limits.
+	MaxConcurrentCommands           int64
+	RequestTimeoutDuration          time.Duration
+	SemaphoreAcquireTimeoutDuration time.Duration
+	MaxOutputBytes                  int64
+	ExecutionConfiguration          *ExecutionConfiguration
+}
+
+func (c *CommandInfo) MarshalJSONTo(enc *jsontext.Encoder) error {
+	type Alias CommandInfo
+	return json.MarshalEncode(enc, &struct {
+		CacheTTL                        string
+		RequestTimeoutDuration          string
+		SemaphoreAcquireTimeoutDuration string
+		*Alias
+	}{
+		CacheTTL:                        c.CacheTTL.String(),
+		RequestTimeoutDuration:          c.RequestTimeoutDuration.String(),
+		SemaphoreAcquireTimeoutDuration: c.SemaphoreAcquireTimeoutDuration.String(),
+		Alias:                           (*Alias)(c),
+	})
 }
 
+// CompositeCommandInfo runs the commands named by CommandIDs as one request,
+// one after another or all at once if Parallel.
+type CompositeCommandInfo struct {
+	ID           string
+	InternalOnly bool
+	Description  string
+	CommandIDs   []string
+	Parallel     bool
+}
+
+// JobConfiguration limits asynchronous command jobs.
+// Zero values use the defaults in WithDefaults.
+type JobConfiguration struct {
+	MaxJobs            int
+	JobTimeoutDuration time.Duration
+	JobTTLDuration     time.Duration
+}
+
+func (j *JobConfiguration) MarshalJSONTo(enc *jsontext.Encoder) error {
+	type Alias JobConfiguration
+	return json.MarshalEncode(enc, &struct {
+		JobTimeoutDuration string
+		JobTTLDuration     string
+		*Alias
+	}{
+		JobTimeoutDuration: j.JobTimeoutDuration.String(),
+		JobTTLDuration:     j.JobTTLDuration.String(),
+		Alias:              (*Alias)(j),
+	})
+}
+
+// AdmissionConfiguration divides the MaxConcurrentCommands global slots between
+// internal and external requests.  Zero values mean "not set".
+type AdmissionConfiguration struct {
+	// ReservedInternalCommands slots are only used by internal requests.
+	ReservedInternalCommands int64
+	// InternalWeight and ExternalWeight share freed slots between classes with waiting requests.
+	// Both default to 1.
+	InternalWeight int
+	ExternalWeight int
+	// MaxInternalQueueLength and MaxExternalQueueLength bound the waiting requests of each class,
+	// further requests are rejected at once.  Zero is unbounded.
+	MaxInternalQueueLength int
+	MaxExternalQueueLength int
+}
+
+// DefaultMaxOutputBytes is used when CommandConfiguration.MaxOutputBytes is not set.
+const DefaultMaxOutputBytes = 1 << 20
+
 type CommandConfiguration struct {
 	MaxConcurrentCommands           int64
 	RequestTimeoutDuration          time.Duration
 	SemaphoreAcquireTimeoutDuration time.Duration
-	Commands                        []CommandInfo
+	// MaxOutputBytes bounds the stdout and stderr kept of each command.  Default DefaultMaxOutputBytes.
+	MaxOutputBytes         int64
+	AdmissionConfiguration AdmissionConfiguration
+	ExecutionConfiguration ExecutionConfiguration
+	JobConfiguration       JobConfiguration
+	// ErrorKindHTTPStatusCodes maps command error kinds (timeout, non_zero_exit,
+	// exec_failure, semaphore_rejection) to the HTTP status returned for them.
+	ErrorKindHTTPStatusCodes map[string]int
+	Commands                 []CommandInfo
+	CompositeCommands        []CompositeCommandInfo
 }
 
 // Idea from https://choly.ca/post/go-json-marshalling/
@@ -63,6 +354,14 @@ func (c *CommandConfiguration) MarshalJSONTo(enc *jsontext.Encoder) error {
 	})
 }
 
+// MaxOutput is MaxOutputBytes or DefaultMaxOutputBytes if it is not set.
+func (c *CommandConfiguration) MaxOutput() int64 {
+	if c.MaxOutputBytes == 0 {
+		return DefaultMaxOutputBytes
+	}
+	return c.MaxOutputBytes
+}
+
 type Configuration struct {
 	ServerConfiguration         ServerConfiguration
 	RequestConfiguration        RequestConfiguration
@@ -0,0 +1,10 @@
+package config
+
+import (
+	_ "embed"
+)
+
+// DefaultConfigurationTOML is a starting configuration, printed by go-api print-default-config.
+//
+//go:embed default-config.toml
+var DefaultConfigurationTOML string
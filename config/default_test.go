@@ -0,0 +1,13 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestDefaultConfigurationIsValid(t *testing.T) {
+	configuration, diagnostics := ValidateLayeredConfiguration(writeConfig(t, DefaultConfigurationTOML), nil)
+
+	if configuration == nil || len(diagnostics) != 0 {
+		t.Fatalf("diagnostics = %v, want none", diagnostics)
+	}
+}
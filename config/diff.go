@@ -0,0 +1,142 @@
+package config
+
+import (
+	"encoding/json/v2"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Change is one value that differs between two configurations.
+// Old and New are JSON, empty if the value was added or removed, and secret values are redacted.
+type Change struct {
+	Path            string `json:"path"`
+	Old             string `json:"old,omitempty"`
+	New             string `json:"new,omitempty"`
+	RequiresRestart bool   `json:"requires_restart,omitzero"`
+}
+
+// restartRequiredPaths are the configuration sections only read when the server starts.
+var restartRequiredPaths = []string{
+	"ServerConfiguration.Listeners",
+	"ProfilingConfiguration",
+}
+
+// flattenJSON adds the leaf values of value to pathToValue keyed by their path.
+// Elements of arrays are keyed by their ID field if they have one, else by their index.
+func flattenJSON(
+	path string,
+	value any,
+	pathToValue map[string]string,
+) {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, element := range value {
+			if path == "" {
+				flattenJSON(key, element, pathToValue)
+			} else {
+				flattenJSON(path+"."+key, element, pathToValue)
+			}
+		}
+
+	case []any:
+		for i, element := range value {
+			key := strconv.Itoa(i)
+			if object, ok := element.(map[string]any); ok {
+				if id, ok := object["ID"].(string); ok {
+					key = id
+				}
+			}
+			flattenJSON(path+"["+key+"]", element, pathToValue)
+		}
+
+	default:
+		valueJSON, _ := json.Marshal(value)
+		pathToValue[path] = string(valueJSON)
+	}
+}
+
+func flattenConfigurationJSON(configurationJSON []byte) (map[string]string, error) {
+	var value any
+	err := json.Unmarshal(configurationJSON, &value)
+	if err != nil {
+		return nil, err
+	}
+
+	pathToValue := make(map[string]string)
+	flattenJSON("", value, pathToValue)
+	return pathToValue, nil
+}
+
+func flattenConfiguration(configuration *Configuration) (map[string]string, error) {
+	configurationJSON, err := json.Marshal(configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	return flattenConfigurationJSON(configurationJSON)
+}
+
+// flattenRedactedConfiguration is flattenConfiguration with secret values redacted, for display.
+func flattenRedactedConfiguration(configuration *Configuration) (map[string]string, error) {
+	configurationJSON, err := MarshalRedacted(configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	return flattenConfigurationJSON(configurationJSON)
+}
+
+// Diff lists the values that differ between oldConfiguration and newConfiguration, sorted by path.
+func Diff(
+	oldConfiguration *Configuration,
+	newConfiguration *Configuration,
+) ([]Change, error) {
+	oldPathToValue, err := flattenConfiguration(oldConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	newPathToValue, err := flattenConfiguration(newConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	oldPathToRedactedValue, err := flattenRedactedConfiguration(oldConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	newPathToRedactedValue, err := flattenRedactedConfiguration(newConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := slices.Sorted(maps.Keys(oldPathToValue))
+	for path := range newPathToValue {
+		if _, ok := oldPathToValue[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+
+	var changes []Change
+	for _, path := range paths {
+		oldValue, newValue := oldPathToValue[path], newPathToValue[path]
+		if oldValue == newValue {
+			continue
+		}
+
+		changes = append(changes, Change{
+			Path: path,
+			Old:  oldPathToRedactedValue[path],
+			New:  newPathToRedactedValue[path],
+			RequiresRestart: slices.ContainsFunc(restartRequiredPaths, func(restartRequiredPath string) bool {
+				return strings.HasPrefix(path, restartRequiredPath)
+			}),
+		})
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	oldConfiguration := &Configuration{
+		ServerConfiguration: ServerConfiguration{
+			Listeners:  []ServerListenerConfiguration{{Network: "tcp", ListenAddress: ":8080"}},
+			APIContext: "/api/v1",
+		},
+		RequestConfiguration: RequestConfiguration{ExternalHost: "aaronr.digital"},
+		CommandConfiguration: CommandConfiguration{
+			RequestTimeoutDuration: 2 * time.Second,
+			Commands: []CommandInfo{
+				{ID: "df", Command: "/usr/bin/df"},
+				{ID: "uptime", Command: "/usr/bin/uptime"},
+			},
+		},
+	}
+
+	newConfiguration := &Configuration{
+		ServerConfiguration: ServerConfiguration{
+			Listeners:  []ServerListenerConfiguration{{Network: "tcp", ListenAddress: ":8081"}},
+			APIContext: "/api/v1",
+		},
+		RequestConfiguration: RequestConfiguration{ExternalHost: "example.com"},
+		CommandConfiguration: CommandConfiguration{
+			RequestTimeoutDuration: 2 * time.Second,
+			Commands: []CommandInfo{
+				{ID: "uptime", Command: "/usr/bin/uptime"},
+				{ID: "df", Command: "/bin/df"},
+			},
+		},
+	}
+
+	changes, err := Diff(oldConfiguration, newConfiguration)
+	if err != nil {
+		t.Fatalf("Diff error: %v", err)
+	}
+
+	want := []Change{
+		{Path: "CommandConfiguration.Commands[df].Command", Old: `"/usr/bin/df"`, New: `"/bin/df"`},
+		{Path: "RequestConfiguration.ExternalHost", Old: `"aaronr.digital"`, New: `"example.com"`},
+		{Path: "ServerConfiguration.Listeners[0].ListenAddress", Old: `":8080"`, New: `":8081"`, RequiresRestart: true},
+	}
+
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("got %+v want %+v", changes, want)
+	}
+
+	if changes, err := Diff(oldConfiguration, oldConfiguration); err != nil || len(changes) != 0 {
+		t.Fatalf("got %+v, %v want no changes", changes, err)
+	}
+}
+
+func TestDiffAddedAndRemoved(t *testing.T) {
+	oldConfiguration := &Configuration{
+		CommandConfiguration: CommandConfiguration{
+			Commands: []CommandInfo{{ID: "df", Command: "/usr/bin/df"}},
+		},
+	}
+
+	newConfiguration := &Configuration{
+		CommandConfiguration: CommandConfiguration{
+			Commands: []CommandInfo{{ID: "w", Command: "/usr/bin/w"}},
+		},
+	}
+
+	changes, err := Diff(oldConfiguration, newConfiguration)
+	if err != nil {
+		t.Fatalf("Diff error: %v", err)
+	}
+
+	pathToChange := make(map[string]Change)
+	for _, change := range changes {
+		pathToChange[change.Path] = change
+	}
+
+	if change := pathToChange["CommandConfiguration.Commands[df].Command"]; change.Old != `"/usr/bin/df"` || change.New != "" {
+		t.Errorf("got removed change %+v", change)
+	}
+
+	if change := pathToChange["CommandConfiguration.Commands[w].Command"]; change.Old != "" || change.New != `"/usr/bin/w"` {
+		t.Errorf("got added change %+v", change)
+	}
+}
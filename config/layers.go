@@ -0,0 +1,499 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// EnvironmentPrefix starts the names of environment variables overriding configuration values.
+// GOAPI_SERVERCONFIGURATION_APICONTEXT overrides ServerConfiguration.APIContext.
+const EnvironmentPrefix = "GOAPI_"
+
+// DefaultSource is the source of values not set by any layer.
+const DefaultSource = "default"
+
+// File is a config file as it was read.
+type File struct {
+	Path    string    `json:"path"`
+	ModTime time.Time `json:"mod_time"`
+	SHA256  string    `json:"sha256"`
+}
+
+// Value is one value of a configuration and the layer that set it.
+type Value struct {
+	Path   string `json:"path"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// LayeredConfiguration is a configuration merged from several layers, later layers first:
+//  1. EnvironmentPrefix environment variables, overriding single values.
+//  2. conf.d/*.toml next to the config file in name order.  Tables are merged key by key and
+//     arrays of tables with IDs, such as Commands, are merged by ID.  Other arrays are replaced.
+//  3. The config file.
+type LayeredConfiguration struct {
+	Configuration *Configuration
+	// Files are the files read, the config file first.
+	Files []File
+	// Values are the values of Configuration sorted by path, secret values are redacted.
+	Values []Value
+}
+
+func layerFiles(configFile string) ([]string, error) {
+	confDFiles, err := filepath.Glob(filepath.Join(filepath.Dir(configFile), "conf.d", "*.toml"))
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]string{configFile}, confDFiles...), nil
+}
+
+// layer is a config file as it was decoded.
+type layer struct {
+	file  File
+	data  []byte
+	table map[string]any
+	// undecodedKeys are the keys of table that are not fields of Configuration.
+	undecodedKeys []toml.Key
+}
+
+// layerDecodeError is a TOML error in the config file at path.
+type layerDecodeError struct {
+	path string
+	err  error
+}
+
+func (layerDecodeError *layerDecodeError) Error() string {
+	return layerDecodeError.path + ": " + layerDecodeError.err.Error()
+}
+
+func (layerDecodeError *layerDecodeError) Unwrap() error {
+	return layerDecodeError.err
+}
+
+func decodeLayer(path string) (*layer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	layer := &layer{
+		file: File{
+			Path:    path,
+			ModTime: fileInfo.ModTime(),
+			SHA256:  fmt.Sprintf("%x", sha256.Sum256(data)),
+		},
+		data: data,
+	}
+
+	// decode into Configuration first so type errors are reported with lines in file
+	var configuration Configuration
+	metaData, err := toml.Decode(string(data), &configuration)
+	if err != nil {
+		return nil, &layerDecodeError{path: path, err: err}
+	}
+	layer.undecodedKeys = metaData.Undecoded()
+
+	if _, err := toml.Decode(string(data), &layer.table); err != nil {
+		return nil, &layerDecodeError{path: path, err: err}
+	}
+
+	return layer, nil
+}
+
+// lookupKey returns the key of table matching key, which the decoder matches case insensitively.
+func lookupKey(
+	table map[string]any,
+	key string,
+) (string, bool) {
+	if _, ok := table[key]; ok {
+		return key, true
+	}
+
+	for tableKey := range table {
+		if strings.EqualFold(tableKey, key) {
+			return tableKey, true
+		}
+	}
+
+	return key, false
+}
+
+func tableID(table map[string]any) (string, bool) {
+	key, ok := lookupKey(table, "ID")
+	if !ok {
+		return "", false
+	}
+
+	id, ok := table[key].(string)
+	return id, ok
+}
+
+// tablesWithIDs returns value as tables if it is an array of tables that all have IDs.
+func tablesWithIDs(value any) ([]map[string]any, bool) {
+	var elements []any
+	switch value := value.(type) {
+	case []map[string]any:
+		for _, element := range value {
+			elements = append(elements, element)
+		}
+	case []any:
+		elements = value
+	default:
+		return nil, false
+	}
+
+	tables := make([]map[string]any, 0, len(elements))
+	for _, element := range elements {
+		table, ok := element.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if _, ok := tableID(table); !ok {
+			return nil, false
+		}
+		tables = append(tables, table)
+	}
+
+	return tables, true
+}
+
+func mergeTablesByID(
+	dst []map[string]any,
+	src []map[string]any,
+) []map[string]any {
+	for _, srcTable := range src {
+		srcID, _ := tableID(srcTable)
+
+		i := slices.IndexFunc(dst, func(dstTable map[string]any) bool {
+			dstID, _ := tableID(dstTable)
+			return dstID == srcID
+		})
+
+		if i >= 0 {
+			mergeTables(dst[i], srcTable)
+		} else {
+			dst = append(dst, srcTable)
+		}
+	}
+
+	return dst
+}
+
+// mergeTables merges src into dst.
+func mergeTables(
+	dst map[string]any,
+	src map[string]any,
+) {
+	for key, srcValue := range src {
+		dstKey, ok := lookupKey(dst, key)
+		if !ok {
+			dst[key] = srcValue
+			continue
+		}
+
+		dstValue := dst[dstKey]
+
+		if srcTable, ok := srcValue.(map[string]any); ok {
+			if dstTable, ok := dstValue.(map[string]any); ok {
+				mergeTables(dstTable, srcTable)
+				continue
+			}
+		}
+
+		if srcTables, ok := tablesWithIDs(srcValue); ok {
+			if dstTables, ok := tablesWithIDs(dstValue); ok {
+				dst[dstKey] = mergeTablesByID(dstTables, srcTables)
+				continue
+			}
+		}
+
+		dst[dstKey] = srcValue
+	}
+}
+
+func decodeMergedLayers(merged map[string]any) (*Configuration, error) {
+	var buffer bytes.Buffer
+	if err := toml.NewEncoder(&buffer).Encode(merged); err != nil {
+		return nil, fmt.Errorf("toml Encode error: %w", err)
+	}
+
+	var configuration Configuration
+	if _, err := toml.NewDecoder(&buffer).Decode(&configuration); err != nil {
+		return nil, fmt.Errorf("toml Decode error: %w", err)
+	}
+
+	return &configuration, nil
+}
+
+// environmentField is a value of a configuration that may be overridden by an environment variable.
+type environmentField struct {
+	path  string
+	value reflect.Value
+}
+
+func isEnvironmentScalar(fieldType reflect.Type) bool {
+	switch fieldType.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+
+	case reflect.Pointer:
+		return isEnvironmentScalar(fieldType.Elem())
+
+	default:
+		return false
+	}
+}
+
+// environmentFields adds the scalar fields of the struct value and its nested structs to nameToField.
+func environmentFields(
+	namePrefix string,
+	pathPrefix string,
+	value reflect.Value,
+	nameToField map[string]environmentField,
+) {
+	for i := range value.NumField() {
+		structField := value.Type().Field(i)
+		fieldValue := value.Field(i)
+
+		name := namePrefix + strings.ToUpper(structField.Name)
+		path := structField.Name
+		if pathPrefix != "" {
+			path = pathPrefix + "." + path
+		}
+
+		switch {
+		case structField.Type.Kind() == reflect.Struct:
+			environmentFields(name+"_", path, fieldValue, nameToField)
+
+		case isEnvironmentScalar(structField.Type):
+			nameToField[name] = environmentField{
+				path:  path,
+				value: fieldValue,
+			}
+		}
+	}
+}
+
+func setFromString(
+	value reflect.Value,
+	s string,
+) error {
+	if value.Kind() == reflect.Pointer {
+		pointer := reflect.New(value.Type().Elem())
+		if err := setFromString(pointer.Elem(), s); err != nil {
+			return err
+		}
+		value.Set(pointer)
+		return nil
+	}
+
+	if value.Type() == reflect.TypeFor[time.Duration]() {
+		duration, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(duration))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(s)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(u)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+
+	default:
+		return fmt.Errorf("unsupported type %v", value.Type())
+	}
+
+	return nil
+}
+
+// applyEnvironment sets the values named by the EnvironmentPrefix variables in environ.
+func applyEnvironment(
+	configuration *Configuration,
+	environ []string,
+	pathToSource map[string]string,
+) error {
+	nameToField := make(map[string]environmentField)
+	environmentFields(EnvironmentPrefix, "", reflect.ValueOf(configuration).Elem(), nameToField)
+
+	for _, variable := range environ {
+		name, value, _ := strings.Cut(variable, "=")
+		if !strings.HasPrefix(name, EnvironmentPrefix) {
+			continue
+		}
+
+		field, ok := nameToField[name]
+		if !ok {
+			return fmt.Errorf("environment variable %s does not name a configuration value", name)
+		}
+
+		if err := setFromString(field.value, value); err != nil {
+			return fmt.Errorf("environment variable %s: %w", name, err)
+		}
+
+		pathToSource[field.path] = "environment " + name
+	}
+
+	return nil
+}
+
+// zeroJSONValues are the flattened zero values, including time.Duration's.
+var zeroJSONValues = []string{`0`, `""`, `false`, `null`, `"0s"`}
+
+// setSources records source for the values of pathToValue that differ from previousPathToValue.
+// Zero values of new paths, such as the unset fields of an added command, are left as defaults.
+func setSources(
+	source string,
+	previousPathToValue map[string]string,
+	pathToValue map[string]string,
+	pathToSource map[string]string,
+) {
+	for path, value := range pathToValue {
+		previousValue, ok := previousPathToValue[path]
+
+		switch {
+		case !ok && slices.Contains(zeroJSONValues, value):
+		case !ok || previousValue != value:
+			pathToSource[path] = source
+		}
+	}
+}
+
+// ReadLayeredConfiguration reads configFile and the layers above it, environ is usually os.Environ().
+func ReadLayeredConfiguration(
+	configFile string,
+	environ []string,
+) (*LayeredConfiguration, error) {
+	return readLayeredConfiguration(configFile, environ, nil)
+}
+
+// readLayeredConfiguration is ReadLayeredConfiguration calling checkLayer, if not nil,
+// with each layer and the configuration merged up to it.  The layer is nil for the environment variables.
+func readLayeredConfiguration(
+	configFile string,
+	environ []string,
+	checkLayer func(layer *layer, configuration *Configuration),
+) (*LayeredConfiguration, error) {
+	paths, err := layerFiles(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("layerFiles error: %w", err)
+	}
+
+	pathToSource := make(map[string]string)
+
+	previousPathToValue, err := flattenConfiguration(new(Configuration))
+	if err != nil {
+		return nil, fmt.Errorf("flattenConfiguration error: %w", err)
+	}
+
+	merged := make(map[string]any)
+	configuration := new(Configuration)
+	files := make([]File, 0, len(paths))
+
+	for _, path := range paths {
+		layer, err := decodeLayer(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, layer.file)
+
+		mergeTables(merged, layer.table)
+
+		configuration, err = decodeMergedLayers(merged)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		if checkLayer != nil {
+			checkLayer(layer, configuration)
+		}
+
+		pathToValue, err := flattenConfiguration(configuration)
+		if err != nil {
+			return nil, fmt.Errorf("flattenConfiguration error: %w", err)
+		}
+
+		setSources(path, previousPathToValue, pathToValue, pathToSource)
+		previousPathToValue = pathToValue
+	}
+
+	if err := applyEnvironment(configuration, environ, pathToSource); err != nil {
+		return nil, err
+	}
+
+	if checkLayer != nil {
+		checkLayer(nil, configuration)
+	}
+	pathToValue, err := flattenRedactedConfiguration(configuration)
+	if err != nil {
+		return nil, fmt.Errorf("flattenRedactedConfiguration error: %w", err)
+	}
+
+	values := make([]Value, 0, len(pathToValue))
+	for _, path := range slices.Sorted(maps.Keys(pathToValue)) {
+		source, ok := pathToSource[path]
+		if !ok {
+			source = DefaultSource
+		}
+
+		values = append(values, Value{
+			Path:   path,
+			Value:  pathToValue[path],
+			Source: source,
+		})
+	}
+
+	return &LayeredConfiguration{
+		Configuration: configuration,
+		Files:         files,
+		Values:        values,
+	}, nil
+}
@@ -0,0 +1,184 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeLayers(
+	t *testing.T,
+	nameToContent map[string]string,
+) string {
+	t.Helper()
+
+	directory := t.TempDir()
+	if err := os.Mkdir(filepath.Join(directory, "conf.d"), 0o700); err != nil {
+		t.Fatalf("os.Mkdir error: %v", err)
+	}
+
+	for name, content := range nameToContent {
+		if err := os.WriteFile(filepath.Join(directory, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("os.WriteFile error: %v", err)
+		}
+	}
+
+	return filepath.Join(directory, "config.toml")
+}
+
+const baseLayer = `
+[serverConfiguration]
+listeners = [
+  { network = "tcp", listenAddress = ":8080" },
+  { network = "unix", listenAddress = "/tmp/go-api.sock" },
+]
+apiContext = "/api/v1"
+
+[commandConfiguration]
+maxConcurrentCommands = 10
+requestTimeoutDuration = "2s"
+commands = [
+  { id = "df", command = "/bin/df", args = ["-h"] },
+  { id = "uptime", command = "/usr/bin/uptime" },
+]
+`
+
+func TestReadLayeredConfiguration(t *testing.T) {
+	configFile := writeLayers(t, map[string]string{
+		"config.toml": baseLayer,
+		"conf.d/10-host.toml": `
+[serverConfiguration]
+listeners = [{ network = "tcp", listenAddress = ":9090" }]
+
+[[commandConfiguration.commands]]
+id = "df"
+args = ["-k"]
+
+[[commandConfiguration.commands]]
+id = "w"
+command = "/usr/bin/w"
+`,
+		"conf.d/20-override.toml": `
+[commandConfiguration]
+requestTimeoutDuration = "5s"
+`,
+		"conf.d/ignored.txt": `not toml`,
+	})
+
+	layeredConfiguration, err := ReadLayeredConfiguration(configFile, []string{
+		"HOME=/root",
+		"GOAPI_COMMANDCONFIGURATION_MAXCONCURRENTCOMMANDS=20",
+		"GOAPI_COMMANDCONFIGURATION_EXECUTIONCONFIGURATION_UID=1000",
+	})
+	if err != nil {
+		t.Fatalf("ReadLayeredConfiguration error: %v", err)
+	}
+
+	directory := filepath.Dir(configFile)
+	wantFiles := []string{
+		configFile,
+		filepath.Join(directory, "conf.d", "10-host.toml"),
+		filepath.Join(directory, "conf.d", "20-override.toml"),
+	}
+	var files []string
+	for _, file := range layeredConfiguration.Files {
+		files = append(files, file.Path)
+		if file.SHA256 == "" || file.ModTime.IsZero() {
+			t.Fatalf("file = %+v, want SHA256 and ModTime", file)
+		}
+	}
+	if !reflect.DeepEqual(files, wantFiles) {
+		t.Fatalf("Files = %v, want %v", files, wantFiles)
+	}
+
+	configuration := layeredConfiguration.Configuration
+
+	wantListeners := []ServerListenerConfiguration{{Network: "tcp", ListenAddress: ":9090"}}
+	if !reflect.DeepEqual(configuration.ServerConfiguration.Listeners, wantListeners) {
+		t.Fatalf("Listeners = %v, want %v", configuration.ServerConfiguration.Listeners, wantListeners)
+	}
+
+	wantCommands := []CommandInfo{
+		{ID: "df", Command: "/bin/df", Args: []string{"-k"}},
+		{ID: "uptime", Command: "/usr/bin/uptime"},
+		{ID: "w", Command: "/usr/bin/w"},
+	}
+	if !reflect.DeepEqual(configuration.CommandConfiguration.Commands, wantCommands) {
+		t.Fatalf("Commands = %+v, want %+v", configuration.CommandConfiguration.Commands, wantCommands)
+	}
+
+	if got := configuration.CommandConfiguration.RequestTimeoutDuration; got != 5*time.Second {
+		t.Fatalf("RequestTimeoutDuration = %v, want 5s", got)
+	}
+
+	if got := configuration.CommandConfiguration.MaxConcurrentCommands; got != 20 {
+		t.Fatalf("MaxConcurrentCommands = %v, want 20", got)
+	}
+
+	if uid := configuration.CommandConfiguration.ExecutionConfiguration.UID; uid == nil || *uid != 1000 {
+		t.Fatalf("UID = %v, want 1000", uid)
+	}
+
+	pathToSource := make(map[string]string)
+	for _, value := range layeredConfiguration.Values {
+		pathToSource[value.Path] = value.Source
+	}
+
+	for path, wantSource := range map[string]string{
+		"ServerConfiguration.APIContext":                        configFile,
+		"ServerConfiguration.Listeners[0].ListenAddress":        wantFiles[1],
+		"CommandConfiguration.Commands[df].Command":             configFile,
+		"CommandConfiguration.Commands[df].Args[0]":             wantFiles[1],
+		"CommandConfiguration.Commands[w].Command":              wantFiles[1],
+		"CommandConfiguration.RequestTimeoutDuration":           wantFiles[2],
+		"CommandConfiguration.MaxConcurrentCommands":            "environment GOAPI_COMMANDCONFIGURATION_MAXCONCURRENTCOMMANDS",
+		"CommandConfiguration.ExecutionConfiguration.UID":       "environment GOAPI_COMMANDCONFIGURATION_EXECUTIONCONFIGURATION_UID",
+		"CommandConfiguration.JobConfiguration.MaxJobs":         DefaultSource,
+		"RequestLoggingConfiguration.RequestLogFile":            DefaultSource,
+		"CommandConfiguration.ExecutionConfiguration.NiceLevel": DefaultSource,
+	} {
+		if got := pathToSource[path]; got != wantSource {
+			t.Errorf("source of %s = %q, want %q", path, got, wantSource)
+		}
+	}
+}
+
+func TestReadLayeredConfigurationErrors(t *testing.T) {
+	tests := map[string]struct {
+		confD       string
+		environ     []string
+		wantMessage string
+	}{
+		"type error in layer": {
+			confD:       "[commandConfiguration]\nmaxConcurrentCommands = \"ten\"\n",
+			wantMessage: "10-host.toml: toml: line 2",
+		},
+		"unknown environment variable": {
+			environ:     []string{"GOAPI_BOGUS=1"},
+			wantMessage: "GOAPI_BOGUS does not name a configuration value",
+		},
+		"bad environment value": {
+			environ:     []string{"GOAPI_COMMANDCONFIGURATION_REQUESTTIMEOUTDURATION=soon"},
+			wantMessage: "GOAPI_COMMANDCONFIGURATION_REQUESTTIMEOUTDURATION",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			nameToContent := map[string]string{
+				"config.toml": baseLayer,
+			}
+			if test.confD != "" {
+				nameToContent["conf.d/10-host.toml"] = test.confD
+			}
+
+			_, err := ReadLayeredConfiguration(writeLayers(t, nameToContent), test.environ)
+			if err == nil || !strings.Contains(err.Error(), test.wantMessage) {
+				t.Fatalf("err = %v, want containing %q", err, test.wantMessage)
+			}
+		})
+	}
+}
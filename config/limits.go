@@ -0,0 +1,57 @@
+package config
+
+import (
+	"net/http"
+	"time"
+)
+
+// Defaults for the ServerLimitsConfiguration fields that are not set.
+const (
+	DefaultReadTimeout       = 1 * time.Minute
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultWriteTimeout      = 1 * time.Minute
+	DefaultIdleTimeout       = 5 * time.Minute
+	DefaultMaxHeaderBytes    = http.DefaultMaxHeaderBytes
+)
+
+// Defaults for the JobConfiguration fields that are not set.
+const (
+	DefaultMaxJobs    = 10
+	DefaultJobTimeout = 1 * time.Minute
+	DefaultJobTTL     = 10 * time.Minute
+)
+
+// WithDefaults returns limits with the fields that are not set replaced by their defaults.
+// HTTP2 is unchanged, Go has its own defaults for it.
+func (limits ServerLimitsConfiguration) WithDefaults() ServerLimitsConfiguration {
+	if limits.ReadTimeoutDuration == 0 {
+		limits.ReadTimeoutDuration = DefaultReadTimeout
+	}
+	if limits.ReadHeaderTimeoutDuration == 0 {
+		limits.ReadHeaderTimeoutDuration = DefaultReadHeaderTimeout
+	}
+	if limits.WriteTimeoutDuration == 0 {
+		limits.WriteTimeoutDuration = DefaultWriteTimeout
+	}
+	if limits.IdleTimeoutDuration == 0 {
+		limits.IdleTimeoutDuration = DefaultIdleTimeout
+	}
+	if limits.MaxHeaderBytes == 0 {
+		limits.MaxHeaderBytes = DefaultMaxHeaderBytes
+	}
+	return limits
+}
+
+// WithDefaults returns jobConfiguration with the fields that are not set replaced by their defaults.
+func (jobConfiguration JobConfiguration) WithDefaults() JobConfiguration {
+	if jobConfiguration.MaxJobs == 0 {
+		jobConfiguration.MaxJobs = DefaultMaxJobs
+	}
+	if jobConfiguration.JobTimeoutDuration == 0 {
+		jobConfiguration.JobTimeoutDuration = DefaultJobTimeout
+	}
+	if jobConfiguration.JobTTLDuration == 0 {
+		jobConfiguration.JobTTLDuration = DefaultJobTTL
+	}
+	return jobConfiguration
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServerLimitsWithDefaults(t *testing.T) {
+	limits := ServerLimitsConfiguration{
+		ReadTimeoutDuration: 5 * time.Second,
+		HTTP2: HTTP2Configuration{
+			MaxConcurrentStreams: 10,
+		},
+	}.WithDefaults()
+
+	want := ServerLimitsConfiguration{
+		ReadTimeoutDuration:       5 * time.Second,
+		ReadHeaderTimeoutDuration: DefaultReadHeaderTimeout,
+		WriteTimeoutDuration:      DefaultWriteTimeout,
+		IdleTimeoutDuration:       DefaultIdleTimeout,
+		MaxHeaderBytes:            DefaultMaxHeaderBytes,
+		HTTP2: HTTP2Configuration{
+			MaxConcurrentStreams: 10,
+		},
+	}
+
+	if limits != want {
+		t.Fatalf("WithDefaults = %+v, want %+v", limits, want)
+	}
+}
+
+func TestJobConfigurationWithDefaults(t *testing.T) {
+	jobConfiguration := JobConfiguration{
+		MaxJobs: 5,
+	}.WithDefaults()
+
+	want := JobConfiguration{
+		MaxJobs:            5,
+		JobTimeoutDuration: DefaultJobTimeout,
+		JobTTLDuration:     DefaultJobTTL,
+	}
+
+	if jobConfiguration != want {
+		t.Fatalf("WithDefaults = %+v, want %+v", jobConfiguration, want)
+	}
+}
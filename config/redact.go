@@ -0,0 +1,104 @@
+package config
+
+import (
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"reflect"
+)
+
+// RedactedValue replaces the values of fields tagged secret:"true" when a configuration is shown.
+// Map keys are kept and empty values are left empty, so it is still visible what is set.
+const RedactedValue = "REDACTED"
+
+func redactSecret(value any) any {
+	switch value := value.(type) {
+	case nil:
+		return nil
+
+	case string:
+		if value == "" {
+			return value
+		}
+		return RedactedValue
+
+	case map[string]any:
+		for key := range value {
+			value[key] = redactSecret(value[key])
+		}
+		return value
+
+	case []any:
+		for i := range value {
+			value[i] = redactSecret(value[i])
+		}
+		return value
+
+	default:
+		return RedactedValue
+	}
+}
+
+// redact replaces the secret values in value, the unmarshalled JSON of a valueType.
+func redact(
+	value any,
+	valueType reflect.Type,
+) any {
+	for valueType.Kind() == reflect.Pointer {
+		valueType = valueType.Elem()
+	}
+
+	switch valueType.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return value
+		}
+
+		for i := range valueType.NumField() {
+			structField := valueType.Field(i)
+
+			fieldValue, ok := object[structField.Name]
+			if !ok {
+				continue
+			}
+
+			if structField.Tag.Get("secret") == "true" {
+				object[structField.Name] = redactSecret(fieldValue)
+			} else {
+				object[structField.Name] = redact(fieldValue, structField.Type)
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		if array, ok := value.([]any); ok {
+			for i := range array {
+				array[i] = redact(array[i], valueType.Elem())
+			}
+		}
+
+	case reflect.Map:
+		if object, ok := value.(map[string]any); ok {
+			for key := range object {
+				object[key] = redact(object[key], valueType.Elem())
+			}
+		}
+	}
+
+	return value
+}
+
+// MarshalRedacted returns configuration as JSON with secret values replaced by RedactedValue.
+func MarshalRedacted(configuration *Configuration) (jsontext.Value, error) {
+	configurationJSON, err := json.Marshal(configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	err = json.Unmarshal(configurationJSON, &value)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(redact(value, reflect.TypeFor[Configuration]()), json.Deterministic(true))
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"encoding/json/v2"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMarshalRedacted(t *testing.T) {
+	configuration := &Configuration{
+		CommandConfiguration: CommandConfiguration{
+			RequestTimeoutDuration: 2 * time.Second,
+			ExecutionConfiguration: ExecutionConfiguration{
+				EnvAllowlist: []string{"PATH"},
+				EnvOverrides: map[string]string{"API_TOKEN": "hunter2", "EMPTY": ""},
+			},
+			Commands: []CommandInfo{
+				{
+					ID:      "curl",
+					Command: "/usr/bin/curl",
+					ExecutionConfiguration: &ExecutionConfiguration{
+						EnvOverrides: map[string]string{"PASSWORD": "secret"},
+					},
+				},
+			},
+		},
+	}
+
+	redactedJSON, err := MarshalRedacted(configuration)
+	if err != nil {
+		t.Fatalf("MarshalRedacted error: %v", err)
+	}
+
+	var redacted struct {
+		CommandConfiguration struct {
+			RequestTimeoutDuration string
+			ExecutionConfiguration struct {
+				EnvAllowlist []string
+				EnvOverrides map[string]string
+			}
+			Commands []struct {
+				Command                string
+				ExecutionConfiguration struct {
+					EnvOverrides map[string]string
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(redactedJSON, &redacted); err != nil {
+		t.Fatalf("json.Unmarshal error: %v", err)
+	}
+
+	commandConfiguration := redacted.CommandConfiguration
+
+	if commandConfiguration.RequestTimeoutDuration != "2s" {
+		t.Errorf("RequestTimeoutDuration = %q, want 2s", commandConfiguration.RequestTimeoutDuration)
+	}
+
+	if !reflect.DeepEqual(commandConfiguration.ExecutionConfiguration.EnvAllowlist, []string{"PATH"}) {
+		t.Errorf("EnvAllowlist = %v, want [PATH]", commandConfiguration.ExecutionConfiguration.EnvAllowlist)
+	}
+
+	wantEnvOverrides := map[string]string{"API_TOKEN": RedactedValue, "EMPTY": ""}
+	if !reflect.DeepEqual(commandConfiguration.ExecutionConfiguration.EnvOverrides, wantEnvOverrides) {
+		t.Errorf("EnvOverrides = %v, want %v", commandConfiguration.ExecutionConfiguration.EnvOverrides, wantEnvOverrides)
+	}
+
+	if len(commandConfiguration.Commands) != 1 {
+		t.Fatalf("Commands = %v, want 1 command", commandConfiguration.Commands)
+	}
+
+	commandInfo := commandConfiguration.Commands[0]
+	if commandInfo.Command != "/usr/bin/curl" {
+		t.Errorf("Command = %q, want /usr/bin/curl", commandInfo.Command)
+	}
+
+	wantCommandEnvOverrides := map[string]string{"PASSWORD": RedactedValue}
+	if !reflect.DeepEqual(commandInfo.ExecutionConfiguration.EnvOverrides, wantCommandEnvOverrides) {
+		t.Errorf("command EnvOverrides = %v, want %v", commandInfo.ExecutionConfiguration.EnvOverrides, wantCommandEnvOverrides)
+	}
+}
+
+func TestDiffRedactsSecrets(t *testing.T) {
+	oldConfiguration := &Configuration{
+		CommandConfiguration: CommandConfiguration{
+			ExecutionConfiguration: ExecutionConfiguration{
+				EnvOverrides: map[string]string{"API_TOKEN": "old"},
+			},
+		},
+	}
+	newConfiguration := &Configuration{
+		CommandConfiguration: CommandConfiguration{
+			ExecutionConfiguration: ExecutionConfiguration{
+				EnvOverrides: map[string]string{"API_TOKEN": "new"},
+			},
+		},
+	}
+
+	changes, err := Diff(oldConfiguration, newConfiguration)
+	if err != nil {
+		t.Fatalf("Diff error: %v", err)
+	}
+
+	wantChanges := []Change{
+		{
+			Path: "CommandConfiguration.ExecutionConfiguration.EnvOverrides.API_TOKEN",
+			Old:  `"` + RedactedValue + `"`,
+			New:  `"` + RedactedValue + `"`,
+		},
+	}
+	if !reflect.DeepEqual(changes, wantChanges) {
+		t.Fatalf("changes = %+v, want %+v", changes, wantChanges)
+	}
+}
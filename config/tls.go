@@ -0,0 +1,81 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"slices"
+)
+
+const (
+	ALPNProtocolHTTP1 = "http/1.1"
+	ALPNProtocolHTTP2 = "h2"
+)
+
+var defaultALPNProtocols = []string{ALPNProtocolHTTP2, ALPNProtocolHTTP1}
+
+// TLSMinVersion returns the crypto/tls value of MinVersion.
+func (tlsConfiguration *TLSConfiguration) TLSMinVersion() (uint16, error) {
+	switch tlsConfiguration.MinVersion {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+
+	case "1.3":
+		return tls.VersionTLS13, nil
+
+	default:
+		return 0, fmt.Errorf("unknown MinVersion %q, expected 1.2 or 1.3", tlsConfiguration.MinVersion)
+	}
+}
+
+// TLSCipherSuites returns the crypto/tls IDs of CipherSuites, nil for Go's defaults.
+// Only the cipher suites crypto/tls considers secure are accepted.
+func (tlsConfiguration *TLSConfiguration) TLSCipherSuites() ([]uint16, error) {
+	if len(tlsConfiguration.CipherSuites) == 0 {
+		return nil, nil
+	}
+
+	secureCipherSuites := tls.CipherSuites()
+
+	ids := make([]uint16, 0, len(tlsConfiguration.CipherSuites))
+	for _, name := range tlsConfiguration.CipherSuites {
+		index := slices.IndexFunc(secureCipherSuites, func(cipherSuite *tls.CipherSuite) bool {
+			return cipherSuite.Name == name
+		})
+		if index < 0 {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, secureCipherSuites[index].ID)
+	}
+
+	return ids, nil
+}
+
+// NextProtos returns ALPNProtocols or the default protocols.
+func (tlsConfiguration *TLSConfiguration) NextProtos() []string {
+	if len(tlsConfiguration.ALPNProtocols) == 0 {
+		return slices.Clone(defaultALPNProtocols)
+	}
+	return slices.Clone(tlsConfiguration.ALPNProtocols)
+}
+
+// Check checks the settings other than the certificate files.
+func (tlsConfiguration *TLSConfiguration) Check() error {
+	if tlsConfiguration.CertFile == "" || tlsConfiguration.KeyFile == "" {
+		return fmt.Errorf("CertFile and KeyFile are required")
+	}
+
+	if _, err := tlsConfiguration.TLSMinVersion(); err != nil {
+		return err
+	}
+
+	if _, err := tlsConfiguration.TLSCipherSuites(); err != nil {
+		return err
+	}
+
+	nextProtos := tlsConfiguration.NextProtos()
+	if !slices.Contains(nextProtos, ALPNProtocolHTTP1) && !slices.Contains(nextProtos, ALPNProtocolHTTP2) {
+		return fmt.Errorf("ALPNProtocols %q include neither %q nor %q", nextProtos, ALPNProtocolHTTP1, ALPNProtocolHTTP2)
+	}
+
+	return nil
+}
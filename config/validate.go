@@ -0,0 +1,694 @@
+package config
+
+import (
+	"cmp"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os/exec"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Diagnostic is one problem found in a config file.  Line is 0 when the problem has no location in the file.
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitzero"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+
+	// problem is Message without details that differ between layers, such as the line of another value.
+	problem string
+}
+
+func (diagnostic Diagnostic) String() string {
+	location := diagnostic.File
+	if diagnostic.Line > 0 {
+		location += ":" + strconv.Itoa(diagnostic.Line)
+	}
+
+	if diagnostic.Path == "" {
+		return location + ": " + diagnostic.Message
+	}
+	return location + ": " + diagnostic.Path + ": " + diagnostic.Message
+}
+
+// ValidationError is the error reading a configuration with problems.
+type ValidationError struct {
+	Diagnostics []Diagnostic
+}
+
+func (validationError *ValidationError) Error() string {
+	messages := make([]string, 0, len(validationError.Diagnostics))
+	for _, diagnostic := range validationError.Diagnostics {
+		messages = append(messages, diagnostic.String())
+	}
+
+	return fmt.Sprintf("%d configuration problem(s): %s", len(messages), strings.Join(messages, "; "))
+}
+
+// configLocator finds the lines of tables and keys in a TOML file.
+// It is best effort, the decoder does not report the positions of decoded keys.
+type configLocator struct {
+	lines []string
+}
+
+func newConfigLocator(data []byte) *configLocator {
+	return &configLocator{
+		lines: strings.Split(string(data), "\n"),
+	}
+}
+
+// tableLine returns the line of the [key] or [[key]] header, matching keys case insensitively like the decoder.
+func (configLocator *configLocator) tableLine(key ...string) int {
+	header := strings.ToLower(strings.Join(key, "."))
+
+	for i, line := range configLocator.lines {
+		line = strings.ToLower(strings.TrimSpace(line))
+		if line == "["+header+"]" || line == "[["+header+"]]" {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+// tableEnd returns the line of the next table header after line from, or the line after the last one.
+func (configLocator *configLocator) tableEnd(from int) int {
+	for i := from; i < len(configLocator.lines); i++ {
+		if strings.HasPrefix(strings.TrimSpace(configLocator.lines[i]), "[") {
+			return i + 1
+		}
+	}
+	return len(configLocator.lines) + 1
+}
+
+// assignmentLine returns the line of the occurrence'th (from 0) match of pattern
+// in lines from up to but not including to, or 0 if there is none.
+func (configLocator *configLocator) assignmentLine(
+	pattern *regexp.Regexp,
+	from int,
+	to int,
+	occurrence int,
+) int {
+	to = min(to, len(configLocator.lines)+1)
+
+	for line := max(from, 1); line < to; line++ {
+		if !pattern.MatchString(configLocator.lines[line-1]) {
+			continue
+		}
+		if occurrence == 0 {
+			return line
+		}
+		occurrence--
+	}
+
+	return 0
+}
+
+func keyPattern(key string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(^|[\s{,])"?` + regexp.QuoteMeta(key) + `"?\s*=`)
+}
+
+func idPattern(id string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(^|[\s{,])"?id"?\s*=\s*"` + regexp.QuoteMeta(id) + `"`)
+}
+
+// keyLine returns the line of the occurrence'th assignment of key from line from up to but not including to.
+func (configLocator *configLocator) keyLine(
+	key string,
+	from int,
+	to int,
+	occurrence int,
+) int {
+	return configLocator.assignmentLine(keyPattern(key), from, to, occurrence)
+}
+
+// tableKeyLine returns the line of key in the table, or of the table header if key is not set.
+func (configLocator *configLocator) tableKeyLine(
+	table []string,
+	key string,
+) int {
+	tableLine := configLocator.tableLine(table...)
+	if tableLine == 0 {
+		return 0
+	}
+
+	return firstNonZero(
+		configLocator.keyLine(key, tableLine, configLocator.tableEnd(tableLine), 0),
+		tableLine,
+	)
+}
+
+// firstNonZero returns the first of lines that is not 0.
+func firstNonZero(lines ...int) int {
+	for _, line := range lines {
+		if line != 0 {
+			return line
+		}
+	}
+	return 0
+}
+
+type configValidator struct {
+	configFile        string
+	configLocator     *configLocator
+	checkCommandPaths bool
+	diagnostics       []Diagnostic
+	commandLines      []int
+	compositeLines    []int
+}
+
+func (configValidator *configValidator) add(
+	line int,
+	path string,
+	format string,
+	args ...any,
+) {
+	message := fmt.Sprintf(format, args...)
+
+	configValidator.diagnostics = append(configValidator.diagnostics, Diagnostic{
+		File:    configValidator.configFile,
+		Line:    line,
+		Path:    path,
+		Message: message,
+		problem: message,
+	})
+}
+
+// addWithDetail adds problem with detail appended to its Message.
+func (configValidator *configValidator) addWithDetail(
+	line int,
+	path string,
+	problem string,
+	detail string,
+) {
+	configValidator.add(line, path, "%s", problem)
+	configValidator.diagnostics[len(configValidator.diagnostics)-1].Message += detail
+}
+
+var tomlErrorLineRegexp = regexp.MustCompile(`^toml: line (\d+)`)
+
+func (configValidator *configValidator) addDecodeError(err error) {
+	line := 0
+
+	var parseError toml.ParseError
+	if errors.As(err, &parseError) {
+		line = parseError.Position.Line
+	} else if match := tomlErrorLineRegexp.FindStringSubmatch(err.Error()); match != nil {
+		line, _ = strconv.Atoi(match[1])
+	}
+
+	configValidator.add(line, "", "%v", err)
+}
+
+func (configValidator *configValidator) checkUndecodedKeys(undecodedKeys []toml.Key) {
+	keyToOccurrences := make(map[string]int)
+
+	for _, key := range undecodedKeys {
+		occurrence := keyToOccurrences[key.String()]
+		keyToOccurrences[key.String()]++
+
+		// search from the header of the innermost table containing the key
+		from := 0
+		for i := len(key) - 1; i > 0 && from == 0; i-- {
+			from = configValidator.configLocator.tableLine(key[:i]...)
+		}
+
+		line := configValidator.configLocator.keyLine(key[len(key)-1], from, len(configValidator.configLocator.lines)+1, occurrence)
+
+		configValidator.add(line, key.String(), "unknown key")
+	}
+}
+
+func (configValidator *configValidator) checkAddress(
+	line int,
+	path string,
+	address string,
+) {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		configValidator.add(line, path, "invalid address %q: %v", address, err)
+		return
+	}
+
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		configValidator.add(line, path, "invalid port %q in address %q", port, address)
+	}
+}
+
+func (configValidator *configValidator) checkServerConfiguration(serverConfiguration *ServerConfiguration) {
+	configLocator := configValidator.configLocator
+	table := []string{"ServerConfiguration"}
+	tableLine := configLocator.tableLine(table...)
+
+	if len(serverConfiguration.Listeners) == 0 {
+		configValidator.add(configLocator.tableKeyLine(table, "Listeners"), "ServerConfiguration.Listeners", "no listeners configured")
+	}
+
+	systemdSocketNames := make(map[string]bool)
+
+	for i, listener := range serverConfiguration.Listeners {
+		path := fmt.Sprintf("ServerConfiguration.Listeners[%d]", i)
+		line := configLocator.keyLine("Network", tableLine, configLocator.tableEnd(tableLine), i)
+
+		switch listener.Network {
+		case "tcp", "tcp4", "tcp6":
+			configValidator.checkAddress(line, path+".ListenAddress", listener.ListenAddress)
+
+		case "unix":
+			if listener.ListenAddress == "" {
+				configValidator.add(line, path+".ListenAddress", "unix listener needs a socket path")
+			}
+
+		case NetworkSystemd:
+			switch {
+			case listener.ListenAddress == "":
+				configValidator.add(line, path+".ListenAddress", "systemd listener needs a socket FileDescriptorName")
+
+			case systemdSocketNames[listener.ListenAddress]:
+				configValidator.add(line, path+".ListenAddress", "systemd socket %q is already used by another listener", listener.ListenAddress)
+			}
+			systemdSocketNames[listener.ListenAddress] = true
+
+		default:
+			configValidator.add(line, path+".Network", "unknown network %q, expected tcp, tcp4, tcp6, unix or %s", listener.Network, NetworkSystemd)
+		}
+
+		if listener.TLS != nil {
+			configValidator.checkTLSConfiguration(line, path, &listener)
+		}
+
+		configValidator.checkServerLimitsConfiguration(line, path+".Limits", &listener.Limits)
+	}
+
+	apiContext := serverConfiguration.APIContext
+	apiContextLine := configLocator.tableKeyLine(table, "APIContext")
+	cleanAPIContext := path.Clean(apiContext)
+
+	switch {
+	case !strings.HasPrefix(apiContext, "/"):
+		configValidator.add(apiContextLine, "ServerConfiguration.APIContext", "%q must start with /", apiContext)
+
+	case cleanAPIContext == "/health" || strings.HasPrefix(cleanAPIContext, "/health/"):
+		configValidator.add(apiContextLine, "ServerConfiguration.APIContext", "%q collides with /health", apiContext)
+	}
+
+	configValidator.checkNotNegative(table, "DrainDelayDuration", int64(serverConfiguration.DrainDelayDuration))
+	configValidator.checkNotNegative(table, "ShutdownTimeoutDuration", int64(serverConfiguration.ShutdownTimeoutDuration))
+	configValidator.checkNotNegative(table, "CommandShutdownTimeoutDuration", int64(serverConfiguration.CommandShutdownTimeoutDuration))
+}
+
+func (configValidator *configValidator) checkTLSConfiguration(
+	line int,
+	path string,
+	listener *ServerListenerConfiguration,
+) {
+	if listener.H2CEnabled {
+		configValidator.add(line, path+".H2CEnabled", "not used with TLS, HTTP/2 is negotiated with ALPN")
+	}
+
+	if err := listener.TLS.Check(); err != nil {
+		configValidator.add(line, path+".TLS", "%v", err)
+		return
+	}
+
+	if _, err := tls.LoadX509KeyPair(listener.TLS.CertFile, listener.TLS.KeyFile); err != nil {
+		configValidator.add(line, path+".TLS", "%v", err)
+	}
+}
+
+// checkServerLimitsConfiguration checks that the limits that are set are in the ranges Go accepts,
+// Go silently uses its default for an invalid HTTP/2 value.
+func (configValidator *configValidator) checkServerLimitsConfiguration(
+	line int,
+	path string,
+	limits *ServerLimitsConfiguration,
+) {
+	const maxValue = math.MaxInt64
+
+	for _, limit := range []struct {
+		name     string
+		value    int64
+		minValue int64
+		maxValue int64
+	}{
+		{"ReadTimeoutDuration", int64(limits.ReadTimeoutDuration), 0, maxValue},
+		{"ReadHeaderTimeoutDuration", int64(limits.ReadHeaderTimeoutDuration), 0, maxValue},
+		{"WriteTimeoutDuration", int64(limits.WriteTimeoutDuration), 0, maxValue},
+		{"IdleTimeoutDuration", int64(limits.IdleTimeoutDuration), 0, maxValue},
+		{"MaxHeaderBytes", int64(limits.MaxHeaderBytes), 0, maxValue},
+		{"HTTP2.MaxConcurrentStreams", int64(limits.HTTP2.MaxConcurrentStreams), 0, maxValue},
+		{"HTTP2.MaxDecoderHeaderTableSize", int64(limits.HTTP2.MaxDecoderHeaderTableSize), 0, 4<<20 - 1},
+		{"HTTP2.MaxEncoderHeaderTableSize", int64(limits.HTTP2.MaxEncoderHeaderTableSize), 0, 4<<20 - 1},
+		{"HTTP2.MaxReadFrameSize", int64(limits.HTTP2.MaxReadFrameSize), 16 << 10, 16 << 20},
+		{"HTTP2.MaxReceiveBufferPerConnection", int64(limits.HTTP2.MaxReceiveBufferPerConnection), 64 << 10, 4<<20 - 1},
+		{"HTTP2.MaxReceiveBufferPerStream", int64(limits.HTTP2.MaxReceiveBufferPerStream), 0, 4<<20 - 1},
+		{"HTTP2.SendPingTimeoutDuration", int64(limits.HTTP2.SendPingTimeoutDuration), 0, maxValue},
+		{"HTTP2.PingTimeoutDuration", int64(limits.HTTP2.PingTimeoutDuration), 0, maxValue},
+		{"HTTP2.WriteByteTimeoutDuration", int64(limits.HTTP2.WriteByteTimeoutDuration), 0, maxValue},
+	} {
+		switch {
+		case limit.value == 0:
+			// not set, the default is used
+
+		case limit.value < 0:
+			configValidator.add(line, path+"."+limit.name, "must not be negative, got %d", limit.value)
+
+		case limit.value < limit.minValue || limit.value > limit.maxValue:
+			configValidator.add(line, path+"."+limit.name, "must be between %d and %d, got %d", limit.minValue, limit.maxValue, limit.value)
+		}
+	}
+}
+
+func (configValidator *configValidator) checkPositive(
+	table []string,
+	key string,
+	value int64,
+) {
+	if value <= 0 {
+		configValidator.add(
+			configValidator.configLocator.tableKeyLine(table, key),
+			strings.Join(append(slices.Clone(table), key), "."),
+			"must be positive, got %d", value,
+		)
+	}
+}
+
+func (configValidator *configValidator) checkPositiveDuration(
+	table []string,
+	key string,
+	value time.Duration,
+) {
+	if value <= 0 {
+		configValidator.add(
+			configValidator.configLocator.tableKeyLine(table, key),
+			strings.Join(append(slices.Clone(table), key), "."),
+			"must be a positive duration, got %v", value,
+		)
+	}
+}
+
+func (configValidator *configValidator) checkNotNegative(
+	table []string,
+	key string,
+	value int64,
+) {
+	if value < 0 {
+		configValidator.add(
+			configValidator.configLocator.tableKeyLine(table, key),
+			strings.Join(append(slices.Clone(table), key), "."),
+			"must not be negative, got %d", value,
+		)
+	}
+}
+
+func (configValidator *configValidator) checkProfilingConfiguration(profilingConfiguration *ProfilingConfiguration) {
+	if !profilingConfiguration.Enabled {
+		return
+	}
+
+	configValidator.checkAddress(
+		configValidator.configLocator.tableKeyLine([]string{"ProfilingConfiguration"}, "ListenAddress"),
+		"ProfilingConfiguration.ListenAddress",
+		profilingConfiguration.ListenAddress,
+	)
+}
+
+func (configValidator *configValidator) checkRequestLoggingConfiguration(requestLoggingConfiguration *RequestLoggingConfiguration) {
+	if !requestLoggingConfiguration.Enabled {
+		return
+	}
+
+	table := []string{"RequestLoggingConfiguration"}
+
+	if requestLoggingConfiguration.RequestLogFile == "" {
+		configValidator.add(configValidator.configLocator.tableKeyLine(table, "RequestLogFile"), "RequestLoggingConfiguration.RequestLogFile", "required when enabled")
+	}
+	configValidator.checkPositive(table, "MaxSizeMegabytes", int64(requestLoggingConfiguration.MaxSizeMegabytes))
+	configValidator.checkNotNegative(table, "MaxBackups", int64(requestLoggingConfiguration.MaxBackups))
+}
+
+// locateIDs returns the line of each element's id assignment, searching from line from.
+func (configValidator *configValidator) locateIDs(
+	ids []string,
+	from int,
+) []int {
+	configLocator := configValidator.configLocator
+
+	idToOccurrences := make(map[string]int)
+	lines := make([]int, len(ids))
+
+	for i, id := range ids {
+		lines[i] = configLocator.assignmentLine(idPattern(id), from, len(configLocator.lines)+1, idToOccurrences[id])
+		idToOccurrences[id]++
+	}
+
+	return lines
+}
+
+func (configValidator *configValidator) checkIDs(
+	kind string,
+	pathPrefix string,
+	ids []string,
+	lines []int,
+) {
+	idToFirstLine := make(map[string]int)
+
+	for i, id := range ids {
+		path := fmt.Sprintf("%s[%d]", pathPrefix, i)
+
+		if id == "" {
+			configValidator.add(lines[i], path+".ID", "%s ID required", kind)
+			continue
+		}
+
+		if firstLine, ok := idToFirstLine[id]; ok {
+			var detail string
+			if firstLine != 0 {
+				detail = fmt.Sprintf(", first defined on line %d", firstLine)
+			}
+			configValidator.addWithDetail(lines[i], path+".ID", fmt.Sprintf("duplicate %s ID %q", kind, id), detail)
+			continue
+		}
+
+		idToFirstLine[id] = lines[i]
+	}
+}
+
+// commandKeyLine returns the line of key in the i'th command, or of the command's id if key is not set.
+func (configValidator *configValidator) commandKeyLine(
+	i int,
+	key string,
+) int {
+	from := configValidator.commandLines[i]
+	if from == 0 {
+		return 0
+	}
+
+	to := len(configValidator.configLocator.lines) + 1
+	for _, line := range configValidator.commandLines[i+1:] {
+		if line > from {
+			to = line
+			break
+		}
+	}
+
+	return firstNonZero(
+		configValidator.configLocator.keyLine(key, from, to, 0),
+		from,
+	)
+}
+
+func (configValidator *configValidator) checkCommandInfo(
+	i int,
+	commandInfo *CommandInfo,
+) {
+	path := fmt.Sprintf("CommandConfiguration.Commands[%s]", commandInfo.ID)
+
+	if slices.Contains(ReservedCommandIDs, commandInfo.ID) {
+		configValidator.add(configValidator.commandLines[i], path+".ID", "command ID %q is reserved", commandInfo.ID)
+	}
+
+	if configValidator.checkCommandPaths {
+		if _, err := exec.LookPath(commandInfo.Command); err != nil {
+			configValidator.add(configValidator.commandKeyLine(i, "Command"), path+".Command", "%v", err)
+		}
+	}
+
+	for _, override := range []struct {
+		key   string
+		value int64
+	}{
+		{key: "MaxConcurrentCommands", value: commandInfo.MaxConcurrentCommands},
+		{key: "MaxOutputBytes", value: commandInfo.MaxOutputBytes},
+		{key: "RequestTimeoutDuration", value: int64(commandInfo.RequestTimeoutDuration)},
+		{key: "SemaphoreAcquireTimeoutDuration", value: int64(commandInfo.SemaphoreAcquireTimeoutDuration)},
+		{key: "CacheTTL", value: int64(commandInfo.CacheTTL)},
+	} {
+		if override.value < 0 {
+			configValidator.add(configValidator.commandKeyLine(i, override.key), path+"."+override.key, "must not be negative")
+		}
+	}
+}
+
+func (configValidator *configValidator) checkCommandConfiguration(commandConfiguration *CommandConfiguration) {
+	configLocator := configValidator.configLocator
+	table := []string{"CommandConfiguration"}
+
+	configValidator.checkPositive(table, "MaxConcurrentCommands", commandConfiguration.MaxConcurrentCommands)
+	configValidator.checkNotNegative(table, "MaxOutputBytes", commandConfiguration.MaxOutputBytes)
+	configValidator.checkPositiveDuration(table, "RequestTimeoutDuration", commandConfiguration.RequestTimeoutDuration)
+	configValidator.checkPositiveDuration(table, "SemaphoreAcquireTimeoutDuration", commandConfiguration.SemaphoreAcquireTimeoutDuration)
+
+	jobTable := []string{"CommandConfiguration", "JobConfiguration"}
+	configValidator.checkNotNegative(jobTable, "MaxJobs", int64(commandConfiguration.JobConfiguration.MaxJobs))
+	configValidator.checkNotNegative(jobTable, "JobTimeoutDuration", int64(commandConfiguration.JobConfiguration.JobTimeoutDuration))
+	configValidator.checkNotNegative(jobTable, "JobTTLDuration", int64(commandConfiguration.JobConfiguration.JobTTLDuration))
+
+	executionTable := []string{"CommandConfiguration", "ExecutionConfiguration"}
+	configValidator.checkNotNegative(executionTable, "KillGracePeriodDuration", int64(commandConfiguration.ExecutionConfiguration.KillGracePeriodDuration))
+
+	admissionTable := []string{"CommandConfiguration", "AdmissionConfiguration"}
+	admissionConfiguration := &commandConfiguration.AdmissionConfiguration
+	configValidator.checkNotNegative(admissionTable, "ReservedInternalCommands", admissionConfiguration.ReservedInternalCommands)
+	configValidator.checkNotNegative(admissionTable, "InternalWeight", int64(admissionConfiguration.InternalWeight))
+	configValidator.checkNotNegative(admissionTable, "ExternalWeight", int64(admissionConfiguration.ExternalWeight))
+	configValidator.checkNotNegative(admissionTable, "MaxInternalQueueLength", int64(admissionConfiguration.MaxInternalQueueLength))
+	configValidator.checkNotNegative(admissionTable, "MaxExternalQueueLength", int64(admissionConfiguration.MaxExternalQueueLength))
+	if commandConfiguration.MaxConcurrentCommands > 0 &&
+		admissionConfiguration.ReservedInternalCommands >= commandConfiguration.MaxConcurrentCommands {
+		configValidator.add(
+			configLocator.tableKeyLine(admissionTable, "ReservedInternalCommands"),
+			"CommandConfiguration.AdmissionConfiguration.ReservedInternalCommands",
+			"must be less than MaxConcurrentCommands %d", commandConfiguration.MaxConcurrentCommands,
+		)
+	}
+
+	commandIDs := make([]string, 0, len(commandConfiguration.Commands))
+	for _, commandInfo := range commandConfiguration.Commands {
+		commandIDs = append(commandIDs, commandInfo.ID)
+	}
+	commandsLine := firstNonZero(
+		configLocator.keyLine("Commands", configLocator.tableLine(table...), len(configLocator.lines)+1, 0),
+		configLocator.tableLine("CommandConfiguration", "Commands"),
+	)
+	configValidator.commandLines = configValidator.locateIDs(commandIDs, commandsLine)
+	configValidator.checkIDs("command", "CommandConfiguration.Commands", commandIDs, configValidator.commandLines)
+
+	for i := range commandConfiguration.Commands {
+		configValidator.checkCommandInfo(i, &commandConfiguration.Commands[i])
+	}
+
+	compositeIDs := make([]string, 0, len(commandConfiguration.CompositeCommands))
+	for _, compositeCommandInfo := range commandConfiguration.CompositeCommands {
+		compositeIDs = append(compositeIDs, compositeCommandInfo.ID)
+	}
+	compositesLine := firstNonZero(
+		configLocator.keyLine("CompositeCommands", configLocator.tableLine(table...), len(configLocator.lines)+1, 0),
+		configLocator.tableLine("CommandConfiguration", "CompositeCommands"),
+	)
+	configValidator.compositeLines = configValidator.locateIDs(compositeIDs, compositesLine)
+	configValidator.checkIDs("composite command", "CommandConfiguration.CompositeCommands", compositeIDs, configValidator.compositeLines)
+}
+
+func newConfigValidator(
+	configFile string,
+	data []byte,
+) *configValidator {
+	return &configValidator{
+		configFile:    configFile,
+		configLocator: newConfigLocator(data),
+	}
+}
+
+func (configValidator *configValidator) checkConfiguration(configuration *Configuration) {
+	configValidator.checkServerConfiguration(&configuration.ServerConfiguration)
+	configValidator.checkProfilingConfiguration(&configuration.ProfilingConfiguration)
+	configValidator.checkRequestLoggingConfiguration(&configuration.RequestLoggingConfiguration)
+	configValidator.checkCommandConfiguration(&configuration.CommandConfiguration)
+}
+
+// EnvironmentDiagnosticFile is the File of problems set by EnvironmentPrefix variables.
+const EnvironmentDiagnosticFile = "environment"
+
+// ValidateLayeredConfiguration reads configFile and the layers above it like ReadLayeredConfiguration
+// and returns every problem found, including commands not found in PATH.  Each file is checked for unknown keys and the configuration is
+// checked as merged after each layer.  A problem of the final configuration is reported in the
+// first layer from which on it is present, so a value set in conf.d is reported at its line there.
+// The configuration is nil if a layer could not be read or decoded.
+// Checks needing the handlers, such as command parameters and parsers, are not done here.
+func ValidateLayeredConfiguration(
+	configFile string,
+	environ []string,
+) (*LayeredConfiguration, []Diagnostic) {
+	return validateLayeredConfiguration(configFile, environ, true)
+}
+
+func validateLayeredConfiguration(
+	configFile string,
+	environ []string,
+	checkCommandPaths bool,
+) (*LayeredConfiguration, []Diagnostic) {
+	var diagnostics []Diagnostic
+	var diagnosticFiles []string
+	var layerDiagnostics [][]Diagnostic
+
+	layeredConfiguration, err := readLayeredConfiguration(configFile, environ, func(layer *layer, configuration *Configuration) {
+		configValidator := newConfigValidator(EnvironmentDiagnosticFile, nil)
+		if layer != nil {
+			configValidator = newConfigValidator(layer.file.Path, layer.data)
+			configValidator.checkUndecodedKeys(layer.undecodedKeys)
+			diagnostics = append(diagnostics, configValidator.diagnostics...)
+			configValidator.diagnostics = nil
+		}
+
+		configValidator.checkCommandPaths = checkCommandPaths
+		configValidator.checkConfiguration(configuration)
+
+		diagnosticFiles = append(diagnosticFiles, configValidator.configFile)
+		layerDiagnostics = append(layerDiagnostics, configValidator.diagnostics)
+	})
+	if err != nil {
+		var layerDecodeError *layerDecodeError
+		if errors.As(err, &layerDecodeError) {
+			configValidator := newConfigValidator(layerDecodeError.path, nil)
+			configValidator.addDecodeError(layerDecodeError.err)
+			return nil, configValidator.diagnostics
+		}
+		return nil, []Diagnostic{{File: configFile, Message: err.Error()}}
+	}
+
+	sameProblem := func(a Diagnostic) func(Diagnostic) bool {
+		return func(b Diagnostic) bool {
+			return a.Path == b.Path && a.problem == b.problem
+		}
+	}
+
+	last := len(layerDiagnostics) - 1
+	for _, diagnostic := range layerDiagnostics[last] {
+		first := last
+		for first > 0 && slices.ContainsFunc(layerDiagnostics[first-1], sameProblem(diagnostic)) {
+			first--
+		}
+
+		diagnostics = append(diagnostics, layerDiagnostics[first][slices.IndexFunc(layerDiagnostics[first], sameProblem(diagnostic))])
+	}
+
+	slices.SortStableFunc(diagnostics, func(a, b Diagnostic) int {
+		return cmp.Or(
+			cmp.Compare(slices.Index(diagnosticFiles, a.File), slices.Index(diagnosticFiles, b.File)),
+			a.Line-b.Line,
+		)
+	})
+
+	return layeredConfiguration, diagnostics
+}
@@ -0,0 +1,317 @@
+package config
+
+import (
+	"cmp"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const validConfig = `
+[serverConfiguration]
+listeners = [
+  { network = "tcp", listenAddress = ":8080" },
+]
+apiContext = "/api/v1"
+
+[commandConfiguration]
+maxConcurrentCommands = 10
+requestTimeoutDuration = "2s"
+semaphoreAcquireTimeoutDuration = "200ms"
+maxOutputBytes = 1048576
+
+[commandConfiguration.jobConfiguration]
+maxJobs = 10
+jobTimeoutDuration = "1m"
+jobTTLDuration = "10m"
+
+[[commandConfiguration.commands]]
+id = "true"
+command = "true"
+`
+
+func writeConfig(
+	t *testing.T,
+	content string,
+) string {
+	t.Helper()
+
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(configFile, []byte(content), 0o600); err != nil {
+		t.Fatalf("os.WriteFile error: %v", err)
+	}
+	return configFile
+}
+
+func TestValidateLayeredConfigurationValid(t *testing.T) {
+	configuration, diagnostics := ValidateLayeredConfiguration(writeConfig(t, validConfig), nil)
+
+	if configuration == nil {
+		t.Fatalf("configuration = nil")
+	}
+	if len(diagnostics) != 0 {
+		t.Fatalf("diagnostics = %v, want none", diagnostics)
+	}
+}
+
+func TestValidateLayeredConfigurationProblems(t *testing.T) {
+	tests := map[string]struct {
+		replace     string
+		with        string
+		wantLine    int
+		wantPath    string
+		wantMessage string
+	}{
+		"unknown key": {
+			replace:     `apiContext = "/api/v1"`,
+			with:        `apiContext = "/api/v1"` + "\nbogus = 1",
+			wantLine:    7,
+			wantPath:    "serverConfiguration.bogus",
+			wantMessage: "unknown key",
+		},
+		"unknown key in command": {
+			replace:     `command = "true"`,
+			with:        `command = "true"` + "\nbogus = 1",
+			wantLine:    22,
+			wantPath:    "commandConfiguration.commands.bogus",
+			wantMessage: "unknown key",
+		},
+		"bad network": {
+			replace:     `network = "tcp"`,
+			with:        `network = "udp"`,
+			wantLine:    4,
+			wantPath:    "ServerConfiguration.Listeners[0].Network",
+			wantMessage: `unknown network "udp"`,
+		},
+		"bad address": {
+			replace:     `":8080"`,
+			with:        `":80800"`,
+			wantLine:    4,
+			wantPath:    "ServerConfiguration.Listeners[0].ListenAddress",
+			wantMessage: `invalid port "80800"`,
+		},
+		"http2 frame size out of range": {
+			replace:     `listenAddress = ":8080" }`,
+			with:        `listenAddress = ":8080", limits = { http2 = { maxReadFrameSize = 1024 } } }`,
+			wantLine:    4,
+			wantPath:    "ServerConfiguration.Listeners[0].Limits.HTTP2.MaxReadFrameSize",
+			wantMessage: "must be between 16384 and 16777216, got 1024",
+		},
+		"negative listener timeout": {
+			replace:     `listenAddress = ":8080" }`,
+			with:        `listenAddress = ":8080", limits = { idleTimeoutDuration = "-1m" } }`,
+			wantLine:    4,
+			wantPath:    "ServerConfiguration.Listeners[0].Limits.IdleTimeoutDuration",
+			wantMessage: "must not be negative",
+		},
+		"duplicate systemd socket": {
+			replace:     `{ network = "tcp", listenAddress = ":8080" },`,
+			with:        "{ network = \"systemd\", listenAddress = \"http\" },\n  { network = \"systemd\", listenAddress = \"http\" },",
+			wantLine:    5,
+			wantPath:    "ServerConfiguration.Listeners[1].ListenAddress",
+			wantMessage: `systemd socket "http" is already used`,
+		},
+		"tls without key file": {
+			replace:     `listenAddress = ":8080" }`,
+			with:        `listenAddress = ":8443", tls = { certFile = "cert.pem" } }`,
+			wantLine:    4,
+			wantPath:    "ServerConfiguration.Listeners[0].TLS",
+			wantMessage: "CertFile and KeyFile are required",
+		},
+		"api context collides with health": {
+			replace:     `"/api/v1"`,
+			with:        `"/health/"`,
+			wantLine:    6,
+			wantPath:    "ServerConfiguration.APIContext",
+			wantMessage: "collides with /health",
+		},
+		"negative shutdown timeout": {
+			replace:     `apiContext = "/api/v1"`,
+			with:        `apiContext = "/api/v1"` + "\nshutdownTimeoutDuration = \"-1s\"",
+			wantLine:    7,
+			wantPath:    "ServerConfiguration.ShutdownTimeoutDuration",
+			wantMessage: "must not be negative",
+		},
+		"negative drain delay": {
+			replace:     `apiContext = "/api/v1"`,
+			with:        `apiContext = "/api/v1"` + "\ndrainDelayDuration = \"-5s\"",
+			wantLine:    7,
+			wantPath:    "ServerConfiguration.DrainDelayDuration",
+			wantMessage: "must not be negative",
+		},
+		"non positive limit": {
+			replace:     `maxConcurrentCommands = 10`,
+			with:        `maxConcurrentCommands = 0`,
+			wantLine:    9,
+			wantPath:    "CommandConfiguration.MaxConcurrentCommands",
+			wantMessage: "must be positive",
+		},
+		"negative duration": {
+			replace:     `jobTTLDuration = "10m"`,
+			with:        `jobTTLDuration = "-10m"`,
+			wantLine:    17,
+			wantPath:    "CommandConfiguration.JobConfiguration.JobTTLDuration",
+			wantMessage: "must not be negative",
+		},
+		"missing command": {
+			replace:     `command = "true"`,
+			with:        `command = "/nonexistent/true"`,
+			wantLine:    21,
+			wantPath:    "CommandConfiguration.Commands[true].Command",
+			wantMessage: "no such file or directory",
+		},
+		"duplicate command ID": {
+			replace:     `command = "true"`,
+			with:        `command = "true"` + "\n\n[[commandConfiguration.commands]]\nid = \"true\"\ncommand = \"true\"",
+			wantLine:    24,
+			wantPath:    "CommandConfiguration.Commands[1].ID",
+			wantMessage: `duplicate command ID "true", first defined on line 20`,
+		},
+		"reserved command ID": {
+			replace:     `id = "true"`,
+			with:        `id = "stats"`,
+			wantLine:    20,
+			wantPath:    "CommandConfiguration.Commands[stats].ID",
+			wantMessage: `command ID "stats" is reserved`,
+		},
+		"decode error": {
+			replace:     `maxJobs = 10`,
+			with:        `maxJobs = "ten"`,
+			wantLine:    15,
+			wantMessage: "maxJobs",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			content := strings.Replace(validConfig, test.replace, test.with, 1)
+			configFile := writeConfig(t, content)
+
+			_, diagnostics := ValidateLayeredConfiguration(configFile, nil)
+
+			if !slices.ContainsFunc(diagnostics, func(diagnostic Diagnostic) bool {
+				return diagnostic.File == configFile &&
+					diagnostic.Line == test.wantLine &&
+					diagnostic.Path == test.wantPath &&
+					strings.Contains(diagnostic.Message, test.wantMessage)
+			}) {
+				t.Fatalf("diagnostics = %v, want line %d path %q message containing %q",
+					diagnostics, test.wantLine, test.wantPath, test.wantMessage)
+			}
+		})
+	}
+}
+
+func TestDiagnosticString(t *testing.T) {
+	tests := map[string]struct {
+		diagnostic Diagnostic
+		want       string
+	}{
+		"located": {
+			diagnostic: Diagnostic{File: "c.toml", Line: 3, Path: "A.B", Message: "bad"},
+			want:       "c.toml:3: A.B: bad",
+		},
+		"no location": {
+			diagnostic: Diagnostic{File: "c.toml", Message: "bad"},
+			want:       "c.toml: bad",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := test.diagnostic.String(); got != test.want {
+				t.Fatalf("String() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateLayeredConfiguration(t *testing.T) {
+	tests := map[string]struct {
+		configFile  string
+		confD       string
+		environ     []string
+		wantFile    string
+		wantLine    int
+		wantPath    string
+		wantMessage string
+	}{
+		"valid": {
+			confD: "[commandConfiguration]\nmaxConcurrentCommands = 5\n",
+		},
+		"layer fixes config file": {
+			configFile: strings.Replace(validConfig, `":8080"`, `":80800"`, 1),
+			confD:      "[serverConfiguration]\nlisteners = [{ network = \"tcp\", listenAddress = \":9090\" }]\n",
+		},
+		"invalid value in layer": {
+			confD:       "[commandConfiguration]\n\nmaxConcurrentCommands = 0\n",
+			wantFile:    "conf.d/10-host.toml",
+			wantLine:    3,
+			wantPath:    "CommandConfiguration.MaxConcurrentCommands",
+			wantMessage: "must be positive",
+		},
+		"unknown key in layer": {
+			confD:       "[commandConfiguration]\nbogus = 1\n",
+			wantFile:    "conf.d/10-host.toml",
+			wantLine:    2,
+			wantPath:    "commandConfiguration.bogus",
+			wantMessage: "unknown key",
+		},
+		"decode error in layer": {
+			confD:       "[commandConfiguration]\nmaxConcurrentCommands = \"ten\"\n",
+			wantFile:    "conf.d/10-host.toml",
+			wantLine:    2,
+			wantMessage: "maxConcurrentCommands",
+		},
+		"duplicate command ID": {
+			configFile:  validConfig + "\n[[commandConfiguration.commands]]\nid = \"true\"\ncommand = \"true\"\n",
+			wantFile:    "config.toml",
+			wantLine:    24,
+			wantPath:    "CommandConfiguration.Commands[1].ID",
+			wantMessage: `duplicate command ID "true", first defined on line 20`,
+		},
+		"invalid environment value": {
+			environ:     []string{"GOAPI_COMMANDCONFIGURATION_MAXCONCURRENTCOMMANDS=0"},
+			wantFile:    EnvironmentDiagnosticFile,
+			wantPath:    "CommandConfiguration.MaxConcurrentCommands",
+			wantMessage: "must be positive",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			nameToContent := map[string]string{
+				"config.toml": cmp.Or(test.configFile, validConfig),
+			}
+			if test.confD != "" {
+				nameToContent["conf.d/10-host.toml"] = test.confD
+			}
+			configFile := writeLayers(t, nameToContent)
+
+			wantFile := test.wantFile
+			if wantFile != EnvironmentDiagnosticFile {
+				wantFile = filepath.Join(filepath.Dir(configFile), test.wantFile)
+			}
+
+			_, diagnostics := ValidateLayeredConfiguration(configFile, test.environ)
+
+			if test.wantMessage == "" {
+				if len(diagnostics) != 0 {
+					t.Fatalf("diagnostics = %v, want none", diagnostics)
+				}
+				return
+			}
+
+			if len(diagnostics) != 1 ||
+				diagnostics[0].File != wantFile ||
+				diagnostics[0].Line != test.wantLine ||
+				diagnostics[0].Path != test.wantPath ||
+				!strings.Contains(diagnostics[0].Message, test.wantMessage) {
+				t.Fatalf("diagnostics = %v, want %s:%d path %q message containing %q",
+					diagnostics, wantFile, test.wantLine, test.wantPath, test.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package command
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/aaronriekenberg/go-api/config"
+	"github.com/aaronriekenberg/go-api/request"
+)
+
+var errorAdmissionQueueFull = errors.New("admission queue full")
+
+// admissionClass is the priority class a command run is admitted under.
+type admissionClass string
+
+const (
+	admissionClassInternal admissionClass = "internal"
+	admissionClassExternal admissionClass = "external"
+)
+
+// admissionClasses is in order of preference when classes are otherwise equal.
+var admissionClasses = []admissionClass{
+	admissionClassInternal,
+	admissionClassExternal,
+}
+
+func admissionClassForRequest(
+	requestIsExternal request.IsExternal,
+	r *http.Request,
+) admissionClass {
+	if requestIsExternal(r) {
+		return admissionClassExternal
+	}
+	return admissionClassInternal
+}
+
+type admissionClassState struct {
+	maxConcurrentCommands int64
+	weight                int
+	maxQueueLength        int
+	inUse                 int64
+	// queue holds the ready channel of each waiting acquire, oldest first.
+	queue list.List
+	// pass is the class's virtual time, advanced by 1/weight per admission from the queue.
+	pass                float64
+	admitted            int64
+	queueFullRejections int64
+	timeoutRejections   int64
+}
+
+// admissionController is the global concurrency limit, shared between admission classes.
+// Internal requests may use every slot, external requests all but the reserved ones.
+// When a slot is freed it goes to the waiting class with the lowest pass (stride scheduling),
+// so backlogged classes share slots in proportion to their weights.
+type admissionController struct {
+	mutex                  sync.Mutex
+	admissionConfiguration config.AdmissionConfiguration
+	maxConcurrentCommands  int64
+	inUse                  int64
+	virtualTime            float64
+	classToState           map[admissionClass]*admissionClassState
+}
+
+func newAdmissionController(
+	maxConcurrentCommands int64,
+	admissionConfiguration config.AdmissionConfiguration,
+) (*admissionController, error) {
+	if maxConcurrentCommands <= 0 {
+		return nil, fmt.Errorf("MaxConcurrentCommands %d must be positive", maxConcurrentCommands)
+	}
+
+	reserved := admissionConfiguration.ReservedInternalCommands
+	if reserved < 0 || reserved >= maxConcurrentCommands {
+		return nil, fmt.Errorf("ReservedInternalCommands %d must be at least 0 and less than MaxConcurrentCommands %d", reserved, maxConcurrentCommands)
+	}
+
+	newClassState := func(
+		class admissionClass,
+		maxConcurrentCommands int64,
+		weight int,
+		maxQueueLength int,
+	) (*admissionClassState, error) {
+		if weight < 0 {
+			return nil, fmt.Errorf("%s weight %d must not be negative", class, weight)
+		}
+		if maxQueueLength < 0 {
+			return nil, fmt.Errorf("%s max queue length %d must not be negative", class, maxQueueLength)
+		}
+		if weight == 0 {
+			weight = 1
+		}
+		return &admissionClassState{
+			maxConcurrentCommands: maxConcurrentCommands,
+			weight:                weight,
+			maxQueueLength:        maxQueueLength,
+		}, nil
+	}
+
+	internalState, err := newClassState(
+		admissionClassInternal,
+		maxConcurrentCommands,
+		admissionConfiguration.InternalWeight,
+		admissionConfiguration.MaxInternalQueueLength,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	externalState, err := newClassState(
+		admissionClassExternal,
+		maxConcurrentCommands-reserved,
+		admissionConfiguration.ExternalWeight,
+		admissionConfiguration.MaxExternalQueueLength,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &admissionController{
+		admissionConfiguration: admissionConfiguration,
+		maxConcurrentCommands:  maxConcurrentCommands,
+		classToState: map[admissionClass]*admissionClassState{
+			admissionClassInternal: internalState,
+			admissionClassExternal: externalState,
+		},
+	}, nil
+}
+
+func (admissionController *admissionController) hasConfiguration(
+	maxConcurrentCommands int64,
+	admissionConfiguration config.AdmissionConfiguration,
+) bool {
+	return admissionController.maxConcurrentCommands == maxConcurrentCommands &&
+		admissionController.admissionConfiguration == admissionConfiguration
+}
+
+func (admissionController *admissionController) canAdmitLocked(classState *admissionClassState) bool {
+	return admissionController.inUse < admissionController.maxConcurrentCommands &&
+		classState.inUse < classState.maxConcurrentCommands
+}
+
+func (admissionController *admissionController) admitLocked(classState *admissionClassState) {
+	admissionController.inUse++
+	classState.inUse++
+	classState.admitted++
+}
+
+// dispatchLocked admits waiters while there is a class with waiters and a slot it may use.
+func (admissionController *admissionController) dispatchLocked() {
+	for {
+		var nextClassState *admissionClassState
+		for _, class := range admissionClasses {
+			classState := admissionController.classToState[class]
+			if classState.queue.Len() == 0 || !admissionController.canAdmitLocked(classState) {
+				continue
+			}
+			if nextClassState == nil || classState.pass < nextClassState.pass {
+				nextClassState = classState
+			}
+		}
+
+		if nextClassState == nil {
+			return
+		}
+
+		ready := nextClassState.queue.Remove(nextClassState.queue.Front()).(chan struct{})
+
+		admissionController.admitLocked(nextClassState)
+		admissionController.virtualTime = nextClassState.pass
+		nextClassState.pass += 1 / float64(nextClassState.weight)
+
+		close(ready)
+	}
+}
+
+func (admissionController *admissionController) releaseLocked(classState *admissionClassState) {
+	admissionController.inUse--
+	classState.inUse--
+	admissionController.dispatchLocked()
+}
+
+// acquire waits for a slot for class until ctx is done.
+// It fails at once if the class's queue is full.
+func (admissionController *admissionController) acquire(
+	ctx context.Context,
+	class admissionClass,
+) error {
+	admissionController.mutex.Lock()
+
+	classState := admissionController.classToState[class]
+
+	// free slots are only left when no waiter may use them, so an empty queue can go first
+	if classState.queue.Len() == 0 && admissionController.canAdmitLocked(classState) {
+		admissionController.admitLocked(classState)
+		admissionController.mutex.Unlock()
+		return nil
+	}
+
+	if classState.maxQueueLength > 0 && classState.queue.Len() >= classState.maxQueueLength {
+		classState.queueFullRejections++
+		admissionController.mutex.Unlock()
+		return fmt.Errorf("%w: %s", errorAdmissionQueueFull, class)
+	}
+
+	// a class that was idle starts from the current virtual time, it gets no credit for being idle
+	if classState.queue.Len() == 0 {
+		classState.pass = max(classState.pass, admissionController.virtualTime)
+	}
+
+	ready := make(chan struct{})
+	element := classState.queue.PushBack(ready)
+
+	admissionController.mutex.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+
+	case <-ctx.Done():
+		admissionController.mutex.Lock()
+		defer admissionController.mutex.Unlock()
+
+		select {
+		case <-ready:
+			// admitted after ctx was done, give the slot back
+			classState.admitted--
+			admissionController.releaseLocked(classState)
+		default:
+			classState.queue.Remove(element)
+		}
+
+		classState.timeoutRejections++
+		return ctx.Err()
+	}
+}
+
+func (admissionController *admissionController) release(class admissionClass) {
+	admissionController.mutex.Lock()
+	defer admissionController.mutex.Unlock()
+
+	admissionController.releaseLocked(admissionController.classToState[class])
+}
+
+type admissionClassDTO struct {
+	Class                 admissionClass `json:"class"`
+	MaxConcurrentCommands int64          `json:"max_concurrent_commands"`
+	Weight                int            `json:"weight"`
+	InUse                 int64          `json:"in_use"`
+	QueueDepth            int            `json:"queue_depth"`
+	MaxQueueLength        int            `json:"max_queue_length"`
+	Admitted              int64          `json:"admitted"`
+	QueueFullRejections   int64          `json:"queue_full_rejections"`
+	TimeoutRejections     int64          `json:"timeout_rejections"`
+}
+
+func (admissionController *admissionController) toDTO() (*concurrencyLimitDTO, []admissionClassDTO) {
+	admissionController.mutex.Lock()
+	defer admissionController.mutex.Unlock()
+
+	classDTOs := make([]admissionClassDTO, 0, len(admissionClasses))
+	for _, class := range admissionClasses {
+		classState := admissionController.classToState[class]
+		classDTOs = append(classDTOs, admissionClassDTO{
+			Class:                 class,
+			MaxConcurrentCommands: classState.maxConcurrentCommands,
+			Weight:                classState.weight,
+			InUse:                 classState.inUse,
+			QueueDepth:            classState.queue.Len(),
+			MaxQueueLength:        classState.maxQueueLength,
+			Admitted:              classState.admitted,
+			QueueFullRejections:   classState.queueFullRejections,
+			TimeoutRejections:     classState.timeoutRejections,
+		})
+	}
+
+	return &concurrencyLimitDTO{
+		MaxConcurrentCommands: admissionController.maxConcurrentCommands,
+		InUse:                 admissionController.inUse,
+	}, classDTOs
+}
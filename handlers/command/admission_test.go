@@ -0,0 +1,212 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aaronriekenberg/go-api/config"
+)
+
+func TestNewAdmissionControllerErrors(t *testing.T) {
+	tests := map[string]struct {
+		maxConcurrentCommands  int64
+		admissionConfiguration config.AdmissionConfiguration
+	}{
+		"zero max":              {maxConcurrentCommands: 0},
+		"reserved all":          {maxConcurrentCommands: 2, admissionConfiguration: config.AdmissionConfiguration{ReservedInternalCommands: 2}},
+		"negative reserved":     {maxConcurrentCommands: 2, admissionConfiguration: config.AdmissionConfiguration{ReservedInternalCommands: -1}},
+		"negative weight":       {maxConcurrentCommands: 2, admissionConfiguration: config.AdmissionConfiguration{ExternalWeight: -1}},
+		"negative queue length": {maxConcurrentCommands: 2, admissionConfiguration: config.AdmissionConfiguration{MaxInternalQueueLength: -1}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := newAdmissionController(tc.maxConcurrentCommands, tc.admissionConfiguration); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func mustNewAdmissionController(
+	t *testing.T,
+	maxConcurrentCommands int64,
+	admissionConfiguration config.AdmissionConfiguration,
+) *admissionController {
+	t.Helper()
+
+	admissionController, err := newAdmissionController(maxConcurrentCommands, admissionConfiguration)
+	if err != nil {
+		t.Fatalf("newAdmissionController error: %v", err)
+	}
+	return admissionController
+}
+
+func admissionClassDTOFor(
+	admissionController *admissionController,
+	class admissionClass,
+) admissionClassDTO {
+	_, classDTOs := admissionController.toDTO()
+	for _, classDTO := range classDTOs {
+		if classDTO.Class == class {
+			return classDTO
+		}
+	}
+	return admissionClassDTO{}
+}
+
+func waitForQueueDepth(
+	t *testing.T,
+	admissionController *admissionController,
+	class admissionClass,
+	queueDepth int,
+) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for admissionClassDTOFor(admissionController, class).QueueDepth != queueDepth {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s queue depth %d", class, queueDepth)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func shortContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestAdmissionReservedInternalCommands(t *testing.T) {
+	admissionController := mustNewAdmissionController(t, 2, config.AdmissionConfiguration{ReservedInternalCommands: 1})
+
+	if err := admissionController.acquire(context.Background(), admissionClassExternal); err != nil {
+		t.Fatalf("first external acquire error: %v", err)
+	}
+
+	if err := admissionController.acquire(shortContext(t), admissionClassExternal); err == nil {
+		t.Fatalf("second external acquire should fail")
+	}
+
+	if err := admissionController.acquire(shortContext(t), admissionClassInternal); err != nil {
+		t.Fatalf("internal acquire error: %v", err)
+	}
+
+	externalDTO := admissionClassDTOFor(admissionController, admissionClassExternal)
+	if externalDTO.InUse != 1 || externalDTO.Admitted != 1 || externalDTO.TimeoutRejections != 1 || externalDTO.QueueDepth != 0 {
+		t.Errorf("got external %+v", externalDTO)
+	}
+
+	admissionController.release(admissionClassInternal)
+	admissionController.release(admissionClassExternal)
+
+	if globalDTO, _ := admissionController.toDTO(); globalDTO.InUse != 0 {
+		t.Errorf("got global in use %d want 0", globalDTO.InUse)
+	}
+}
+
+func TestAdmissionQueueFull(t *testing.T) {
+	admissionController := mustNewAdmissionController(t, 1, config.AdmissionConfiguration{MaxExternalQueueLength: 1})
+
+	if err := admissionController.acquire(context.Background(), admissionClassExternal); err != nil {
+		t.Fatalf("acquire error: %v", err)
+	}
+
+	waiterDone := make(chan error)
+	go func() {
+		waiterDone <- admissionController.acquire(context.Background(), admissionClassExternal)
+	}()
+	waitForQueueDepth(t, admissionController, admissionClassExternal, 1)
+
+	if err := admissionController.acquire(context.Background(), admissionClassExternal); !errors.Is(err, errorAdmissionQueueFull) {
+		t.Fatalf("got error %v want %v", err, errorAdmissionQueueFull)
+	}
+
+	admissionController.release(admissionClassExternal)
+
+	if err := <-waiterDone; err != nil {
+		t.Fatalf("waiter acquire error: %v", err)
+	}
+
+	externalDTO := admissionClassDTOFor(admissionController, admissionClassExternal)
+	if externalDTO.InUse != 1 || externalDTO.Admitted != 2 || externalDTO.QueueFullRejections != 1 {
+		t.Errorf("got external %+v", externalDTO)
+	}
+}
+
+func TestAdmissionWeightedFairQueueing(t *testing.T) {
+	admissionController := mustNewAdmissionController(t, 1, config.AdmissionConfiguration{
+		InternalWeight: 3,
+		ExternalWeight: 1,
+	})
+
+	if err := admissionController.acquire(context.Background(), admissionClassInternal); err != nil {
+		t.Fatalf("acquire error: %v", err)
+	}
+
+	const waitersPerClass = 6
+
+	var mutex sync.Mutex
+	var admissionOrder []admissionClass
+	var waitGroup sync.WaitGroup
+
+	for i := range waitersPerClass {
+		for _, class := range admissionClasses {
+			waitGroup.Go(func() {
+				if err := admissionController.acquire(context.Background(), class); err != nil {
+					t.Errorf("acquire error: %v", err)
+					return
+				}
+
+				mutex.Lock()
+				admissionOrder = append(admissionOrder, class)
+				mutex.Unlock()
+
+				admissionController.release(class)
+			})
+			waitForQueueDepth(t, admissionController, class, i+1)
+		}
+	}
+
+	admissionController.release(admissionClassInternal)
+	waitGroup.Wait()
+
+	// both classes start at the same pass, internal then gets 3 slots for each external one
+	wantPrefix := []admissionClass{
+		admissionClassInternal, admissionClassExternal,
+		admissionClassInternal, admissionClassInternal, admissionClassInternal, admissionClassExternal,
+		admissionClassInternal, admissionClassInternal,
+	}
+
+	if !slices.Equal(admissionOrder[:len(wantPrefix)], wantPrefix) {
+		t.Fatalf("got admission order %v want prefix %v", admissionOrder, wantPrefix)
+	}
+}
+
+func TestAdmissionCancelledWaiterLeavesQueue(t *testing.T) {
+	admissionController := mustNewAdmissionController(t, 1, config.AdmissionConfiguration{})
+
+	if err := admissionController.acquire(context.Background(), admissionClassInternal); err != nil {
+		t.Fatalf("acquire error: %v", err)
+	}
+
+	if err := admissionController.acquire(shortContext(t), admissionClassInternal); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v want %v", err, context.DeadlineExceeded)
+	}
+
+	internalDTO := admissionClassDTOFor(admissionController, admissionClassInternal)
+	if internalDTO.QueueDepth != 0 || internalDTO.TimeoutRejections != 1 {
+		t.Errorf("got internal %+v", internalDTO)
+	}
+
+	admissionController.release(admissionClassInternal)
+
+	if err := admissionController.acquire(shortContext(t), admissionClassInternal); err != nil {
+		t.Fatalf("acquire after release error: %v", err)
+	}
+}
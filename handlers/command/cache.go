@@ -0,0 +1,144 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/aaronriekenberg/go-api/config"
+)
+
+const (
+	cacheStatusHeaderKey = "X-Cache-Status"
+	ageHeaderKey         = "Age"
+)
+
+type cacheStatus string
+
+const (
+	cacheStatusHit       cacheStatus = "HIT"
+	cacheStatusMiss      cacheStatus = "MISS"
+	cacheStatusCoalesced cacheStatus = "COALESCED"
+)
+
+// errorWaitingForSharedRun is returned when the caller's context ends while it waits for a shared execution.
+var errorWaitingForSharedRun = errors.New("error waiting for shared command run")
+
+type cachedResponse struct {
+	response   commandAPIResponse
+	createTime time.Time
+	expireTime time.Time
+}
+
+// commandResultCache caches responses of commands with a CacheTTL.
+// Concurrent misses for the same key share one execution.
+type commandResultCache struct {
+	mutex             sync.Mutex
+	keyToResponse     map[string]cachedResponse
+	singleflightGroup singleflight.Group
+}
+
+func newCommandResultCache() *commandResultCache {
+	return &commandResultCache{
+		keyToResponse: make(map[string]cachedResponse),
+	}
+}
+
+func commandCacheKey(commandInfo config.CommandInfo) string {
+	return commandInfo.ID + "\x00" + strings.Join(commandInfo.Args, "\x00")
+}
+
+func (commandResultCache *commandResultCache) load(
+	key string,
+	now time.Time,
+) (cachedResponse cachedResponse, ok bool) {
+	commandResultCache.mutex.Lock()
+	defer commandResultCache.mutex.Unlock()
+
+	cachedResponse, ok = commandResultCache.keyToResponse[key]
+	if ok && !now.Before(cachedResponse.expireTime) {
+		delete(commandResultCache.keyToResponse, key)
+		ok = false
+	}
+	return
+}
+
+func (commandResultCache *commandResultCache) store(
+	key string,
+	response commandAPIResponse,
+	ttl time.Duration,
+) {
+	commandResultCache.mutex.Lock()
+	defer commandResultCache.mutex.Unlock()
+
+	now := time.Now()
+
+	for cacheKey, cachedResponse := range commandResultCache.keyToResponse {
+		if !now.Before(cachedResponse.expireTime) {
+			delete(commandResultCache.keyToResponse, cacheKey)
+		}
+	}
+
+	commandResultCache.keyToResponse[key] = cachedResponse{
+		response:   response,
+		createTime: now,
+		expireTime: now.Add(ttl),
+	}
+}
+
+// getOrRun returns a cached response for commandInfo if one is younger than commandInfo.CacheTTL.
+// Otherwise it calls runCommand once for all concurrent callers and caches the result if the command succeeded,
+// so a failure is not served until the TTL expires.
+// runCommand is passed a context that is not cancelled when the caller's ctx is,
+// a caller whose ctx ends while it waits gets errorWaitingForSharedRun.
+func (commandResultCache *commandResultCache) getOrRun(
+	ctx context.Context,
+	commandInfo config.CommandInfo,
+	runCommand func(ctx context.Context) (commandAPIResponse, error),
+) (response commandAPIResponse, status cacheStatus, age time.Duration, err error) {
+	key := commandCacheKey(commandInfo)
+
+	now := time.Now()
+
+	if cachedResponse, ok := commandResultCache.load(key, now); ok {
+		age = now.Sub(cachedResponse.createTime)
+		response = cachedResponse.response
+		response.CacheAgeMilliseconds = new(age.Milliseconds())
+		status = cacheStatusHit
+		return
+	}
+
+	resultChannel := commandResultCache.singleflightGroup.DoChan(key, func() (any, error) {
+		response, err := runCommand(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		if response.ErrorKind == commandErrorKindNone {
+			commandResultCache.store(key, response, commandInfo.CacheTTL)
+		}
+
+		return response, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		err = fmt.Errorf("%w: %w", errorWaitingForSharedRun, ctx.Err())
+
+	case result := <-resultChannel:
+		err = result.Err
+		if err == nil {
+			response = result.Val.(commandAPIResponse)
+		}
+		status = cacheStatusMiss
+		if result.Shared {
+			status = cacheStatusCoalesced
+		}
+	}
+	return
+}
@@ -0,0 +1,161 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aaronriekenberg/go-api/config"
+)
+
+// joinedContext calls joined the first time Done is called,
+// which getOrRun does after joining the execution.
+type joinedContext struct {
+	context.Context
+	joinedOnce sync.Once
+	joined     func()
+}
+
+func (joinedContext *joinedContext) Done() <-chan struct{} {
+	joinedContext.joinedOnce.Do(joinedContext.joined)
+	return joinedContext.Context.Done()
+}
+
+func TestCommandResultCacheCoalescesAndCaches(t *testing.T) {
+	commandResultCache := newCommandResultCache()
+
+	commandInfo := config.CommandInfo{
+		ID:       "test",
+		CacheTTL: time.Hour,
+	}
+
+	var runs atomic.Int64
+	release := make(chan struct{})
+
+	runCommand := func(ctx context.Context) (commandAPIResponse, error) {
+		runs.Add(1)
+		<-release
+		return commandAPIResponse{CommandOutput: "output"}, nil
+	}
+
+	const callers = 5
+
+	var joinedWaitGroup sync.WaitGroup
+	joinedWaitGroup.Add(callers)
+
+	var waitGroup sync.WaitGroup
+	for range callers {
+		waitGroup.Go(func() {
+			ctx := &joinedContext{
+				Context: context.Background(),
+				joined:  joinedWaitGroup.Done,
+			}
+
+			response, status, _, err := commandResultCache.getOrRun(ctx, commandInfo, runCommand)
+			if err != nil {
+				t.Errorf("getOrRun error: %v", err)
+			}
+			if response.CommandOutput != "output" {
+				t.Errorf("got output %q", response.CommandOutput)
+			}
+			if status == cacheStatusHit {
+				t.Errorf("concurrent miss got status %q", status)
+			}
+		})
+	}
+
+	// all callers join the in-flight execution before it completes
+	joinedWaitGroup.Wait()
+	close(release)
+	waitGroup.Wait()
+
+	if got := runs.Load(); got != 1 {
+		t.Fatalf("got %d runs want 1", got)
+	}
+
+	response, status, _, err := commandResultCache.getOrRun(context.Background(), commandInfo, runCommand)
+	if err != nil {
+		t.Fatalf("getOrRun error: %v", err)
+	}
+	if status != cacheStatusHit {
+		t.Fatalf("got status %q want %q", status, cacheStatusHit)
+	}
+	if response.CacheAgeMilliseconds == nil {
+		t.Fatalf("cache hit should set CacheAgeMilliseconds")
+	}
+	if got := runs.Load(); got != 1 {
+		t.Fatalf("got %d runs want 1", got)
+	}
+}
+
+func TestCommandResultCacheWaitTimeout(t *testing.T) {
+	commandResultCache := newCommandResultCache()
+
+	commandInfo := config.CommandInfo{
+		ID:       "test",
+		CacheTTL: time.Hour,
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+
+	runCommand := func(ctx context.Context) (commandAPIResponse, error) {
+		<-release
+		return commandAPIResponse{}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	joinedCtx := &joinedContext{
+		Context: ctx,
+		joined:  cancel,
+	}
+
+	_, _, _, err := commandResultCache.getOrRun(joinedCtx, commandInfo, runCommand)
+	if !errors.Is(err, errorWaitingForSharedRun) {
+		t.Fatalf("got error %v want %v", err, errorWaitingForSharedRun)
+	}
+}
+
+func TestCommandResultCacheDoesNotCacheFailure(t *testing.T) {
+	commandResultCache := newCommandResultCache()
+
+	commandInfo := config.CommandInfo{
+		ID:       "test",
+		CacheTTL: time.Hour,
+	}
+
+	var runs atomic.Int64
+
+	runCommand := func(ctx context.Context) (commandAPIResponse, error) {
+		if runs.Add(1) == 1 {
+			return commandAPIResponse{ErrorKind: commandErrorKindNonZeroExit}, nil
+		}
+		return commandAPIResponse{CommandOutput: "output"}, nil
+	}
+
+	response, status, _, err := commandResultCache.getOrRun(context.Background(), commandInfo, runCommand)
+	if err != nil {
+		t.Fatalf("getOrRun error: %v", err)
+	}
+	if response.ErrorKind != commandErrorKindNonZeroExit || status != cacheStatusMiss {
+		t.Fatalf("got error kind %q status %q want %q %q", response.ErrorKind, status, commandErrorKindNonZeroExit, cacheStatusMiss)
+	}
+
+	response, status, _, err = commandResultCache.getOrRun(context.Background(), commandInfo, runCommand)
+	if err != nil {
+		t.Fatalf("getOrRun error: %v", err)
+	}
+	if response.CommandOutput != "output" || status != cacheStatusMiss {
+		t.Fatalf("got output %q status %q, the failed run should not be cached", response.CommandOutput, status)
+	}
+
+	if _, status, _, _ := commandResultCache.getOrRun(context.Background(), commandInfo, runCommand); status != cacheStatusHit {
+		t.Fatalf("got status %q want %q", status, cacheStatusHit)
+	}
+	if got := runs.Load(); got != 2 {
+		t.Fatalf("got %d runs want 2", got)
+	}
+}
@@ -1,47 +1,75 @@
 package command
 
 import (
+	"cmp"
 	"context"
 	"errors"
-	"fmt"
 	"log/slog"
 	"net/http"
-	"os/exec"
 	"slices"
+	"strconv"
 	"time"
 
-	"golang.org/x/sync/semaphore"
-
 	"github.com/aaronriekenberg/go-api/config"
 	"github.com/aaronriekenberg/go-api/request"
 	"github.com/aaronriekenberg/go-api/utils"
 )
 
 type commandInfoDTO struct {
-	ID          string   `json:"id"`
-	Description string   `json:"description"`
-	Command     string   `json:"command"`
-	Args        []string `json:"args"`
+	ID          string                `json:"id"`
+	Description string                `json:"description"`
+	Command     string                `json:"command"`
+	Args        []string              `json:"args"`
+	Parameters  []commandParameterDTO `json:"parameters,omitempty"`
+	Parser      string                `json:"parser,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
 }
 
 func commandInfoToDTO(commandInfo config.CommandInfo) commandInfoDTO {
-	return commandInfoDTO{
+	commandInfoDTO := commandInfoDTO{
 		ID:          commandInfo.ID,
 		Description: commandInfo.Description,
 		Command:     commandInfo.Command,
 		Args:        slices.Clone(commandInfo.Args),
+		Parameters:  commandParametersToDTOs(commandInfo.Parameters),
+		Tags:        slices.Clone(commandInfo.Tags),
+	}
+
+	if parser := commandInfo.Parser; parser != nil {
+		commandInfoDTO.Parser = cmp.Or(parser.Builtin, parser.Type)
 	}
+
+	return commandInfoDTO
 }
 
-type allCommandsHandler struct {
+// externalAwareHandler serves externalHandler to external requests and allHandler to the rest.
+type externalAwareHandler struct {
 	requestIsExternal request.IsExternal
 	allHandler        http.Handler
 	externalHandler   http.Handler
 }
 
-func NewAllCommandsHandler() http.Handler {
+func (externalAwareHandler *externalAwareHandler) ServeHTTP(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	if externalAwareHandler.requestIsExternal(r) {
+		externalAwareHandler.externalHandler.ServeHTTP(w, r)
+	} else {
+		externalAwareHandler.allHandler.ServeHTTP(w, r)
+	}
+}
+
+type allCommandsHandler struct {
+	requestIsExternal   request.IsExternal
+	allCommandDTOs      []commandInfoDTO
+	externalCommandDTOs []commandInfoDTO
+	unfilteredHandler   http.Handler
+}
 
-	commandConfiguration := config.Instance().CommandConfiguration
+func (commands *Commands) NewAllCommandsHandler() http.Handler {
+
+	commandConfiguration := commands.commandConfiguration
 
 	allCommandDTOs := make([]commandInfoDTO, 0, len(commandConfiguration.Commands))
 
@@ -56,51 +84,55 @@ func NewAllCommandsHandler() http.Handler {
 		}
 	}
 
-	allHandler := utils.JSONBytesHandlerFunc(utils.MustMarshalJSON(allCommandDTOs))
-
-	externalHandler := utils.JSONBytesHandlerFunc(utils.MustMarshalJSON(externalCommandDTOs))
+	requestIsExternal := commands.commandRunner.requestIsExternal
 
 	return &allCommandsHandler{
-		requestIsExternal: request.ExternalCheckInstance(),
-		allHandler:        allHandler,
-		externalHandler:   externalHandler,
+		requestIsExternal:   requestIsExternal,
+		allCommandDTOs:      allCommandDTOs,
+		externalCommandDTOs: externalCommandDTOs,
+		unfilteredHandler: &externalAwareHandler{
+			requestIsExternal: requestIsExternal,
+			allHandler:        utils.JSONBytesHandlerFunc(utils.MustMarshalJSON(allCommandDTOs)),
+			externalHandler:   utils.JSONBytesHandlerFunc(utils.MustMarshalJSON(externalCommandDTOs)),
+		},
 	}
 }
 
+// ServeHTTP lists the commands visible to the request, filtered by the
+// tag (all must match) and q (substring of id or description) query parameters.
 func (allCommandsHandler *allCommandsHandler) ServeHTTP(
 	w http.ResponseWriter,
 	r *http.Request,
 ) {
+	query := r.URL.Query()
+
+	tags := query[tagQueryParameter]
+	search := query.Get(searchQueryParameter)
+
+	if len(tags) == 0 && search == "" {
+		allCommandsHandler.unfilteredHandler.ServeHTTP(w, r)
+		return
+	}
+
+	commandDTOs := allCommandsHandler.allCommandDTOs
 	if allCommandsHandler.requestIsExternal(r) {
-		allCommandsHandler.externalHandler.ServeHTTP(w, r)
-	} else {
-		allCommandsHandler.allHandler.ServeHTTP(w, r)
+		commandDTOs = allCommandsHandler.externalCommandDTOs
 	}
+
+	filteredCommandDTOs := filterCommandDTOs(commandDTOs, tags, search)
+
+	utils.RespondWithJSONDTO(&filteredCommandDTOs, w)
 }
 
 type runCommandsHandler struct {
-	requestIsExternal       request.IsExternal
-	commandSemaphore        *semaphore.Weighted
-	requestTimeout          time.Duration
-	semaphoreAcquireTimeout time.Duration
-	idToCommandInfo         map[string]config.CommandInfo
+	commandRunner      *commandRunner
+	commandResultCache *commandResultCache
 }
 
-func NewRunCommandsHandler() http.Handler {
-
-	commandConfiguration := config.Instance().CommandConfiguration
-
-	idToCommandInfo := make(map[string]config.CommandInfo)
-	for _, commandInfo := range commandConfiguration.Commands {
-		idToCommandInfo[commandInfo.ID] = commandInfo
-	}
-
+func (commands *Commands) NewRunCommandsHandler() http.Handler {
 	return &runCommandsHandler{
-		requestIsExternal:       request.ExternalCheckInstance(),
-		commandSemaphore:        semaphore.NewWeighted(commandConfiguration.MaxConcurrentCommands),
-		requestTimeout:          commandConfiguration.RequestTimeoutDuration,
-		semaphoreAcquireTimeout: commandConfiguration.SemaphoreAcquireTimeoutDuration,
-		idToCommandInfo:         idToCommandInfo,
+		commandRunner:      commands.commandRunner,
+		commandResultCache: newCommandResultCache(),
 	}
 }
 
@@ -110,37 +142,44 @@ func (runCommandsHandler *runCommandsHandler) ServeHTTP(
 ) {
 	ctx := r.Context()
 
-	id := r.PathValue("id")
-	commandInfo, ok := runCommandsHandler.idToCommandInfo[id]
+	w.Header().Set(varyHeaderKey, acceptHeaderKey)
 
-	if !ok {
-		slog.Warn("RunCommandsHandler unable to find comand",
-			"id", id,
+	responseFormat, err := negotiateResponseFormat(r)
+	if err != nil {
+		slog.Warn("RunCommandsHandler negotiateResponseFormat error",
+			"error", err,
 		)
-		utils.HTTPErrorStatusCode(w, http.StatusNotFound)
+		utils.HTTPErrorStatusCode(w, http.StatusBadRequest)
 		return
 	}
 
-	if commandInfo.InternalOnly && runCommandsHandler.requestIsExternal(r) {
-		slog.Warn("RunCommandsHandler external request for internal only command",
-			"id", id,
-		)
-		utils.HTTPErrorStatusCode(w, http.StatusNotFound)
+	commandInfo, ok := runCommandsHandler.commandRunner.runnableCommandInfoForRequest(w, r)
+	if !ok {
 		return
 	}
 
-	runCommandsHandler.handleRunCommandRequest(ctx, commandInfo, w)
+	admissionClass := runCommandsHandler.commandRunner.admissionClassForRequest(r)
+
+	runCommandsHandler.handleRunCommandRequest(ctx, commandInfo, admissionClass, responseFormat, w)
 }
 
 func (runCommandsHandler *runCommandsHandler) handleRunCommandRequest(
 	ctx context.Context,
 	commandInfo config.CommandInfo,
+	admissionClass admissionClass,
+	responseFormat responseFormat,
 	w http.ResponseWriter,
 ) {
-	ctx, cancel := context.WithTimeout(ctx, runCommandsHandler.requestTimeout)
+	ctx, cancel := context.WithTimeout(ctx, runCommandsHandler.commandRunner.requestTimeout(commandInfo))
 	defer cancel()
 
-	commandAPIResponse, err := runCommandsHandler.runCommand(ctx, commandInfo)
+	var commandAPIResponse commandAPIResponse
+	var err error
+	if commandInfo.CacheTTL > 0 {
+		commandAPIResponse, err = runCommandsHandler.runCachedCommand(ctx, commandInfo, admissionClass, w)
+	} else {
+		commandAPIResponse, err = runCommandsHandler.runCommand(ctx, commandInfo, admissionClass)
+	}
 
 	if err != nil {
 		slog.Warn("RunCommandsHandler.runCommand returned error",
@@ -148,74 +187,86 @@ func (runCommandsHandler *runCommandsHandler) handleRunCommandRequest(
 		)
 		switch {
 		case errors.Is(err, errorAcquiringCommandSemaphore):
-			utils.HTTPErrorStatusCode(w, http.StatusTooManyRequests)
+			commandAPIResponse = semaphoreRejectionResponse(commandInfo, err)
+
+		case errors.Is(err, errorWaitingForSharedRun):
+			commandAPIResponse = timeoutResponse(commandInfo, err)
 
 		default:
 			utils.HTTPErrorStatusCode(w, http.StatusInternalServerError)
+			return
 		}
-		return
 	}
 
-	utils.RespondWithJSONDTO(commandAPIResponse, w)
+	statusCode := runCommandsHandler.commandRunner.errorKindHTTPStatusCodes[commandAPIResponse.ErrorKind]
+
+	writeCommandAPIResponse(w, responseFormat, &commandAPIResponse, statusCode)
 }
 
-var errorAcquiringCommandSemaphore = errors.New("error acquiring command semaphore")
+// commandAPIResponse output fields are base64 encoded when the matching encoding field is "base64".
+// ParsedOutput is the command's parser applied to stdout.
+type commandAPIResponse struct {
+	CommandInfo                 commandInfoDTO   `json:"command_info"`
+	Now                         time.Time        `json:"now"`
+	CommandDurationMilliseconds int64            `json:"command_duration_ms"`
+	CommandOutput               string           `json:"command_output"`
+	CommandOutputEncoding       string           `json:"command_output_encoding,omitempty"`
+	Stdout                      string           `json:"stdout"`
+	StdoutEncoding              string           `json:"stdout_encoding,omitempty"`
+	Stderr                      string           `json:"stderr"`
+	StderrEncoding              string           `json:"stderr_encoding,omitempty"`
+	Truncated                   bool             `json:"truncated,omitzero"`
+	TotalOutputBytes            int64            `json:"total_output_bytes"`
+	ParsedOutput                any              `json:"parsed_output,omitzero"`
+	ParseError                  string           `json:"parse_error,omitempty"`
+	ExitCode                    *int             `json:"exit_code,omitempty"`
+	Signal                      string           `json:"signal,omitempty"`
+	ErrorKind                   commandErrorKind `json:"error_kind,omitempty"`
+	Error                       string           `json:"error,omitempty"`
+	CacheAgeMilliseconds        *int64           `json:"cache_age_ms,omitempty"`
+}
 
-func (runCommandsHandler *runCommandsHandler) acquireCommandSemaphore(ctx context.Context) error {
-	ctx, cancel := context.WithTimeout(ctx, runCommandsHandler.semaphoreAcquireTimeout)
-	defer cancel()
+func (runCommandsHandler *runCommandsHandler) runCachedCommand(
+	ctx context.Context,
+	commandInfo config.CommandInfo,
+	admissionClass admissionClass,
+	w http.ResponseWriter,
+) (commandAPIResponse, error) {
+	response, cacheStatus, age, err := runCommandsHandler.commandResultCache.getOrRun(
+		ctx,
+		commandInfo,
+		func(ctx context.Context) (commandAPIResponse, error) {
+			ctx, cancel := context.WithTimeout(ctx, runCommandsHandler.commandRunner.requestTimeout(commandInfo))
+			defer cancel()
 
-	err := runCommandsHandler.commandSemaphore.Acquire(ctx, 1)
-	if err != nil {
-		return fmt.Errorf("%w: %w", errorAcquiringCommandSemaphore, err)
+			return runCommandsHandler.runCommand(ctx, commandInfo, admissionClass)
+		},
+	)
+
+	if cacheStatus != "" {
+		w.Header().Set(cacheStatusHeaderKey, string(cacheStatus))
 	}
-	return nil
-}
 
-func (runCommandsHandler *runCommandsHandler) releaseCommandSemaphore() {
-	runCommandsHandler.commandSemaphore.Release(1)
-}
+	if cacheStatus == cacheStatusHit {
+		w.Header().Set(ageHeaderKey, strconv.FormatInt(int64(age.Seconds()), 10))
+	}
 
-type commandAPIResponse struct {
-	CommandInfo                 commandInfoDTO `json:"command_info"`
-	Now                         time.Time      `json:"now"`
-	CommandDurationMilliseconds int64          `json:"command_duration_ms"`
-	CommandOutput               string         `json:"command_output"`
+	return response, err
 }
 
 func (runCommandsHandler *runCommandsHandler) runCommand(
 	ctx context.Context,
 	commandInfo config.CommandInfo,
+	admissionClass admissionClass,
 ) (response commandAPIResponse, err error) {
-	err = runCommandsHandler.acquireCommandSemaphore(ctx)
-	if err != nil {
-		return
-	}
-	defer runCommandsHandler.releaseCommandSemaphore()
-
-	commandStartTime := time.Now()
-	rawCommandOutput, err := exec.CommandContext(
-		ctx,
-		commandInfo.Command,
-		commandInfo.Args...,
-	).CombinedOutput()
-	commandEndTime := time.Now()
-
-	commandDuration := commandEndTime.Sub(commandStartTime)
+	commandRunner := runCommandsHandler.commandRunner
 
-	var commandOutput string
+	err = commandRunner.acquireCommandSemaphore(ctx, commandInfo, admissionClass)
 	if err != nil {
-		commandOutput = fmt.Sprintf("command error %v", err)
-	} else {
-		commandOutput = string(rawCommandOutput)
+		return
 	}
+	defer commandRunner.releaseCommandSemaphore(commandInfo, admissionClass)
 
-	response = commandAPIResponse{
-		CommandInfo:                 commandInfoToDTO(commandInfo),
-		Now:                         commandEndTime,
-		CommandDurationMilliseconds: commandDuration.Milliseconds(),
-		CommandOutput:               commandOutput,
-	}
-	err = nil
+	response, _ = commandRunner.executeCommand(ctx, commandInfo)
 	return
 }
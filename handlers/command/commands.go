@@ -0,0 +1,80 @@
+package command
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aaronriekenberg/go-api/config"
+	"github.com/aaronriekenberg/go-api/request"
+)
+
+// Commands is the state the command handlers share for one configuration.
+// When the configuration is reloaded a new Commands takes over the statistics,
+// scheduled command results and jobs of the previous one.
+// Commands already running finish under the previous limits.
+type Commands struct {
+	commandConfiguration     config.CommandConfiguration
+	commandRunner            *commandRunner
+	commandScheduler         *commandScheduler
+	jobsHandler              *jobsHandler
+	compositeCommandsHandler *compositeCommandsHandler
+}
+
+// NewCommands validates commandConfiguration.  previous is nil on startup.
+func NewCommands(
+	commandConfiguration config.CommandConfiguration,
+	requestIsExternal request.IsExternal,
+	previous *Commands,
+) (*Commands, error) {
+	var (
+		previousCommandRunner    *commandRunner
+		previousCommandScheduler *commandScheduler
+		previousJobsHandler      *jobsHandler
+	)
+	if previous != nil {
+		previousCommandRunner = previous.commandRunner
+		previousCommandScheduler = previous.commandScheduler
+		previousJobsHandler = previous.jobsHandler
+	}
+
+	commandRunner, err := newCommandRunner(commandConfiguration, requestIsExternal, previousCommandRunner)
+	if err != nil {
+		return nil, fmt.Errorf("newCommandRunner error: %w", err)
+	}
+
+	commandScheduler, err := newCommandScheduler(commandRunner, commandConfiguration.Commands, previousCommandScheduler)
+	if err != nil {
+		return nil, fmt.Errorf("newCommandScheduler error: %w", err)
+	}
+
+	compositeCommandsHandler, err := newCompositeCommandsHandler(commandRunner, commandConfiguration.CompositeCommands)
+	if err != nil {
+		return nil, fmt.Errorf("newCompositeCommandsHandler error: %w", err)
+	}
+
+	return &Commands{
+		commandConfiguration:     commandConfiguration,
+		commandRunner:            commandRunner,
+		commandScheduler:         commandScheduler,
+		jobsHandler:              newJobsHandler(commandRunner, commandConfiguration.JobConfiguration, previousJobsHandler),
+		compositeCommandsHandler: compositeCommandsHandler,
+	}, nil
+}
+
+// Start starts running the scheduled commands.
+func (commands *Commands) Start() {
+	commands.commandScheduler.start()
+}
+
+// Stop stops scheduling commands, runs in progress finish.
+func (commands *Commands) Stop() {
+	commands.commandScheduler.stop()
+}
+
+// Shutdown stops scheduling commands and waits for the running commands of every configuration
+// until ctx is done, then kills them.  No more commands are started.
+func (commands *Commands) Shutdown(ctx context.Context) error {
+	commands.Stop()
+
+	return commands.commandRunner.runningCommands.shutdown(ctx)
+}
@@ -0,0 +1,96 @@
+package command
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aaronriekenberg/go-api/config"
+)
+
+func requestIsNeverExternal(r *http.Request) bool {
+	return false
+}
+
+func TestNewCommandsKeepsPreviousState(t *testing.T) {
+	commandConfiguration := config.CommandConfiguration{
+		MaxConcurrentCommands: 2,
+		JobConfiguration:      config.JobConfiguration{MaxJobs: 1},
+		Commands: []config.CommandInfo{
+			{ID: "uptime", Command: "/usr/bin/uptime", Schedule: &config.ScheduleConfiguration{IntervalDuration: time.Minute, HistorySize: 2}},
+			{ID: "w", Command: "/usr/bin/w"},
+		},
+	}
+
+	previous, err := NewCommands(commandConfiguration, requestIsNeverExternal, nil)
+	if err != nil {
+		t.Fatalf("NewCommands error: %v", err)
+	}
+
+	previous.commandRunner.idToStats["uptime"].recordRun(time.Millisecond, commandErrorKindNone, nil)
+	for _, exitCode := range []int{1, 2, 3} {
+		previous.commandScheduler.idToScheduledCommand["uptime"].history.add(commandAPIResponse{ExitCode: new(exitCode)})
+	}
+
+	commandConfiguration.MaxConcurrentCommands = 3
+	commandConfiguration.JobConfiguration.MaxJobs = 5
+	commandConfiguration.Commands[0].Schedule = &config.ScheduleConfiguration{IntervalDuration: time.Minute, HistorySize: 1}
+
+	commands, err := NewCommands(commandConfiguration, requestIsNeverExternal, previous)
+	if err != nil {
+		t.Fatalf("NewCommands error: %v", err)
+	}
+
+	if runs := commands.commandRunner.idToStats["uptime"].toDTO("uptime").Runs; runs != 1 {
+		t.Errorf("got runs %d want 1", runs)
+	}
+
+	results := commands.commandScheduler.idToScheduledCommand["uptime"].history.all()
+	if len(results) != 1 || *results[0].ExitCode != 3 {
+		t.Errorf("got results %+v want the last one", results)
+	}
+
+	// a run of the previous scheduler finishing after the reload
+	previous.commandScheduler.idToScheduledCommand["uptime"].history.add(commandAPIResponse{ExitCode: new(4)})
+
+	results = commands.commandScheduler.idToScheduledCommand["uptime"].history.all()
+	if len(results) != 1 || *results[0].ExitCode != 4 {
+		t.Errorf("got results %+v want the run finished after the reload", results)
+	}
+
+	if commands.jobsHandler.jobStore != previous.jobsHandler.jobStore || commands.jobsHandler.jobStore.maxJobs != 5 {
+		t.Errorf("jobStore not kept with new limits")
+	}
+
+	if commands.commandRunner.admissionController == previous.commandRunner.admissionController {
+		t.Errorf("admissionController kept after MaxConcurrentCommands changed")
+	}
+}
+
+func TestNewCommandsErrors(t *testing.T) {
+	tests := map[string]config.CommandConfiguration{
+		"no concurrency": {
+			Commands: []config.CommandInfo{{ID: "uptime", Command: "/usr/bin/uptime"}},
+		},
+		"bad schedule": {
+			MaxConcurrentCommands: 1,
+			Commands:              []config.CommandInfo{{ID: "uptime", Command: "/usr/bin/uptime", Schedule: &config.ScheduleConfiguration{}}},
+		},
+		"reserved ID": {
+			MaxConcurrentCommands: 1,
+			Commands:              []config.CommandInfo{{ID: "limits", Command: "/usr/bin/uptime"}},
+		},
+		"bad composite": {
+			MaxConcurrentCommands: 1,
+			CompositeCommands:     []config.CompositeCommandInfo{{ID: "overview", CommandIDs: []string{"bogus"}}},
+		},
+	}
+
+	for name, commandConfiguration := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewCommands(commandConfiguration, requestIsNeverExternal, nil); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aaronriekenberg/go-api/config"
+	"github.com/aaronriekenberg/go-api/utils"
+)
+
+type compositeCommandInfoDTO struct {
+	ID          string   `json:"id"`
+	Description string   `json:"description"`
+	CommandIDs  []string `json:"command_ids"`
+	Parallel    bool     `json:"parallel"`
+}
+
+func compositeCommandInfoToDTO(compositeCommandInfo config.CompositeCommandInfo) compositeCommandInfoDTO {
+	return compositeCommandInfoDTO{
+		ID:          compositeCommandInfo.ID,
+		Description: compositeCommandInfo.Description,
+		CommandIDs:  compositeCommandInfo.CommandIDs,
+		Parallel:    compositeCommandInfo.Parallel,
+	}
+}
+
+// compositeCommand is a validated CompositeCommandInfo with the member commands it runs.
+type compositeCommand struct {
+	compositeCommandInfo config.CompositeCommandInfo
+	memberCommandInfos   []config.CommandInfo
+}
+
+func newCompositeCommand(
+	compositeCommandInfo config.CompositeCommandInfo,
+	commandRunner *commandRunner,
+) (*compositeCommand, error) {
+	if len(compositeCommandInfo.CommandIDs) == 0 {
+		return nil, fmt.Errorf("composite command %q has no CommandIDs", compositeCommandInfo.ID)
+	}
+
+	memberCommandInfos := make([]config.CommandInfo, 0, len(compositeCommandInfo.CommandIDs))
+
+	for _, commandID := range compositeCommandInfo.CommandIDs {
+		commandInfo, ok := commandRunner.idToCommandInfo[commandID]
+		if !ok {
+			return nil, fmt.Errorf("composite command %q: unknown command ID %q", compositeCommandInfo.ID, commandID)
+		}
+
+		if commandInfo.InternalOnly && !compositeCommandInfo.InternalOnly {
+			return nil, fmt.Errorf("composite command %q: internal only command %q in external composite command", compositeCommandInfo.ID, commandID)
+		}
+
+		// members run with their parameter defaults
+		commandInfo, err := commandRunner.commandInfoWithDefaultArgs(commandInfo)
+		if err != nil {
+			return nil, fmt.Errorf("composite command %q: command %q: %w", compositeCommandInfo.ID, commandID, err)
+		}
+
+		memberCommandInfos = append(memberCommandInfos, commandInfo)
+	}
+
+	return &compositeCommand{
+		compositeCommandInfo: compositeCommandInfo,
+		memberCommandInfos:   memberCommandInfos,
+	}, nil
+}
+
+type compositeCommandAPIResponse struct {
+	CompositeCommandInfo        compositeCommandInfoDTO `json:"composite_command_info"`
+	Now                         time.Time               `json:"now"`
+	CommandDurationMilliseconds int64                   `json:"command_duration_ms"`
+	Results                     []commandAPIResponse    `json:"results"`
+}
+
+type compositeCommandsHandler struct {
+	commandRunner        *commandRunner
+	idToCompositeCommand map[string]*compositeCommand
+}
+
+func newCompositeCommandsHandler(
+	commandRunner *commandRunner,
+	compositeCommandInfos []config.CompositeCommandInfo,
+) (*compositeCommandsHandler, error) {
+	idToCompositeCommand := make(map[string]*compositeCommand)
+
+	for _, compositeCommandInfo := range compositeCommandInfos {
+		if _, ok := idToCompositeCommand[compositeCommandInfo.ID]; ok {
+			return nil, fmt.Errorf("duplicate composite command ID %q", compositeCommandInfo.ID)
+		}
+
+		compositeCommand, err := newCompositeCommand(compositeCommandInfo, commandRunner)
+		if err != nil {
+			return nil, fmt.Errorf("newCompositeCommand error: %w", err)
+		}
+
+		idToCompositeCommand[compositeCommandInfo.ID] = compositeCommand
+	}
+
+	return &compositeCommandsHandler{
+		commandRunner:        commandRunner,
+		idToCompositeCommand: idToCompositeCommand,
+	}, nil
+}
+
+func (commands *Commands) NewAllCompositeCommandsHandler() http.Handler {
+	compositeCommandsHandler := commands.compositeCommandsHandler
+	compositeCommandInfos := commands.commandConfiguration.CompositeCommands
+
+	allCompositeCommandDTOs := make([]compositeCommandInfoDTO, 0, len(compositeCommandInfos))
+
+	externalCompositeCommandDTOs := make([]compositeCommandInfoDTO, 0, len(compositeCommandInfos))
+
+	for _, compositeCommandInfo := range compositeCommandInfos {
+
+		allCompositeCommandDTOs = append(allCompositeCommandDTOs, compositeCommandInfoToDTO(compositeCommandInfo))
+
+		if !compositeCommandInfo.InternalOnly {
+			externalCompositeCommandDTOs = append(externalCompositeCommandDTOs, compositeCommandInfoToDTO(compositeCommandInfo))
+		}
+	}
+
+	return &externalAwareHandler{
+		requestIsExternal: compositeCommandsHandler.commandRunner.requestIsExternal,
+		allHandler:        utils.JSONBytesHandlerFunc(utils.MustMarshalJSON(allCompositeCommandDTOs)),
+		externalHandler:   utils.JSONBytesHandlerFunc(utils.MustMarshalJSON(externalCompositeCommandDTOs)),
+	}
+}
+
+func (commands *Commands) NewRunCompositeCommandHandler() http.Handler {
+	return commands.compositeCommandsHandler
+}
+
+func (compositeCommandsHandler *compositeCommandsHandler) ServeHTTP(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	compositeCommand, ok := compositeCommandsHandler.idToCompositeCommand[r.PathValue("id")]
+	if !ok ||
+		(compositeCommand.compositeCommandInfo.InternalOnly && compositeCommandsHandler.commandRunner.requestIsExternal(r)) {
+		utils.HTTPErrorStatusCode(w, http.StatusNotFound)
+		return
+	}
+
+	startTime := time.Now()
+
+	admissionClass := compositeCommandsHandler.commandRunner.admissionClassForRequest(r)
+
+	results := make([]commandAPIResponse, len(compositeCommand.memberCommandInfos))
+
+	if compositeCommand.compositeCommandInfo.Parallel {
+		var waitGroup sync.WaitGroup
+		for i, commandInfo := range compositeCommand.memberCommandInfos {
+			waitGroup.Go(func() {
+				results[i] = compositeCommandsHandler.runMemberCommand(r.Context(), commandInfo, admissionClass)
+			})
+		}
+		waitGroup.Wait()
+	} else {
+		for i, commandInfo := range compositeCommand.memberCommandInfos {
+			results[i] = compositeCommandsHandler.runMemberCommand(r.Context(), commandInfo, admissionClass)
+		}
+	}
+
+	endTime := time.Now()
+
+	response := compositeCommandAPIResponse{
+		CompositeCommandInfo:        compositeCommandInfoToDTO(compositeCommand.compositeCommandInfo),
+		Now:                         endTime,
+		CommandDurationMilliseconds: endTime.Sub(startTime).Milliseconds(),
+		Results:                     results,
+	}
+
+	utils.RespondWithJSONDTO(&response, w)
+}
+
+// runMemberCommand runs one member under the shared semaphore and the member's own limits.
+// Failures, including semaphore rejection, are reported in the member's result.
+func (compositeCommandsHandler *compositeCommandsHandler) runMemberCommand(
+	ctx context.Context,
+	commandInfo config.CommandInfo,
+	admissionClass admissionClass,
+) commandAPIResponse {
+	commandRunner := compositeCommandsHandler.commandRunner
+
+	ctx, cancel := context.WithTimeout(ctx, commandRunner.requestTimeout(commandInfo))
+	defer cancel()
+
+	err := commandRunner.acquireCommandSemaphore(ctx, commandInfo, admissionClass)
+	if err != nil {
+		return semaphoreRejectionResponse(commandInfo, err)
+	}
+	defer commandRunner.releaseCommandSemaphore(commandInfo, admissionClass)
+
+	response, _ := commandRunner.executeCommand(ctx, commandInfo)
+	return response
+}
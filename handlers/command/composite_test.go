@@ -0,0 +1,69 @@
+package command
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/aaronriekenberg/go-api/config"
+)
+
+func TestNewCompositeCommand(t *testing.T) {
+	commandInfos := []config.CommandInfo{
+		{ID: "uptime", Command: "/usr/bin/uptime"},
+		{ID: "w", InternalOnly: true, Command: "/usr/bin/w"},
+		{ID: "sleep", Command: "/bin/sleep", Args: []string{"{seconds}"}, Parameters: []config.CommandParameter{
+			{Name: "seconds", MinValue: new(int64(0)), MaxValue: new(int64(5)), Default: new("1")},
+		}},
+		{ID: "echo", Command: "/bin/echo", Args: []string{"{word}"}, Parameters: []config.CommandParameter{
+			{Name: "word", Regex: `[a-z]+`},
+		}},
+	}
+
+	commandRunner := &commandRunner{
+		idToCommandInfo: make(map[string]config.CommandInfo),
+		idToParameters:  make(map[string]commandParameters),
+	}
+	for _, commandInfo := range commandInfos {
+		parameters, err := newCommandParameters(commandInfo)
+		if err != nil {
+			t.Fatalf("newCommandParameters error: %v", err)
+		}
+		commandRunner.idToCommandInfo[commandInfo.ID] = commandInfo
+		commandRunner.idToParameters[commandInfo.ID] = parameters
+	}
+
+	compositeCommand, err := newCompositeCommand(config.CompositeCommandInfo{
+		ID:         "overview",
+		CommandIDs: []string{"uptime", "sleep"},
+	}, commandRunner)
+	if err != nil {
+		t.Fatalf("newCompositeCommand error: %v", err)
+	}
+
+	if got := compositeCommand.memberCommandInfos[1].Args; !slices.Equal(got, []string{"1"}) {
+		t.Errorf("got member args %q want default args", got)
+	}
+
+	tests := map[string]config.CompositeCommandInfo{
+		"no members":             {ID: "empty"},
+		"unknown member":         {ID: "unknown", CommandIDs: []string{"uptime", "bogus"}},
+		"external with internal": {ID: "external", CommandIDs: []string{"uptime", "w"}},
+		"required parameter":     {ID: "required", CommandIDs: []string{"echo"}},
+	}
+
+	for name, compositeCommandInfo := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := newCompositeCommand(compositeCommandInfo, commandRunner); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+
+	if _, err := newCompositeCommand(config.CompositeCommandInfo{
+		ID:           "internal",
+		InternalOnly: true,
+		CommandIDs:   []string{"uptime", "w"},
+	}, commandRunner); err != nil {
+		t.Errorf("internal composite command with internal member error: %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronField is a bit set of the values matched by one cron expression field.
+type cronField uint64
+
+func (cronField cronField) matches(value int) bool {
+	return cronField&(1<<uint(value)) != 0
+}
+
+type cronFieldRange struct {
+	name     string
+	minValue int
+	maxValue int
+}
+
+var cronFieldRanges = []cronFieldRange{
+	{name: "minute", minValue: 0, maxValue: 59},
+	{name: "hour", minValue: 0, maxValue: 23},
+	{name: "day of month", minValue: 1, maxValue: 31},
+	{name: "month", minValue: 1, maxValue: 12},
+	// 0 and 7 are both Sunday
+	{name: "day of week", minValue: 0, maxValue: 7},
+}
+
+// cronSchedule is a parsed 5 field cron expression: minute hour day-of-month month day-of-week.
+// Fields support "*", lists "a,b", ranges "a-b" and steps "*/n" or "a-b/n".
+// When both day fields are restricted a day matching either one matches, as in cron(8).
+type cronSchedule struct {
+	expression         string
+	minute             cronField
+	hour               cronField
+	dayOfMonth         cronField
+	month              cronField
+	dayOfWeek          cronField
+	dayOfMonthWildcard bool
+	dayOfWeekWildcard  bool
+}
+
+// cronSearchLimit bounds the search for the next matching time, e.g. for "0 0 30 2 *".
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+func parseCronField(
+	field string,
+	fieldRange cronFieldRange,
+) (cronField cronField, err error) {
+	for part := range strings.SplitSeq(field, ",") {
+		rangePart, stepString, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			step, err = strconv.Atoi(stepString)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid %s step %q", fieldRange.name, stepString)
+			}
+		}
+
+		var low, high int
+		switch {
+		case rangePart == "*":
+			low, high = fieldRange.minValue, fieldRange.maxValue
+
+		case strings.Contains(rangePart, "-"):
+			lowString, highString, _ := strings.Cut(rangePart, "-")
+			if low, err = strconv.Atoi(lowString); err != nil {
+				return 0, fmt.Errorf("invalid %s value %q", fieldRange.name, lowString)
+			}
+			if high, err = strconv.Atoi(highString); err != nil {
+				return 0, fmt.Errorf("invalid %s value %q", fieldRange.name, highString)
+			}
+
+		default:
+			if low, err = strconv.Atoi(rangePart); err != nil {
+				return 0, fmt.Errorf("invalid %s value %q", fieldRange.name, rangePart)
+			}
+			high = low
+			if hasStep {
+				high = fieldRange.maxValue
+			}
+		}
+
+		if low < fieldRange.minValue || high > fieldRange.maxValue || low > high {
+			return 0, fmt.Errorf("%s range %q outside %d-%d", fieldRange.name, rangePart, fieldRange.minValue, fieldRange.maxValue)
+		}
+
+		for value := low; value <= high; value += step {
+			cronField |= 1 << uint(value)
+		}
+	}
+
+	return cronField, nil
+}
+
+func parseCronSchedule(expression string) (*cronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != len(cronFieldRanges) {
+		return nil, fmt.Errorf("cron expression %q has %d fields, want %d", expression, len(fields), len(cronFieldRanges))
+	}
+
+	cronFields := make([]cronField, len(fields))
+	for i, field := range fields {
+		cronField, err := parseCronField(field, cronFieldRanges[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %w", expression, err)
+		}
+		cronFields[i] = cronField
+	}
+
+	dayOfWeek := cronFields[4]
+	if dayOfWeek.matches(7) {
+		dayOfWeek |= 1 << 0
+	}
+
+	return &cronSchedule{
+		expression:         expression,
+		minute:             cronFields[0],
+		hour:               cronFields[1],
+		dayOfMonth:         cronFields[2],
+		month:              cronFields[3],
+		dayOfWeek:          dayOfWeek,
+		dayOfMonthWildcard: strings.HasPrefix(fields[2], "*"),
+		dayOfWeekWildcard:  strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+func (cronSchedule *cronSchedule) dayMatches(t time.Time) bool {
+	dayOfMonthMatches := cronSchedule.dayOfMonth.matches(t.Day())
+	dayOfWeekMatches := cronSchedule.dayOfWeek.matches(int(t.Weekday()))
+
+	switch {
+	case cronSchedule.dayOfMonthWildcard && cronSchedule.dayOfWeekWildcard:
+		return true
+	case cronSchedule.dayOfMonthWildcard:
+		return dayOfWeekMatches
+	case cronSchedule.dayOfWeekWildcard:
+		return dayOfMonthMatches
+	default:
+		return dayOfMonthMatches || dayOfWeekMatches
+	}
+}
+
+// next returns the first matching minute after after, or the zero time if none is found.
+func (cronSchedule *cronSchedule) next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	searchLimit := t.Add(cronSearchLimit)
+
+	for t.Before(searchLimit) {
+		switch {
+		case !cronSchedule.month.matches(int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+
+		case !cronSchedule.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+
+		case !cronSchedule.hour.matches(t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+
+		case !cronSchedule.minute.matches(t.Minute()):
+			t = t.Add(time.Minute)
+
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (cronSchedule *cronSchedule) String() string {
+	return "cron " + cronSchedule.expression
+}
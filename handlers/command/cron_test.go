@@ -0,0 +1,64 @@
+package command
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronScheduleErrors(t *testing.T) {
+	tests := map[string]string{
+		"too few fields":   "* * * *",
+		"too many fields":  "* * * * * *",
+		"minute too large": "60 * * * *",
+		"hour too large":   "* 24 * * *",
+		"day zero":         "* * 0 * *",
+		"month too large":  "* * * 13 *",
+		"bad step":         "*/0 * * * *",
+		"reversed range":   "10-5 * * * *",
+		"not a number":     "a * * * *",
+	}
+
+	for name, expression := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseCronSchedule(expression); err == nil {
+				t.Fatalf("expected error for %q", expression)
+			}
+		})
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	// a Wednesday
+	after := time.Date(2025, time.January, 1, 10, 7, 30, 0, time.UTC)
+
+	tests := map[string]struct {
+		expression string
+		want       time.Time
+	}{
+		"every minute":        {expression: "* * * * *", want: time.Date(2025, time.January, 1, 10, 8, 0, 0, time.UTC)},
+		"every 5 minutes":     {expression: "*/5 * * * *", want: time.Date(2025, time.January, 1, 10, 10, 0, 0, time.UTC)},
+		"list":                {expression: "3,6 * * * *", want: time.Date(2025, time.January, 1, 11, 3, 0, 0, time.UTC)},
+		"hour range step":     {expression: "0 0-12/6 * * *", want: time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC)},
+		"daily":               {expression: "30 2 * * *", want: time.Date(2025, time.January, 2, 2, 30, 0, 0, time.UTC)},
+		"day of month":        {expression: "0 0 15 * *", want: time.Date(2025, time.January, 15, 0, 0, 0, 0, time.UTC)},
+		"month":               {expression: "0 0 1 3 *", want: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		"sunday 0":            {expression: "0 0 * * 0", want: time.Date(2025, time.January, 5, 0, 0, 0, 0, time.UTC)},
+		"sunday 7":            {expression: "0 0 * * 7", want: time.Date(2025, time.January, 5, 0, 0, 0, 0, time.UTC)},
+		"day of month or dow": {expression: "0 0 20 * 5", want: time.Date(2025, time.January, 3, 0, 0, 0, 0, time.UTC)},
+		"leap day":            {expression: "0 0 29 2 *", want: time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		"never":               {expression: "0 0 30 2 *", want: time.Time{}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cronSchedule, err := parseCronSchedule(tc.expression)
+			if err != nil {
+				t.Fatalf("parseCronSchedule error: %v", err)
+			}
+
+			if got := cronSchedule.next(after); !got.Equal(tc.want) {
+				t.Fatalf("got %v want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package command
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aaronriekenberg/go-api/utils"
+)
+
+// formatQueryParameter overrides the Accept header.  It is reserved and cannot be a command parameter name.
+const formatQueryParameter = "format"
+
+type responseFormat string
+
+const (
+	responseFormatJSON responseFormat = "json"
+	responseFormatText responseFormat = "text"
+	responseFormatHTML responseFormat = "html"
+)
+
+// responseFormatMediaTypes is in order of preference when the Accept header ranks formats equally.
+var responseFormatMediaTypes = []struct {
+	responseFormat responseFormat
+	mediaType      string
+}{
+	{responseFormat: responseFormatJSON, mediaType: utils.ContentTypeApplicationJSON},
+	{responseFormat: responseFormatText, mediaType: "text/plain"},
+	{responseFormat: responseFormatHTML, mediaType: "text/html"},
+}
+
+const (
+	contentTypeTextHTML         = "text/html; charset=utf-8"
+	contentTypeApplicationOctet = "application/octet-stream"
+	acceptHeaderKey             = "Accept"
+	varyHeaderKey               = "Vary"
+)
+
+// acceptQuality returns the quality the accept header gives mediaType,
+// from the most specific matching media range, or 0 if no media range matches.
+func acceptQuality(
+	accept string,
+	mediaType string,
+) float64 {
+	mainType, _, _ := strings.Cut(mediaType, "/")
+
+	quality := 0.0
+	bestSpecificity := -1
+
+	for mediaRange := range strings.SplitSeq(accept, ",") {
+		rangeType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+
+		var specificity int
+		switch rangeType {
+		case mediaType:
+			specificity = 2
+		case mainType + "/*":
+			specificity = 1
+		case "*/*":
+			specificity = 0
+		default:
+			continue
+		}
+
+		if specificity <= bestSpecificity {
+			continue
+		}
+		bestSpecificity = specificity
+
+		quality = 1.0
+		if qualityString, ok := params["q"]; ok {
+			if parsedQuality, err := strconv.ParseFloat(qualityString, 64); err == nil {
+				quality = parsedQuality
+			}
+		}
+	}
+
+	return quality
+}
+
+// negotiateResponseFormat picks the format from the format query parameter, else the Accept header.
+// JSON is the default when there is no Accept header or it accepts none of the formats.
+func negotiateResponseFormat(
+	r *http.Request,
+) (responseFormat, error) {
+	if format := r.URL.Query().Get(formatQueryParameter); format != "" {
+		for _, formatMediaType := range responseFormatMediaTypes {
+			if string(formatMediaType.responseFormat) == format {
+				return formatMediaType.responseFormat, nil
+			}
+		}
+		return "", fmt.Errorf("unknown format %q", format)
+	}
+
+	accept := r.Header.Get(acceptHeaderKey)
+	if accept == "" {
+		return responseFormatJSON, nil
+	}
+
+	bestFormat := responseFormatJSON
+	bestQuality := 0.0
+
+	for _, formatMediaType := range responseFormatMediaTypes {
+		if quality := acceptQuality(accept, formatMediaType.mediaType); quality > bestQuality {
+			bestFormat = formatMediaType.responseFormat
+			bestQuality = quality
+		}
+	}
+
+	return bestFormat, nil
+}
+
+var commandHTMLTemplate = template.Must(template.New("command").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.CommandInfo.ID}}</title>
+</head>
+<body>
+<h2>{{.CommandInfo.Description}}</h2>
+<table>
+<tr><td>Command</td><td>{{.CommandInfo.Command}}{{range .CommandInfo.Args}} {{.}}{{end}}</td></tr>
+<tr><td>Now</td><td>{{.Now.Format "2006-01-02T15:04:05.999999999Z07:00"}}</td></tr>
+<tr><td>Duration</td><td>{{.CommandDurationMilliseconds}}ms</td></tr>
+{{- with .ExitCode}}
+<tr><td>Exit Code</td><td>{{.}}</td></tr>
+{{- end}}
+{{- with .Signal}}
+<tr><td>Signal</td><td>{{.}}</td></tr>
+{{- end}}
+{{- with .ErrorKind}}
+<tr><td>Error Kind</td><td>{{.}}</td></tr>
+{{- end}}
+{{- with .Error}}
+<tr><td>Error</td><td>{{.}}</td></tr>
+{{- end}}
+{{- if .Truncated}}
+<tr><td>Truncated</td><td>{{.TotalOutputBytes}} bytes total</td></tr>
+{{- end}}
+{{- with .CommandOutputEncoding}}
+<tr><td>Output Encoding</td><td>{{.}}</td></tr>
+{{- end}}
+</table>
+<pre>{{.CommandOutput}}</pre>
+</body>
+</html>
+`))
+
+// writeCommandAPIResponse writes response in responseFormat.
+// The text format is only the command output, or the error if there is no output.
+func writeCommandAPIResponse(
+	w http.ResponseWriter,
+	responseFormat responseFormat,
+	response *commandAPIResponse,
+	statusCode int,
+) {
+	switch responseFormat {
+	case responseFormatText:
+		output := []byte(response.CommandOutput)
+		contentType := utils.ContentTypeTextPlain
+
+		if response.CommandOutputEncoding == outputEncodingBase64 {
+			output, _ = base64.StdEncoding.DecodeString(response.CommandOutput)
+			contentType = contentTypeApplicationOctet
+		}
+
+		if len(output) == 0 && response.Error != "" {
+			output = []byte(response.Error + "\n")
+			contentType = utils.ContentTypeTextPlain
+		}
+
+		w.Header().Set(utils.ContentTypeHeaderKey, contentType)
+		w.WriteHeader(statusCode)
+		w.Write(output)
+
+	case responseFormatHTML:
+		var htmlBuilder strings.Builder
+		err := commandHTMLTemplate.Execute(&htmlBuilder, response)
+		if err != nil {
+			slog.Warn("writeCommandAPIResponse template error",
+				"error", err,
+			)
+			utils.HTTPErrorStatusCode(w, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set(utils.ContentTypeHeaderKey, contentTypeTextHTML)
+		w.WriteHeader(statusCode)
+		w.Write([]byte(htmlBuilder.String()))
+
+	default:
+		utils.RespondWithJSONDTOAndStatusCode(response, statusCode, w)
+	}
+}
@@ -0,0 +1,114 @@
+package command
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateResponseFormat(t *testing.T) {
+	tests := map[string]struct {
+		url     string
+		accept  string
+		want    responseFormat
+		wantErr bool
+	}{
+		"default":             {url: "/", want: responseFormatJSON},
+		"curl":                {url: "/", accept: "*/*", want: responseFormatJSON},
+		"browser":             {url: "/", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", want: responseFormatHTML},
+		"text plain":          {url: "/", accept: "text/plain", want: responseFormatText},
+		"text wildcard":       {url: "/", accept: "text/*", want: responseFormatText},
+		"quality":             {url: "/", accept: "application/json;q=0.5, text/plain;q=0.9", want: responseFormatText},
+		"specific beats wild": {url: "/", accept: "text/*;q=0.9, text/plain;q=0.1", want: responseFormatHTML},
+		"not acceptable":      {url: "/", accept: "image/png", want: responseFormatJSON},
+		"query overrides":     {url: "/?format=text", accept: "text/html", want: responseFormatText},
+		"query html":          {url: "/?format=html", want: responseFormatHTML},
+		"query unknown":       {url: "/?format=xml", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			if tc.accept != "" {
+				r.Header.Set(acceptHeaderKey, tc.accept)
+			}
+
+			got, err := negotiateResponseFormat(r)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v want error %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Fatalf("got %q want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWriteCommandAPIResponse(t *testing.T) {
+	response := &commandAPIResponse{
+		CommandInfo: commandInfoDTO{
+			ID:          "test",
+			Description: "<test>",
+			Command:     "/bin/echo",
+		},
+		CommandOutput: "a < b\n",
+		ExitCode:      new(0),
+	}
+
+	tests := map[string]struct {
+		response        *commandAPIResponse
+		responseFormat  responseFormat
+		wantContentType string
+		wantBody        string
+	}{
+		"text": {
+			response:        response,
+			responseFormat:  responseFormatText,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "a < b\n",
+		},
+		"text binary": {
+			response:        &commandAPIResponse{CommandOutput: "//4=", CommandOutputEncoding: outputEncodingBase64},
+			responseFormat:  responseFormatText,
+			wantContentType: contentTypeApplicationOctet,
+			wantBody:        "\xff\xfe",
+		},
+		"text error": {
+			response:        &commandAPIResponse{Error: "error acquiring command semaphore"},
+			responseFormat:  responseFormatText,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "error acquiring command semaphore\n",
+		},
+		"html": {
+			response:        response,
+			responseFormat:  responseFormatHTML,
+			wantContentType: contentTypeTextHTML,
+			wantBody:        "<pre>a &lt; b\n</pre>",
+		},
+		"json": {
+			response:        response,
+			responseFormat:  responseFormatJSON,
+			wantContentType: "application/json",
+			wantBody:        `"command_output":"a < b\n"`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			writeCommandAPIResponse(w, tc.responseFormat, tc.response, http.StatusTeapot)
+
+			if w.Code != http.StatusTeapot {
+				t.Errorf("got status %d want %d", w.Code, http.StatusTeapot)
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != tc.wantContentType {
+				t.Errorf("got content type %q want %q", contentType, tc.wantContentType)
+			}
+			if body := w.Body.String(); !strings.Contains(body, tc.wantBody) {
+				t.Errorf("got body %q want it to contain %q", body, tc.wantBody)
+			}
+		})
+	}
+}
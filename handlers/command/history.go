@@ -0,0 +1,347 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aaronriekenberg/go-api/config"
+	"github.com/aaronriekenberg/go-api/utils"
+)
+
+// ringBuffer keeps the last capacity values added.
+type ringBuffer[T any] struct {
+	values []T
+	next   int
+	full   bool
+}
+
+func newRingBuffer[T any](capacity int) *ringBuffer[T] {
+	return &ringBuffer[T]{
+		values: make([]T, capacity),
+	}
+}
+
+func (ringBuffer *ringBuffer[T]) add(value T) {
+	ringBuffer.values[ringBuffer.next] = value
+	ringBuffer.next = (ringBuffer.next + 1) % len(ringBuffer.values)
+	if ringBuffer.next == 0 {
+		ringBuffer.full = true
+	}
+}
+
+// all returns the values oldest first.
+func (ringBuffer *ringBuffer[T]) all() []T {
+	if !ringBuffer.full {
+		return append([]T(nil), ringBuffer.values[:ringBuffer.next]...)
+	}
+	return append(
+		append([]T(nil), ringBuffer.values[ringBuffer.next:]...),
+		ringBuffer.values[:ringBuffer.next]...,
+	)
+}
+
+// commandHistory is the results of a scheduled command.  It is passed on to the scheduler of
+// a reloaded configuration, so a run in progress during the reload is not lost.
+type commandHistory struct {
+	mutex   sync.Mutex
+	results *ringBuffer[commandAPIResponse]
+}
+
+func newCommandHistory(size int) *commandHistory {
+	return &commandHistory{
+		results: newRingBuffer[commandAPIResponse](size),
+	}
+}
+
+func (commandHistory *commandHistory) add(response commandAPIResponse) {
+	commandHistory.mutex.Lock()
+	defer commandHistory.mutex.Unlock()
+
+	commandHistory.results.add(response)
+}
+
+func (commandHistory *commandHistory) all() []commandAPIResponse {
+	commandHistory.mutex.Lock()
+	defer commandHistory.mutex.Unlock()
+
+	return commandHistory.results.all()
+}
+
+func (commandHistory *commandHistory) size() int {
+	commandHistory.mutex.Lock()
+	defer commandHistory.mutex.Unlock()
+
+	return len(commandHistory.results.values)
+}
+
+// resize keeps the last size results.
+func (commandHistory *commandHistory) resize(size int) {
+	commandHistory.mutex.Lock()
+	defer commandHistory.mutex.Unlock()
+
+	if size == len(commandHistory.results.values) {
+		return
+	}
+
+	results := newRingBuffer[commandAPIResponse](size)
+	for _, response := range commandHistory.results.all() {
+		results.add(response)
+	}
+	commandHistory.results = results
+}
+
+type commandSchedule interface {
+	// next returns the time of the run following after, or the zero time if there is none.
+	next(after time.Time) time.Time
+	String() string
+}
+
+type intervalSchedule time.Duration
+
+func (intervalSchedule intervalSchedule) next(after time.Time) time.Time {
+	return after.Add(time.Duration(intervalSchedule))
+}
+
+func (intervalSchedule intervalSchedule) String() string {
+	return "every " + time.Duration(intervalSchedule).String()
+}
+
+func newCommandSchedule(
+	scheduleConfiguration config.ScheduleConfiguration,
+) (commandSchedule, error) {
+	var commandSchedule commandSchedule
+
+	switch {
+	case scheduleConfiguration.IntervalDuration > 0 && scheduleConfiguration.Cron != "":
+		return nil, errors.New("schedule has both IntervalDuration and Cron")
+
+	case scheduleConfiguration.IntervalDuration > 0:
+		commandSchedule = intervalSchedule(scheduleConfiguration.IntervalDuration)
+
+	case scheduleConfiguration.Cron != "":
+		cronSchedule, err := parseCronSchedule(scheduleConfiguration.Cron)
+		if err != nil {
+			return nil, err
+		}
+		if cronSchedule.next(time.Now()).IsZero() {
+			return nil, fmt.Errorf("cron expression %q never matches", scheduleConfiguration.Cron)
+		}
+		commandSchedule = cronSchedule
+
+	default:
+		return nil, errors.New("schedule needs a positive IntervalDuration or a Cron expression")
+	}
+
+	if scheduleConfiguration.HistorySize <= 0 {
+		return nil, fmt.Errorf("invalid schedule HistorySize %d", scheduleConfiguration.HistorySize)
+	}
+
+	return commandSchedule, nil
+}
+
+// scheduledCommand is one command run in the background on its schedule.
+type scheduledCommand struct {
+	commandInfo config.CommandInfo
+	schedule    commandSchedule
+
+	history     *commandHistory
+	mutex       sync.Mutex
+	nextRunTime time.Time
+}
+
+type commandHistoryDTO struct {
+	CommandInfo commandInfoDTO       `json:"command_info"`
+	Schedule    string               `json:"schedule"`
+	HistorySize int                  `json:"history_size"`
+	NextRunTime *time.Time           `json:"next_run_time,omitempty"`
+	Results     []commandAPIResponse `json:"results"`
+}
+
+func (scheduledCommand *scheduledCommand) toDTO() commandHistoryDTO {
+	scheduledCommand.mutex.Lock()
+	defer scheduledCommand.mutex.Unlock()
+
+	commandHistoryDTO := commandHistoryDTO{
+		CommandInfo: commandInfoToDTO(scheduledCommand.commandInfo),
+		Schedule:    scheduledCommand.schedule.String(),
+		HistorySize: scheduledCommand.history.size(),
+		Results:     scheduledCommand.history.all(),
+	}
+
+	if !scheduledCommand.nextRunTime.IsZero() {
+		commandHistoryDTO.NextRunTime = new(scheduledCommand.nextRunTime)
+	}
+
+	return commandHistoryDTO
+}
+
+func (scheduledCommand *scheduledCommand) setNextRunTime(nextRunTime time.Time) {
+	scheduledCommand.mutex.Lock()
+	defer scheduledCommand.mutex.Unlock()
+
+	scheduledCommand.nextRunTime = nextRunTime
+}
+
+// commandScheduler runs scheduled commands under the same semaphore and limits as requests.
+type commandScheduler struct {
+	commandRunner        *commandRunner
+	idToScheduledCommand map[string]*scheduledCommand
+	cancel               context.CancelFunc
+}
+
+// newCommandScheduler validates the schedules of commandInfos.
+// A command scheduled in previous keeps its commandHistory, resized to its new history size.
+func newCommandScheduler(
+	commandRunner *commandRunner,
+	commandInfos []config.CommandInfo,
+	previous *commandScheduler,
+) (*commandScheduler, error) {
+	idToScheduledCommand := make(map[string]*scheduledCommand)
+
+	for _, commandInfo := range commandInfos {
+		if commandInfo.Schedule == nil {
+			continue
+		}
+
+		schedule, err := newCommandSchedule(*commandInfo.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("command %q newCommandSchedule error: %w", commandInfo.ID, err)
+		}
+
+		commandInfo, err = commandRunner.commandInfoWithDefaultArgs(commandInfo)
+		if err != nil {
+			return nil, fmt.Errorf("command %q commandInfoWithDefaultArgs error: %w", commandInfo.ID, err)
+		}
+
+		var history *commandHistory
+		if previous != nil {
+			if previousScheduledCommand, ok := previous.idToScheduledCommand[commandInfo.ID]; ok {
+				history = previousScheduledCommand.history
+				history.resize(commandInfo.Schedule.HistorySize)
+			}
+		}
+		if history == nil {
+			history = newCommandHistory(commandInfo.Schedule.HistorySize)
+		}
+
+		idToScheduledCommand[commandInfo.ID] = &scheduledCommand{
+			commandInfo: commandInfo,
+			schedule:    schedule,
+			history:     history,
+		}
+	}
+
+	return &commandScheduler{
+		commandRunner:        commandRunner,
+		idToScheduledCommand: idToScheduledCommand,
+	}, nil
+}
+
+func (commandScheduler *commandScheduler) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	commandScheduler.cancel = cancel
+
+	for _, scheduledCommand := range commandScheduler.idToScheduledCommand {
+		slog.Info("starting scheduled command",
+			"id", scheduledCommand.commandInfo.ID,
+			"schedule", scheduledCommand.schedule.String(),
+		)
+
+		go commandScheduler.runScheduledCommand(ctx, scheduledCommand)
+	}
+}
+
+// stop stops scheduling runs, a run in progress finishes.
+func (commandScheduler *commandScheduler) stop() {
+	if commandScheduler.cancel != nil {
+		commandScheduler.cancel()
+	}
+}
+
+func (commandScheduler *commandScheduler) runScheduledCommand(
+	ctx context.Context,
+	scheduledCommand *scheduledCommand,
+) {
+	for {
+		nextRunTime := scheduledCommand.schedule.next(time.Now())
+		scheduledCommand.setNextRunTime(nextRunTime)
+
+		if nextRunTime.IsZero() {
+			slog.Warn("scheduled command has no next run time",
+				"id", scheduledCommand.commandInfo.ID,
+			)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(nextRunTime))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		scheduledCommand.history.add(
+			commandScheduler.runCommand(context.WithoutCancel(ctx), scheduledCommand.commandInfo),
+		)
+	}
+}
+
+func (commandScheduler *commandScheduler) runCommand(
+	ctx context.Context,
+	commandInfo config.CommandInfo,
+) commandAPIResponse {
+	commandRunner := commandScheduler.commandRunner
+
+	// scheduled runs are configured by the operator, not requested from outside
+	err := commandRunner.acquireCommandSemaphore(ctx, commandInfo, admissionClassInternal)
+	if err != nil {
+		slog.Warn("commandScheduler.runCommand semaphore acquire error",
+			"id", commandInfo.ID,
+			"error", err,
+		)
+		return semaphoreRejectionResponse(commandInfo, err)
+	}
+	defer commandRunner.releaseCommandSemaphore(commandInfo, admissionClassInternal)
+
+	ctx, cancel := context.WithTimeout(ctx, commandRunner.requestTimeout(commandInfo))
+	defer cancel()
+
+	response, commandErr := commandRunner.executeCommand(ctx, commandInfo)
+	if commandErr != nil {
+		slog.Warn("commandScheduler.runCommand command error",
+			"id", commandInfo.ID,
+			"errorKind", response.ErrorKind,
+			"error", commandErr,
+		)
+	}
+
+	return response
+}
+
+func (commands *Commands) NewCommandHistoryHandler() http.Handler {
+	commandScheduler := commands.commandScheduler
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commandInfo, ok := commandScheduler.commandRunner.commandInfoForRequest(r)
+		if !ok {
+			utils.HTTPErrorStatusCode(w, http.StatusNotFound)
+			return
+		}
+
+		scheduledCommand, ok := commandScheduler.idToScheduledCommand[commandInfo.ID]
+		if !ok {
+			utils.HTTPErrorStatusCode(w, http.StatusNotFound)
+			return
+		}
+
+		commandHistoryDTO := scheduledCommand.toDTO()
+
+		utils.RespondWithJSONDTO(&commandHistoryDTO, w)
+	})
+}
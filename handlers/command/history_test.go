@@ -0,0 +1,56 @@
+package command
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aaronriekenberg/go-api/config"
+)
+
+func TestRingBuffer(t *testing.T) {
+	ringBuffer := newRingBuffer[int](3)
+
+	if values := ringBuffer.all(); len(values) != 0 {
+		t.Fatalf("got values %v want empty", values)
+	}
+
+	ringBuffer.add(1)
+	ringBuffer.add(2)
+
+	if values := ringBuffer.all(); !slices.Equal(values, []int{1, 2}) {
+		t.Fatalf("got values %v want [1 2]", values)
+	}
+
+	ringBuffer.add(3)
+	ringBuffer.add(4)
+	ringBuffer.add(5)
+
+	if values := ringBuffer.all(); !slices.Equal(values, []int{3, 4, 5}) {
+		t.Fatalf("got values %v want [3 4 5]", values)
+	}
+}
+
+func TestNewCommandSchedule(t *testing.T) {
+	tests := map[string]struct {
+		scheduleConfiguration config.ScheduleConfiguration
+		wantErr               bool
+	}{
+		"interval":         {scheduleConfiguration: config.ScheduleConfiguration{IntervalDuration: time.Minute, HistorySize: 10}},
+		"cron":             {scheduleConfiguration: config.ScheduleConfiguration{Cron: "*/5 * * * *", HistorySize: 10}},
+		"both":             {scheduleConfiguration: config.ScheduleConfiguration{IntervalDuration: time.Minute, Cron: "* * * * *", HistorySize: 10}, wantErr: true},
+		"neither":          {scheduleConfiguration: config.ScheduleConfiguration{HistorySize: 10}, wantErr: true},
+		"no history":       {scheduleConfiguration: config.ScheduleConfiguration{IntervalDuration: time.Minute}, wantErr: true},
+		"bad cron":         {scheduleConfiguration: config.ScheduleConfiguration{Cron: "* * *", HistorySize: 10}, wantErr: true},
+		"cron never match": {scheduleConfiguration: config.ScheduleConfiguration{Cron: "0 0 30 2 *", HistorySize: 10}, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := newCommandSchedule(tc.scheduleConfiguration)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v want error %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+package command
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/aaronriekenberg/go-api/config"
+	"github.com/aaronriekenberg/go-api/utils"
+)
+
+// jobID is 128 random bits in hex, so the IDs of other jobs cannot be guessed.
+type jobID string
+
+func newJobID() jobID {
+	var id [16]byte
+	rand.Read(id[:])
+	return jobID(hex.EncodeToString(id[:]))
+}
+
+type jobState string
+
+const (
+	jobStateQueued  jobState = "queued"
+	jobStateRunning jobState = "running"
+	jobStateDone    jobState = "done"
+	jobStateFailed  jobState = "failed"
+)
+
+func (jobState jobState) finished() bool {
+	return jobState == jobStateDone || jobState == jobStateFailed
+}
+
+type job struct {
+	id          jobID
+	commandInfo config.CommandInfo
+	state       jobState
+	createTime  time.Time
+	startTime   time.Time
+	endTime     time.Time
+	result      *commandAPIResponse
+	err         error
+}
+
+type jobDTO struct {
+	ID          jobID               `json:"id"`
+	CommandInfo commandInfoDTO      `json:"command_info"`
+	State       jobState            `json:"state"`
+	CreateTime  time.Time           `json:"create_time"`
+	StartTime   *time.Time          `json:"start_time,omitempty"`
+	EndTime     *time.Time          `json:"end_time,omitempty"`
+	Result      *commandAPIResponse `json:"result,omitempty"`
+	Error       string              `json:"error,omitempty"`
+}
+
+func jobToDTO(job *job) jobDTO {
+	jobDTO := jobDTO{
+		ID:          job.id,
+		CommandInfo: commandInfoToDTO(job.commandInfo),
+		State:       job.state,
+		CreateTime:  job.createTime,
+		Result:      job.result,
+	}
+
+	if !job.startTime.IsZero() {
+		jobDTO.StartTime = new(job.startTime)
+	}
+
+	if !job.endTime.IsZero() {
+		jobDTO.EndTime = new(job.endTime)
+	}
+
+	if job.err != nil {
+		jobDTO.Error = job.err.Error()
+	}
+
+	return jobDTO
+}
+
+var errorJobStoreFull = errors.New("job store full")
+
+// jobStore holds at most maxJobs jobs.  Finished jobs expire ttl after they end.
+type jobStore struct {
+	mutex    sync.Mutex
+	maxJobs  int
+	ttl      time.Duration
+	idToJob  map[jobID]*job
+	jobOrder []jobID
+}
+
+func newJobStore(
+	maxJobs int,
+	ttl time.Duration,
+) *jobStore {
+	return &jobStore{
+		maxJobs: maxJobs,
+		ttl:     ttl,
+		idToJob: make(map[jobID]*job),
+	}
+}
+
+// setLimits applies to jobs added from now on.
+func (jobStore *jobStore) setLimits(
+	maxJobs int,
+	ttl time.Duration,
+) {
+	jobStore.mutex.Lock()
+	defer jobStore.mutex.Unlock()
+
+	jobStore.maxJobs = maxJobs
+	jobStore.ttl = ttl
+}
+
+// removeJobsLocked removes jobs matching shouldRemove, up to limit jobs in creation order.
+func (jobStore *jobStore) removeJobsLocked(
+	limit int,
+	shouldRemove func(job *job) bool,
+) {
+	removed := 0
+	newJobOrder := jobStore.jobOrder[:0]
+
+	for _, id := range jobStore.jobOrder {
+		job := jobStore.idToJob[id]
+		if removed < limit && shouldRemove(job) {
+			delete(jobStore.idToJob, id)
+			removed++
+		} else {
+			newJobOrder = append(newJobOrder, id)
+		}
+	}
+
+	jobStore.jobOrder = newJobOrder
+}
+
+func (jobStore *jobStore) removeExpiredJobsLocked(now time.Time) {
+	jobStore.removeJobsLocked(len(jobStore.jobOrder), func(job *job) bool {
+		return job.state.finished() && now.Sub(job.endTime) >= jobStore.ttl
+	})
+}
+
+func (jobStore *jobStore) addJob(
+	commandInfo config.CommandInfo,
+) (jobDTO, error) {
+	jobStore.mutex.Lock()
+	defer jobStore.mutex.Unlock()
+
+	now := time.Now()
+
+	jobStore.removeExpiredJobsLocked(now)
+
+	if len(jobStore.idToJob) >= jobStore.maxJobs {
+		// evict the oldest finished job to make room
+		jobStore.removeJobsLocked(1, func(job *job) bool {
+			return job.state.finished()
+		})
+	}
+
+	if len(jobStore.idToJob) >= jobStore.maxJobs {
+		return jobDTO{}, errorJobStoreFull
+	}
+
+	job := &job{
+		id:          newJobID(),
+		commandInfo: commandInfo,
+		state:       jobStateQueued,
+		createTime:  now,
+	}
+
+	jobStore.idToJob[job.id] = job
+	jobStore.jobOrder = append(jobStore.jobOrder, job.id)
+
+	return jobToDTO(job), nil
+}
+
+func (jobStore *jobStore) getJob(
+	id jobID,
+) (jobDTO jobDTO, ok bool) {
+	jobStore.mutex.Lock()
+	defer jobStore.mutex.Unlock()
+
+	jobStore.removeExpiredJobsLocked(time.Now())
+
+	job, ok := jobStore.idToJob[id]
+	if ok {
+		jobDTO = jobToDTO(job)
+	}
+	return
+}
+
+func (jobStore *jobStore) updateJob(
+	id jobID,
+	update func(job *job),
+) {
+	jobStore.mutex.Lock()
+	defer jobStore.mutex.Unlock()
+
+	if job, ok := jobStore.idToJob[id]; ok {
+		update(job)
+	}
+}
+
+func (jobStore *jobStore) markJobRunning(id jobID) {
+	jobStore.updateJob(id, func(job *job) {
+		job.state = jobStateRunning
+		job.startTime = time.Now()
+	})
+}
+
+func (jobStore *jobStore) markJobFinished(
+	id jobID,
+	result *commandAPIResponse,
+	err error,
+) {
+	jobStore.updateJob(id, func(job *job) {
+		if err != nil {
+			job.state = jobStateFailed
+		} else {
+			job.state = jobStateDone
+		}
+		job.endTime = time.Now()
+		job.result = result
+		job.err = err
+	})
+}
+
+type jobsHandler struct {
+	commandRunner *commandRunner
+	jobStore      *jobStore
+	jobTimeout    time.Duration
+}
+
+// newJobsHandler takes over the jobs of previous, so jobs outlive a configuration reload.
+func newJobsHandler(
+	commandRunner *commandRunner,
+	jobConfiguration config.JobConfiguration,
+	previous *jobsHandler,
+) *jobsHandler {
+	jobConfiguration = jobConfiguration.WithDefaults()
+
+	slog.Info("creating jobsHandler",
+		"jobConfiguration", &jobConfiguration,
+	)
+
+	var jobStore *jobStore
+	if previous != nil {
+		jobStore = previous.jobStore
+		jobStore.setLimits(
+			jobConfiguration.MaxJobs,
+			jobConfiguration.JobTTLDuration,
+		)
+	} else {
+		jobStore = newJobStore(
+			jobConfiguration.MaxJobs,
+			jobConfiguration.JobTTLDuration,
+		)
+	}
+
+	return &jobsHandler{
+		commandRunner: commandRunner,
+		jobStore:      jobStore,
+		jobTimeout:    jobConfiguration.JobTimeoutDuration,
+	}
+}
+
+func (commands *Commands) NewCreateJobHandler() http.Handler {
+	return http.HandlerFunc(commands.jobsHandler.createJob)
+}
+
+func (commands *Commands) NewGetJobHandler() http.Handler {
+	return http.HandlerFunc(commands.jobsHandler.getJob)
+}
+
+func (jobsHandler *jobsHandler) createJob(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	commandInfo, ok := jobsHandler.commandRunner.runnableCommandInfoForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	jobDTO, err := jobsHandler.jobStore.addJob(commandInfo)
+	if err != nil {
+		slog.Warn("jobsHandler.createJob addJob error",
+			"error", err,
+		)
+		utils.HTTPErrorStatusCode(w, http.StatusTooManyRequests)
+		return
+	}
+
+	// the job outlives the request but keeps its context values
+	ctx := context.WithoutCancel(r.Context())
+
+	go jobsHandler.runJob(ctx, jobDTO.ID, commandInfo, jobsHandler.commandRunner.admissionClassForRequest(r))
+
+	w.Header().Set("Location", path.Join(r.URL.Path, string(jobDTO.ID)))
+	utils.RespondWithJSONDTOAndStatusCode(&jobDTO, http.StatusAccepted, w)
+}
+
+func (jobsHandler *jobsHandler) runJob(
+	ctx context.Context,
+	id jobID,
+	commandInfo config.CommandInfo,
+	admissionClass admissionClass,
+) {
+	ctx, cancel := context.WithTimeout(ctx, jobsHandler.jobTimeout)
+	defer cancel()
+
+	commandRunner := jobsHandler.commandRunner
+
+	// queued jobs wait for the semaphore up to the job timeout
+	err := commandRunner.waitForCommandSemaphore(ctx, commandInfo, admissionClass)
+	if err != nil {
+		slog.Warn("jobsHandler.runJob semaphore acquire error",
+			"jobID", id,
+			"error", err,
+		)
+		jobsHandler.jobStore.markJobFinished(id, nil, err)
+		return
+	}
+	defer commandRunner.releaseCommandSemaphore(commandInfo, admissionClass)
+
+	jobsHandler.jobStore.markJobRunning(id)
+
+	response, commandErr := commandRunner.executeCommand(ctx, commandInfo)
+
+	jobsHandler.jobStore.markJobFinished(id, &response, commandErr)
+}
+
+func (jobsHandler *jobsHandler) getJob(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	commandInfo, ok := jobsHandler.commandRunner.commandInfoForRequest(r)
+	if !ok {
+		utils.HTTPErrorStatusCode(w, http.StatusNotFound)
+		return
+	}
+
+	jobDTO, ok := jobsHandler.jobStore.getJob(jobID(r.PathValue("jobID")))
+	if !ok || jobDTO.CommandInfo.ID != commandInfo.ID {
+		utils.HTTPErrorStatusCode(w, http.StatusNotFound)
+		return
+	}
+
+	utils.RespondWithJSONDTO(&jobDTO, w)
+}
@@ -0,0 +1,83 @@
+package command
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aaronriekenberg/go-api/config"
+)
+
+func TestJobStoreEvictsOldestFinishedJob(t *testing.T) {
+	jobStore := newJobStore(2, time.Hour)
+
+	commandInfo := config.CommandInfo{ID: "test"}
+
+	job1, err := jobStore.addJob(commandInfo)
+	if err != nil {
+		t.Fatalf("addJob error: %v", err)
+	}
+
+	job2, err := jobStore.addJob(commandInfo)
+	if err != nil {
+		t.Fatalf("addJob error: %v", err)
+	}
+
+	// both jobs unfinished, store is full
+	if _, err := jobStore.addJob(commandInfo); !errors.Is(err, errorJobStoreFull) {
+		t.Fatalf("got error %v want %v", err, errorJobStoreFull)
+	}
+
+	jobStore.markJobFinished(job2.ID, nil, nil)
+
+	job3, err := jobStore.addJob(commandInfo)
+	if err != nil {
+		t.Fatalf("addJob error: %v", err)
+	}
+
+	if _, ok := jobStore.getJob(job2.ID); ok {
+		t.Errorf("job %v should have been evicted", job2.ID)
+	}
+
+	for _, id := range []jobID{job1.ID, job3.ID} {
+		jobDTO, ok := jobStore.getJob(id)
+		if !ok {
+			t.Fatalf("job %v not found", id)
+		}
+		if jobDTO.State != jobStateQueued {
+			t.Errorf("job %v got state %q want %q", id, jobDTO.State, jobStateQueued)
+		}
+	}
+}
+
+func TestJobStoreExpiresFinishedJobs(t *testing.T) {
+	jobStore := newJobStore(10, 0)
+
+	jobDTO, err := jobStore.addJob(config.CommandInfo{ID: "test"})
+	if err != nil {
+		t.Fatalf("addJob error: %v", err)
+	}
+
+	jobStore.markJobRunning(jobDTO.ID)
+
+	if _, ok := jobStore.getJob(jobDTO.ID); !ok {
+		t.Fatalf("running job should not expire")
+	}
+
+	jobStore.markJobFinished(jobDTO.ID, nil, errors.New("failed"))
+
+	if _, ok := jobStore.getJob(jobDTO.ID); ok {
+		t.Fatalf("finished job should have expired")
+	}
+}
+
+func TestNewJobID(t *testing.T) {
+	id1, id2 := newJobID(), newJobID()
+
+	if len(id1) != 32 {
+		t.Fatalf("got job ID %q want 32 hex digits", id1)
+	}
+	if id1 == id2 {
+		t.Fatalf("got equal job IDs %q", id1)
+	}
+}
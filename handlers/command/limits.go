@@ -0,0 +1,164 @@
+package command
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+
+	"github.com/aaronriekenberg/go-api/config"
+	"github.com/aaronriekenberg/go-api/utils"
+)
+
+// concurrencyLimit is a semaphore that also tracks how much of it is in use.
+type concurrencyLimit struct {
+	maxConcurrentCommands int64
+	semaphore             *semaphore.Weighted
+	inUse                 atomic.Int64
+}
+
+func newConcurrencyLimit(maxConcurrentCommands int64) *concurrencyLimit {
+	return &concurrencyLimit{
+		maxConcurrentCommands: maxConcurrentCommands,
+		semaphore:             semaphore.NewWeighted(maxConcurrentCommands),
+	}
+}
+
+func (concurrencyLimit *concurrencyLimit) acquire(ctx context.Context) error {
+	err := concurrencyLimit.semaphore.Acquire(ctx, 1)
+	if err != nil {
+		return err
+	}
+	concurrencyLimit.inUse.Add(1)
+	return nil
+}
+
+func (concurrencyLimit *concurrencyLimit) release() {
+	concurrencyLimit.inUse.Add(-1)
+	concurrencyLimit.semaphore.Release(1)
+}
+
+type concurrencyLimitDTO struct {
+	MaxConcurrentCommands int64 `json:"max_concurrent_commands"`
+	InUse                 int64 `json:"in_use"`
+}
+
+func (concurrencyLimit *concurrencyLimit) toDTO() *concurrencyLimitDTO {
+	return &concurrencyLimitDTO{
+		MaxConcurrentCommands: concurrencyLimit.maxConcurrentCommands,
+		InUse:                 concurrencyLimit.inUse.Load(),
+	}
+}
+
+// commandLimits are the effective limits of one command, per-command overrides layered over the global configuration.
+type commandLimits struct {
+	concurrencyLimit        *concurrencyLimit
+	requestTimeout          time.Duration
+	semaphoreAcquireTimeout time.Duration
+	maxOutputBytes          int64
+}
+
+func newCommandLimits(
+	commandConfiguration config.CommandConfiguration,
+	commandInfo config.CommandInfo,
+) commandLimits {
+	commandLimits := commandLimits{
+		requestTimeout:          commandConfiguration.RequestTimeoutDuration,
+		semaphoreAcquireTimeout: commandConfiguration.SemaphoreAcquireTimeoutDuration,
+		maxOutputBytes:          commandConfiguration.MaxOutput(),
+	}
+
+	if commandInfo.MaxConcurrentCommands > 0 {
+		commandLimits.concurrencyLimit = newConcurrencyLimit(commandInfo.MaxConcurrentCommands)
+	}
+
+	if commandInfo.RequestTimeoutDuration > 0 {
+		commandLimits.requestTimeout = commandInfo.RequestTimeoutDuration
+	}
+
+	if commandInfo.SemaphoreAcquireTimeoutDuration > 0 {
+		commandLimits.semaphoreAcquireTimeout = commandInfo.SemaphoreAcquireTimeoutDuration
+	}
+
+	if commandInfo.MaxOutputBytes > 0 {
+		commandLimits.maxOutputBytes = commandInfo.MaxOutputBytes
+	}
+
+	return commandLimits
+}
+
+type commandLimitsDTO struct {
+	ID                             string               `json:"id"`
+	RequestTimeout                 string               `json:"request_timeout"`
+	SemaphoreAcquireTimeout        string               `json:"semaphore_acquire_timeout"`
+	MaxOutputBytes                 int64                `json:"max_output_bytes"`
+	EffectiveMaxConcurrentCommands int64                `json:"effective_max_concurrent_commands"`
+	CommandConcurrency             *concurrencyLimitDTO `json:"command_concurrency,omitempty"`
+}
+
+type globalLimitsDTO struct {
+	RequestTimeout           string               `json:"request_timeout"`
+	SemaphoreAcquireTimeout  string               `json:"semaphore_acquire_timeout"`
+	MaxOutputBytes           int64                `json:"max_output_bytes"`
+	ReservedInternalCommands int64                `json:"reserved_internal_commands"`
+	Concurrency              *concurrencyLimitDTO `json:"concurrency"`
+	Admission                []admissionClassDTO  `json:"admission"`
+}
+
+type limitsDTO struct {
+	Global   globalLimitsDTO    `json:"global"`
+	Commands []commandLimitsDTO `json:"commands"`
+}
+
+func (commands *Commands) NewCommandLimitsHandler() http.Handler {
+	commandRunner := commands.commandRunner
+	commandConfiguration := commands.commandConfiguration
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestIsExternal := commandRunner.requestIsExternal(r)
+
+		globalConcurrency, admissionClassDTOs := commandRunner.admissionController.toDTO()
+
+		response := limitsDTO{
+			Global: globalLimitsDTO{
+				RequestTimeout:           commandConfiguration.RequestTimeoutDuration.String(),
+				SemaphoreAcquireTimeout:  commandConfiguration.SemaphoreAcquireTimeoutDuration.String(),
+				MaxOutputBytes:           commandConfiguration.MaxOutput(),
+				ReservedInternalCommands: commandConfiguration.AdmissionConfiguration.ReservedInternalCommands,
+				Concurrency:              globalConcurrency,
+				Admission:                admissionClassDTOs,
+			},
+			Commands: make([]commandLimitsDTO, 0, len(commandConfiguration.Commands)),
+		}
+
+		for _, commandInfo := range commandConfiguration.Commands {
+			if commandInfo.InternalOnly && requestIsExternal {
+				continue
+			}
+
+			commandLimits := commandRunner.idToLimits[commandInfo.ID]
+
+			commandLimitsDTO := commandLimitsDTO{
+				ID:                             commandInfo.ID,
+				RequestTimeout:                 commandLimits.requestTimeout.String(),
+				SemaphoreAcquireTimeout:        commandLimits.semaphoreAcquireTimeout.String(),
+				MaxOutputBytes:                 commandLimits.maxOutputBytes,
+				EffectiveMaxConcurrentCommands: commandRunner.admissionController.maxConcurrentCommands,
+			}
+
+			if commandLimits.concurrencyLimit != nil {
+				commandLimitsDTO.CommandConcurrency = commandLimits.concurrencyLimit.toDTO()
+				commandLimitsDTO.EffectiveMaxConcurrentCommands = min(
+					commandLimits.concurrencyLimit.maxConcurrentCommands,
+					commandLimitsDTO.EffectiveMaxConcurrentCommands,
+				)
+			}
+
+			response.Commands = append(response.Commands, commandLimitsDTO)
+		}
+
+		utils.RespondWithJSONDTO(&response, w)
+	})
+}
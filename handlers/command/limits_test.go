@@ -0,0 +1,74 @@
+package command
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aaronriekenberg/go-api/config"
+)
+
+func TestNewCommandLimits(t *testing.T) {
+	commandConfiguration := config.CommandConfiguration{
+		MaxConcurrentCommands:           10,
+		RequestTimeoutDuration:          2 * time.Second,
+		SemaphoreAcquireTimeoutDuration: 200 * time.Millisecond,
+	}
+
+	defaultLimits := newCommandLimits(commandConfiguration, config.CommandInfo{ID: "uptime"})
+
+	if defaultLimits.concurrencyLimit != nil {
+		t.Errorf("command without override should have no command concurrency limit")
+	}
+	if defaultLimits.requestTimeout != 2*time.Second {
+		t.Errorf("got requestTimeout %v", defaultLimits.requestTimeout)
+	}
+	if defaultLimits.semaphoreAcquireTimeout != 200*time.Millisecond {
+		t.Errorf("got semaphoreAcquireTimeout %v", defaultLimits.semaphoreAcquireTimeout)
+	}
+	if defaultLimits.maxOutputBytes != config.DefaultMaxOutputBytes {
+		t.Errorf("got maxOutputBytes %v", defaultLimits.maxOutputBytes)
+	}
+
+	overrideLimits := newCommandLimits(commandConfiguration, config.CommandInfo{
+		ID:                              "top",
+		MaxConcurrentCommands:           1,
+		RequestTimeoutDuration:          10 * time.Second,
+		SemaphoreAcquireTimeoutDuration: time.Second,
+	})
+
+	if overrideLimits.concurrencyLimit == nil || overrideLimits.concurrencyLimit.maxConcurrentCommands != 1 {
+		t.Errorf("got concurrencyLimit %v", overrideLimits.concurrencyLimit)
+	}
+	if overrideLimits.requestTimeout != 10*time.Second {
+		t.Errorf("got requestTimeout %v", overrideLimits.requestTimeout)
+	}
+	if overrideLimits.semaphoreAcquireTimeout != time.Second {
+		t.Errorf("got semaphoreAcquireTimeout %v", overrideLimits.semaphoreAcquireTimeout)
+	}
+}
+
+func TestConcurrencyLimitInUse(t *testing.T) {
+	concurrencyLimit := newConcurrencyLimit(1)
+
+	if err := concurrencyLimit.acquire(context.Background()); err != nil {
+		t.Fatalf("acquire error: %v", err)
+	}
+
+	if inUse := concurrencyLimit.toDTO().InUse; inUse != 1 {
+		t.Errorf("got inUse %d want 1", inUse)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := concurrencyLimit.acquire(ctx); err == nil {
+		t.Errorf("second acquire should fail")
+	}
+
+	concurrencyLimit.release()
+
+	if inUse := concurrencyLimit.toDTO().InUse; inUse != 0 {
+		t.Errorf("got inUse %d want 0", inUse)
+	}
+}
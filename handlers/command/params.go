@@ -0,0 +1,213 @@
+package command
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aaronriekenberg/go-api/config"
+)
+
+type commandParameterDTO struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Required    bool     `json:"required"`
+	Enum        []string `json:"enum,omitempty"`
+	Regex       string   `json:"regex,omitempty"`
+	MinValue    *int64   `json:"min_value,omitempty"`
+	MaxValue    *int64   `json:"max_value,omitempty"`
+	Default     *string  `json:"default,omitempty"`
+}
+
+func commandParametersToDTOs(parameters []config.CommandParameter) []commandParameterDTO {
+	if len(parameters) == 0 {
+		return nil
+	}
+
+	dtos := make([]commandParameterDTO, 0, len(parameters))
+	for _, parameter := range parameters {
+		dtos = append(dtos, commandParameterDTO{
+			Name:        parameter.Name,
+			Description: parameter.Description,
+			Required:    parameter.Default == nil,
+			Enum:        slices.Clone(parameter.Enum),
+			Regex:       parameter.Regex,
+			MinValue:    parameter.MinValue,
+			MaxValue:    parameter.MaxValue,
+			Default:     parameter.Default,
+		})
+	}
+	return dtos
+}
+
+type parameterErrorDTO struct {
+	Parameter string `json:"parameter"`
+	Error     string `json:"error"`
+}
+
+// parametersError collects every invalid parameter in a request.
+type parametersError struct {
+	ParameterErrors []parameterErrorDTO `json:"parameter_errors"`
+}
+
+func (parametersError *parametersError) Error() string {
+	return fmt.Sprintf("invalid command parameters: %v", parametersError.ParameterErrors)
+}
+
+// argPlaceholderRegex matches a "{name}" placeholder in an arg, but not a shell "${name}".
+var argPlaceholderRegex = regexp.MustCompile(`(?:^|[^$])\{([A-Za-z0-9_.-]+)\}`)
+
+type commandParameter struct {
+	config.CommandParameter
+	regex *regexp.Regexp
+}
+
+func (commandParameter *commandParameter) validate(value string) error {
+	if len(commandParameter.Enum) > 0 && !slices.Contains(commandParameter.Enum, value) {
+		return fmt.Errorf("value must be one of %v", commandParameter.Enum)
+	}
+
+	if commandParameter.regex != nil && !commandParameter.regex.MatchString(value) {
+		return fmt.Errorf("value must match regex %q", commandParameter.Regex)
+	}
+
+	if commandParameter.MinValue != nil || commandParameter.MaxValue != nil {
+		intValue, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("value must be an integer")
+		}
+		if commandParameter.MinValue != nil && intValue < *commandParameter.MinValue {
+			return fmt.Errorf("value must be >= %d", *commandParameter.MinValue)
+		}
+		if commandParameter.MaxValue != nil && intValue > *commandParameter.MaxValue {
+			return fmt.Errorf("value must be <= %d", *commandParameter.MaxValue)
+		}
+	}
+
+	return nil
+}
+
+// commandParameters are the compiled parameters of one command.
+// Values are only ever substituted into individual args, never passed through a shell.
+// Every parameter has a validation rule, so a caller can not pass arbitrary options to the command.
+type commandParameters []commandParameter
+
+func (commandParameters commandParameters) contains(name string) bool {
+	return slices.ContainsFunc(commandParameters, func(commandParameter commandParameter) bool {
+		return commandParameter.Name == name
+	})
+}
+
+func newCommandParameters(
+	commandInfo config.CommandInfo,
+) (commandParameters, error) {
+	parameters := make(commandParameters, 0, len(commandInfo.Parameters))
+
+	for _, configParameter := range commandInfo.Parameters {
+		if configParameter.Name == formatQueryParameter {
+			return nil, fmt.Errorf("command %q parameter name %q is reserved", commandInfo.ID, configParameter.Name)
+		}
+
+		if len(configParameter.Enum) == 0 && configParameter.Regex == "" &&
+			configParameter.MinValue == nil && configParameter.MaxValue == nil {
+			return nil, fmt.Errorf("command %q parameter %q needs an enum, regex, minValue or maxValue", commandInfo.ID, configParameter.Name)
+		}
+
+		parameter := commandParameter{
+			CommandParameter: configParameter,
+		}
+
+		if configParameter.Regex != "" {
+			regex, err := regexp.Compile("^(?:" + configParameter.Regex + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("command %q parameter %q regexp.Compile error: %w", commandInfo.ID, configParameter.Name, err)
+			}
+			parameter.regex = regex
+		}
+
+		if configParameter.Default != nil {
+			if err := parameter.validate(*configParameter.Default); err != nil {
+				return nil, fmt.Errorf("command %q parameter %q invalid default: %w", commandInfo.ID, configParameter.Name, err)
+			}
+		}
+
+		parameters = append(parameters, parameter)
+	}
+
+	for _, arg := range commandInfo.Args {
+		for _, match := range argPlaceholderRegex.FindAllStringSubmatch(arg, -1) {
+			if !parameters.contains(match[1]) {
+				return nil, fmt.Errorf("command %q arg %q placeholder {%s} is not a parameter", commandInfo.ID, arg, match[1])
+			}
+		}
+	}
+
+	return parameters, nil
+}
+
+// resolveArgs validates query against the parameters and returns args with every "{name}" replaced.
+func (commandParameters commandParameters) resolveArgs(
+	args []string,
+	query url.Values,
+) ([]string, error) {
+	if len(commandParameters) == 0 {
+		return args, nil
+	}
+
+	var parametersError parametersError
+
+	replacements := make([]string, 0, 2*len(commandParameters))
+
+	for _, parameter := range commandParameters {
+		values, present := query[parameter.Name]
+
+		var value string
+		switch {
+		case len(values) > 1:
+			parametersError.ParameterErrors = append(parametersError.ParameterErrors, parameterErrorDTO{
+				Parameter: parameter.Name,
+				Error:     "parameter specified more than once",
+			})
+			continue
+
+		case present:
+			value = values[0]
+
+		case parameter.Default != nil:
+			value = *parameter.Default
+
+		default:
+			parametersError.ParameterErrors = append(parametersError.ParameterErrors, parameterErrorDTO{
+				Parameter: parameter.Name,
+				Error:     "missing required parameter",
+			})
+			continue
+		}
+
+		if err := parameter.validate(value); err != nil {
+			parametersError.ParameterErrors = append(parametersError.ParameterErrors, parameterErrorDTO{
+				Parameter: parameter.Name,
+				Error:     err.Error(),
+			})
+			continue
+		}
+
+		replacements = append(replacements, "{"+parameter.Name+"}", value)
+	}
+
+	if len(parametersError.ParameterErrors) > 0 {
+		return nil, &parametersError
+	}
+
+	replacer := strings.NewReplacer(replacements...)
+
+	resolvedArgs := make([]string, 0, len(args))
+	for _, arg := range args {
+		resolvedArgs = append(resolvedArgs, replacer.Replace(arg))
+	}
+
+	return resolvedArgs, nil
+}
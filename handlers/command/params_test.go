@@ -0,0 +1,128 @@
+package command
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/aaronriekenberg/go-api/config"
+)
+
+func TestResolveArgs(
+	t *testing.T,
+) {
+	commandInfo := config.CommandInfo{
+		ID:   "journalctl",
+		Args: []string{"-u", "{unit}", "-n", "{lines}", "${notaparameter}"},
+		Parameters: []config.CommandParameter{
+			{Name: "unit", Regex: `[a-z-]+\.service`},
+			{Name: "lines", MinValue: new(int64(1)), MaxValue: new(int64(100)), Default: new("10")},
+		},
+	}
+
+	parameters, err := newCommandParameters(commandInfo)
+	if err != nil {
+		t.Fatalf("newCommandParameters error: %v", err)
+	}
+
+	tests := map[string]struct {
+		wantArgs   []string
+		wantErrors []string
+	}{
+		"unit=go-api.service":           {wantArgs: []string{"-u", "go-api.service", "-n", "10", "${notaparameter}"}},
+		"unit=go-api.service&lines=50":  {wantArgs: []string{"-u", "go-api.service", "-n", "50", "${notaparameter}"}},
+		"lines=50":                      {wantErrors: []string{"unit"}},
+		"unit=go-api.service%3Brm":      {wantErrors: []string{"unit"}},
+		"unit=go-api.service&lines=0":   {wantErrors: []string{"lines"}},
+		"unit=x&lines=abc":              {wantErrors: []string{"unit", "lines"}},
+		"unit=a.service&unit=b.service": {wantErrors: []string{"unit"}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			query, err := url.ParseQuery(name)
+			if err != nil {
+				t.Fatalf("url.ParseQuery error: %v", err)
+			}
+
+			args, err := parameters.resolveArgs(commandInfo.Args, query)
+
+			var parametersError *parametersError
+			if errors.As(err, &parametersError) {
+				var gotErrors []string
+				for _, parameterError := range parametersError.ParameterErrors {
+					gotErrors = append(gotErrors, parameterError.Parameter)
+				}
+				if !slices.Equal(gotErrors, tc.wantErrors) {
+					t.Fatalf("got errors %v want %v", gotErrors, tc.wantErrors)
+				}
+				return
+			}
+
+			if err != nil || len(tc.wantErrors) > 0 {
+				t.Fatalf("got error %v want errors %v", err, tc.wantErrors)
+			}
+
+			if !slices.Equal(args, tc.wantArgs) {
+				t.Fatalf("got args %q want %q", args, tc.wantArgs)
+			}
+		})
+	}
+}
+
+func TestNewCommandParametersInvalidDefault(t *testing.T) {
+	_, err := newCommandParameters(config.CommandInfo{
+		ID: "test",
+		Parameters: []config.CommandParameter{
+			{Name: "mode", Enum: []string{"fast", "slow"}, Default: new("medium")},
+		},
+	})
+	if err == nil {
+		t.Fatal("expected error for default outside of enum")
+	}
+}
+
+func TestNewCommandParametersReservedName(t *testing.T) {
+	_, err := newCommandParameters(config.CommandInfo{
+		ID: "test",
+		Parameters: []config.CommandParameter{
+			{Name: formatQueryParameter},
+		},
+	})
+	if err == nil {
+		t.Fatal("expected error for reserved parameter name")
+	}
+}
+
+func TestNewCommandParametersInvalid(t *testing.T) {
+	tests := map[string]struct {
+		args       []string
+		parameters []config.CommandParameter
+	}{
+		"no validation rule": {
+			args:       []string{"{unit}"},
+			parameters: []config.CommandParameter{{Name: "unit"}},
+		},
+		"undeclared placeholder": {
+			args:       []string{"-u", "{unit}", "--since={since}"},
+			parameters: []config.CommandParameter{{Name: "unit", Regex: `[a-z-]+\.service`}},
+		},
+		"placeholder without parameters": {
+			args: []string{"{unit}"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := newCommandParameters(config.CommandInfo{
+				ID:         "test",
+				Args:       test.args,
+				Parameters: test.parameters,
+			})
+			if err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
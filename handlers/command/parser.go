@@ -0,0 +1,257 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json/jsontext"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/aaronriekenberg/go-api/config"
+)
+
+const (
+	parserTypeTable    = "table"
+	parserTypeKeyValue = "key_value"
+	parserTypeRegex    = "regex"
+	parserTypeJSON     = "json"
+)
+
+// builtinParserConfigurations are parsers for the output of common system commands.
+var builtinParserConfigurations = map[string]config.ParserConfiguration{
+	"chronyc_sources": {
+		Type:  parserTypeRegex,
+		Regex: `(?m)^(?P<mode>[\^=#])(?P<state>[*+\-?x~ ])\s+(?P<name>\S+)\s+(?P<stratum>\d+)\s+(?P<poll>\d+)\s+(?P<reach>[0-7]+)\s+(?P<last_rx>\S+)\s+(?P<last_sample>.*\S)\s*$`,
+	},
+	"chronyc_sourcestats": {
+		Type:  parserTypeRegex,
+		Regex: `(?m)^(?P<name>\S+)\s+(?P<np>\d+)\s+(?P<nr>\d+)\s+(?P<span>\S+)\s+(?P<frequency>\S+)\s+(?P<freq_skew>\S+)\s+(?P<offset>\S+)\s+(?P<std_dev>\S+)\s*$`,
+	},
+	"chronyc_tracking": {
+		Type: parserTypeKeyValue,
+	},
+	"df": {
+		// the "Mounted on" header is two words
+		Type:       parserTypeTable,
+		MaxColumns: 6,
+	},
+	"git_log": {
+		Type:  parserTypeRegex,
+		Regex: `(?s)commit (?P<commit>\S+).*?\nAuthor:\s+(?P<author>[^\n]*)\nDate:\s+(?P<date>[^\n]*)\n\n\s*(?P<message>.*?)\s*$`,
+	},
+	"ip_addr": {
+		Type:  parserTypeRegex,
+		Regex: `(?m)^\s+(?P<family>inet6?)\s+(?P<address>\S+).*?\sscope\s+(?P<scope>\S+)`,
+	},
+	"lscpu": {
+		Type: parserTypeKeyValue,
+	},
+	"lscpu_e": {
+		Type: parserTypeTable,
+	},
+	"netstat": {
+		Type:  parserTypeRegex,
+		Regex: `(?m)^(?P<proto>(?:tcp|udp|raw)6?)\s+(?P<recv_q>\d+)\s+(?P<send_q>\d+)\s+(?P<local_address>\S+)\s+(?P<foreign_address>\S+)[ \t]*(?P<state>\S*)[ \t]*$`,
+	},
+	"sensors": {
+		Type:  parserTypeRegex,
+		Regex: `(?m)^(?P<label>[^:\n]+):\s+(?P<value>[+-]?[\d.]+)\s*(?P<unit>°C|RPM|V|W|A|J|%)?`,
+	},
+	"top": {
+		// 5 summary lines, then the process table
+		Type:      parserTypeTable,
+		SkipLines: 5,
+	},
+	"uptime": {
+		Type:  parserTypeRegex,
+		Regex: `(?m)^\s*(?P<time>\S+)\s+up\s+(?P<uptime>.+?),\s+(?P<users>\d+)\s+users?,\s+load averages?:\s+(?P<load_1>[\d.]+),?\s+(?P<load_5>[\d.]+),?\s+(?P<load_15>[\d.]+)`,
+	},
+	"vmstat": {
+		// skip the "procs ---memory---" group header
+		Type:      parserTypeTable,
+		SkipLines: 1,
+	},
+	"w": {
+		// skip the uptime line
+		Type:      parserTypeTable,
+		SkipLines: 1,
+	},
+}
+
+// outputParser turns command output into a value for parsed_output.
+type outputParser interface {
+	parse(output string) (any, error)
+}
+
+// newOutputParser returns nil if parserConfiguration is nil.
+func newOutputParser(
+	parserConfiguration *config.ParserConfiguration,
+) (outputParser, error) {
+	if parserConfiguration == nil {
+		return nil, nil
+	}
+
+	if parserConfiguration.Builtin != "" {
+		builtinParserConfiguration, ok := builtinParserConfigurations[parserConfiguration.Builtin]
+		if !ok {
+			return nil, fmt.Errorf("unknown builtin parser %q, valid builtin parsers are %v",
+				parserConfiguration.Builtin, slices.Sorted(maps.Keys(builtinParserConfigurations)))
+		}
+		if parserConfiguration.Type != "" {
+			return nil, fmt.Errorf("parser has both Builtin %q and Type %q", parserConfiguration.Builtin, parserConfiguration.Type)
+		}
+		parserConfiguration = &builtinParserConfiguration
+	}
+
+	switch parserConfiguration.Type {
+	case parserTypeTable:
+		return &tableParser{
+			skipLines:  parserConfiguration.SkipLines,
+			maxColumns: parserConfiguration.MaxColumns,
+		}, nil
+
+	case parserTypeKeyValue:
+		separator := parserConfiguration.Separator
+		if separator == "" {
+			separator = ":"
+		}
+		return &keyValueParser{
+			separator: separator,
+		}, nil
+
+	case parserTypeRegex:
+		regex, err := regexp.Compile(parserConfiguration.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parser Regex: %w", err)
+		}
+		if !slices.ContainsFunc(regex.SubexpNames(), func(name string) bool { return name != "" }) {
+			return nil, fmt.Errorf("parser Regex %q has no named groups", parserConfiguration.Regex)
+		}
+		return &regexParser{
+			regex: regex,
+		}, nil
+
+	case parserTypeJSON:
+		return jsonParser{}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown parser Type %q, valid types are %v",
+			parserConfiguration.Type, []string{parserTypeTable, parserTypeKeyValue, parserTypeRegex, parserTypeJSON})
+	}
+}
+
+// splitFields splits line around runs of whitespace.
+// If maxFields > 0 at most maxFields are returned, the last one holding the rest of the line.
+func splitFields(
+	line string,
+	maxFields int,
+) []string {
+	fields := strings.Fields(line)
+	if maxFields <= 0 || len(fields) <= maxFields {
+		return fields
+	}
+
+	rest := strings.TrimSpace(line)
+	for range maxFields - 1 {
+		end := strings.IndexFunc(rest, unicode.IsSpace)
+		rest = strings.TrimLeftFunc(rest[end:], unicode.IsSpace)
+	}
+
+	return append(fields[:maxFields-1], rest)
+}
+
+// tableParser returns one object per row, keyed by the header line's column names.
+type tableParser struct {
+	skipLines  int
+	maxColumns int
+}
+
+func (tableParser *tableParser) parse(output string) (any, error) {
+	lines := strings.Split(output, "\n")
+	lines = lines[min(tableParser.skipLines, len(lines)):]
+	lines = slices.DeleteFunc(lines, func(line string) bool {
+		return strings.TrimSpace(line) == ""
+	})
+
+	if len(lines) == 0 {
+		return nil, errors.New("table has no header line")
+	}
+
+	header := splitFields(lines[0], tableParser.maxColumns)
+
+	rows := make([]map[string]string, 0, len(lines)-1)
+	for _, line := range lines[1:] {
+		fields := splitFields(line, len(header))
+
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(fields) {
+				row[column] = fields[i]
+			} else {
+				row[column] = ""
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// keyValueParser returns one object of all "key<separator>value" lines.  Other lines are ignored.
+type keyValueParser struct {
+	separator string
+}
+
+func (keyValueParser *keyValueParser) parse(output string) (any, error) {
+	keyValues := make(map[string]string)
+
+	for line := range strings.Lines(output) {
+		key, value, ok := strings.Cut(line, keyValueParser.separator)
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			continue
+		}
+		keyValues[key] = strings.TrimSpace(value)
+	}
+
+	return keyValues, nil
+}
+
+// regexParser returns one object per match, keyed by the regex's named groups.
+type regexParser struct {
+	regex *regexp.Regexp
+}
+
+func (regexParser *regexParser) parse(output string) (any, error) {
+	names := regexParser.regex.SubexpNames()
+
+	allMatches := regexParser.regex.FindAllStringSubmatch(output, -1)
+
+	results := make([]map[string]string, 0, len(allMatches))
+	for _, matches := range allMatches {
+		result := make(map[string]string)
+		for i, name := range names {
+			if name != "" {
+				result[name] = matches[i]
+			}
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// jsonParser passes through output that is already JSON.
+type jsonParser struct{}
+
+func (jsonParser) parse(output string) (any, error) {
+	value := jsontext.Value(bytes.TrimSpace([]byte(output)))
+	if !value.IsValid() {
+		return nil, errors.New("output is not valid JSON")
+	}
+	return value, nil
+}
@@ -0,0 +1,197 @@
+package command
+
+import (
+	"encoding/json/jsontext"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/aaronriekenberg/go-api/config"
+)
+
+func TestSplitFields(t *testing.T) {
+	tests := map[string]struct {
+		line      string
+		maxFields int
+		want      []string
+	}{
+		"unlimited":    {line: "  a  b   c ", want: []string{"a", "b", "c"}},
+		"limit":        {line: "  a  b   c d ", maxFields: 2, want: []string{"a", "b   c d"}},
+		"limit unused": {line: "a b", maxFields: 3, want: []string{"a", "b"}},
+		"empty":        {line: "   ", want: []string{}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := splitFields(tc.line, tc.maxFields); !slices.Equal(got, tc.want) {
+				t.Fatalf("got %q want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewOutputParserBuiltins(t *testing.T) {
+	for name := range builtinParserConfigurations {
+		if _, err := newOutputParser(&config.ParserConfiguration{Builtin: name}); err != nil {
+			t.Errorf("builtin parser %q error: %v", name, err)
+		}
+	}
+}
+
+func TestNewOutputParserErrors(t *testing.T) {
+	tests := map[string]config.ParserConfiguration{
+		"unknown builtin":   {Builtin: "bogus"},
+		"builtin and type":  {Builtin: "df", Type: parserTypeTable},
+		"unknown type":      {Type: "bogus"},
+		"invalid regex":     {Type: parserTypeRegex, Regex: "("},
+		"regex no groups":   {Type: parserTypeRegex, Regex: "a+"},
+		"missing type":      {},
+		"regex empty regex": {Type: parserTypeRegex},
+	}
+
+	for name, parserConfiguration := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := newOutputParser(&parserConfiguration); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func TestOutputParsers(t *testing.T) {
+	tests := map[string]struct {
+		parserConfiguration config.ParserConfiguration
+		output              string
+		want                any
+	}{
+		"df": {
+			parserConfiguration: config.ParserConfiguration{Builtin: "df"},
+			output: "Filesystem      Size  Used Avail Use% Mounted on\n" +
+				"/dev/vda        252G   18G   80G  19% /\n" +
+				"/dev/vdb        450M  363M   53M  88% /mnt/with space\n",
+			want: []map[string]string{
+				{"Filesystem": "/dev/vda", "Size": "252G", "Used": "18G", "Avail": "80G", "Use%": "19%", "Mounted on": "/"},
+				{"Filesystem": "/dev/vdb", "Size": "450M", "Used": "363M", "Avail": "53M", "Use%": "88%", "Mounted on": "/mnt/with space"},
+			},
+		},
+		"vmstat": {
+			parserConfiguration: config.ParserConfiguration{Builtin: "vmstat"},
+			output: "procs -----------memory---------- ---swap--\n" +
+				" r  b   swpd   free   si   so\n" +
+				" 2  0      0 4288108    0    0\n",
+			want: []map[string]string{
+				{"r": "2", "b": "0", "swpd": "0", "free": "4288108", "si": "0", "so": "0"},
+			},
+		},
+		"top": {
+			parserConfiguration: config.ParserConfiguration{Builtin: "top"},
+			output: "top - 11:07:38 up 30 min,  0 user,  load average: 0.10, 0.38, 0.33\n" +
+				"Tasks:  58 total,   1 running,  57 sleeping,   0 stopped,   0 zombie\n" +
+				"%Cpu(s):  0.0 us,  0.0 sy\n" +
+				"MiB Mem :   6013.8 total,   4187.6 free\n" +
+				"MiB Swap:      0.0 total,      0.0 free\n" +
+				"\n" +
+				"  PID USER      PR  NI S COMMAND\n" +
+				"    1 root      20   0 S init\n",
+			want: []map[string]string{
+				{"PID": "1", "USER": "root", "PR": "20", "NI": "0", "S": "S", "COMMAND": "init"},
+			},
+		},
+		"uptime": {
+			parserConfiguration: config.ParserConfiguration{Builtin: "uptime"},
+			output:              " 11:07:38 up 2 days,  3:04,  1 user,  load average: 0.10, 0.38, 0.33\n",
+			want: []map[string]string{
+				{"time": "11:07:38", "uptime": "2 days,  3:04", "users": "1", "load_1": "0.10", "load_5": "0.38", "load_15": "0.33"},
+			},
+		},
+		"key value": {
+			parserConfiguration: config.ParserConfiguration{Builtin: "chronyc_tracking"},
+			output: "Reference ID    : A29FC87B (time.cloudflare.com)\n" +
+				"Ref time (UTC)  : Sun Oct 18 11:00:00 2026\n" +
+				"no separator\n",
+			want: map[string]string{
+				"Reference ID":   "A29FC87B (time.cloudflare.com)",
+				"Ref time (UTC)": "Sun Oct 18 11:00:00 2026",
+			},
+		},
+		"key value separator": {
+			parserConfiguration: config.ParserConfiguration{Type: parserTypeKeyValue, Separator: "="},
+			output:              "a=1\nb = 2=3\n",
+			want:                map[string]string{"a": "1", "b": "2=3"},
+		},
+		"regex": {
+			parserConfiguration: config.ParserConfiguration{Type: parserTypeRegex, Regex: `(?m)^(?P<key>\w+) (\d+)$`},
+			output:              "a 1\nb 2\nc\n",
+			want:                []map[string]string{{"key": "a"}, {"key": "b"}},
+		},
+		"regex no match": {
+			parserConfiguration: config.ParserConfiguration{Type: parserTypeRegex, Regex: `(?P<key>x)`},
+			output:              "abc",
+			want:                []map[string]string{},
+		},
+		"netstat": {
+			parserConfiguration: config.ParserConfiguration{Builtin: "netstat"},
+			output: "Active Internet connections (servers and established)\n" +
+				"Proto Recv-Q Send-Q Local Address           Foreign Address         State      \n" +
+				"tcp        0      0 0.0.0.0:2024            0.0.0.0:*               LISTEN     \n" +
+				"udp        0      0 0.0.0.0:68              0.0.0.0:*                          \n",
+			want: []map[string]string{
+				{"proto": "tcp", "recv_q": "0", "send_q": "0", "local_address": "0.0.0.0:2024", "foreign_address": "0.0.0.0:*", "state": "LISTEN"},
+				{"proto": "udp", "recv_q": "0", "send_q": "0", "local_address": "0.0.0.0:68", "foreign_address": "0.0.0.0:*", "state": ""},
+			},
+		},
+		"json": {
+			parserConfiguration: config.ParserConfiguration{Type: parserTypeJSON},
+			output:              " [{\"a\":1}]\n",
+			want:                jsontext.Value(`[{"a":1}]`),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			outputParser, err := newOutputParser(&tc.parserConfiguration)
+			if err != nil {
+				t.Fatalf("newOutputParser error: %v", err)
+			}
+
+			got, err := outputParser.parse(tc.output)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %v want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOutputParserErrors(t *testing.T) {
+	tests := map[string]struct {
+		parserConfiguration config.ParserConfiguration
+		output              string
+	}{
+		"json invalid": {parserConfiguration: config.ParserConfiguration{Type: parserTypeJSON}, output: `{"a":`},
+		"table empty":  {parserConfiguration: config.ParserConfiguration{Type: parserTypeTable}, output: "\n\n"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			outputParser, err := newOutputParser(&tc.parserConfiguration)
+			if err != nil {
+				t.Fatalf("newOutputParser error: %v", err)
+			}
+
+			if parsed, err := outputParser.parse(tc.output); err == nil {
+				t.Fatalf("expected error, got %v", parsed)
+			}
+		})
+	}
+}
+
+func TestNewOutputParserNil(t *testing.T) {
+	outputParser, err := newOutputParser(nil)
+	if outputParser != nil || err != nil {
+		t.Fatalf("got %v, %v want nil, nil", outputParser, err)
+	}
+}
@@ -0,0 +1,217 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"sync"
+	"syscall"
+	"unicode/utf8"
+)
+
+type commandErrorKind string
+
+const (
+	commandErrorKindNone               commandErrorKind = ""
+	commandErrorKindTimeout            commandErrorKind = "timeout"
+	commandErrorKindNonZeroExit        commandErrorKind = "non_zero_exit"
+	commandErrorKindExecFailure        commandErrorKind = "exec_failure"
+	commandErrorKindSemaphoreRejection commandErrorKind = "semaphore_rejection"
+)
+
+var allCommandErrorKinds = []commandErrorKind{
+	commandErrorKindTimeout,
+	commandErrorKindNonZeroExit,
+	commandErrorKindExecFailure,
+	commandErrorKindSemaphoreRejection,
+}
+
+func defaultErrorKindHTTPStatusCodes() map[commandErrorKind]int {
+	return map[commandErrorKind]int{
+		commandErrorKindNone:               http.StatusOK,
+		commandErrorKindTimeout:            http.StatusOK,
+		commandErrorKindNonZeroExit:        http.StatusOK,
+		commandErrorKindExecFailure:        http.StatusOK,
+		commandErrorKindSemaphoreRejection: http.StatusTooManyRequests,
+	}
+}
+
+// newErrorKindHTTPStatusCodes overlays configured status codes on the defaults.
+func newErrorKindHTTPStatusCodes(
+	configured map[string]int,
+) (map[commandErrorKind]int, error) {
+	errorKindHTTPStatusCodes := defaultErrorKindHTTPStatusCodes()
+
+	for errorKindString, statusCode := range configured {
+		errorKind := commandErrorKind(errorKindString)
+		if _, ok := errorKindHTTPStatusCodes[errorKind]; !ok || errorKind == commandErrorKindNone {
+			return nil, fmt.Errorf("unknown command error kind %q, valid kinds are %v", errorKindString, allCommandErrorKinds)
+		}
+		if http.StatusText(statusCode) == "" {
+			return nil, fmt.Errorf("invalid http status code %d for command error kind %q", statusCode, errorKindString)
+		}
+		errorKindHTTPStatusCodes[errorKind] = statusCode
+	}
+
+	return errorKindHTTPStatusCodes, nil
+}
+
+const outputEncodingBase64 = "base64"
+
+// encodeOutput returns output as a string, base64 encoded if it is not valid UTF-8.
+func encodeOutput(output []byte) (text string, encoding string) {
+	if utf8.Valid(output) {
+		return string(output), ""
+	}
+	return base64.StdEncoding.EncodeToString(output), outputEncodingBase64
+}
+
+// trimIncompleteRune drops a UTF-8 sequence cut short at the end of truncated output.
+func trimIncompleteRune(output []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(output); i++ {
+		if utf8.Valid(output) {
+			break
+		}
+		if utf8.Valid(output[:len(output)-i]) {
+			return output[:len(output)-i]
+		}
+	}
+	return output
+}
+
+// outputLimiter bounds how many bytes of output are kept, while counting all bytes written.
+type outputLimiter struct {
+	mutex      sync.Mutex
+	maxBytes   int64
+	keptBytes  int64
+	totalBytes int64
+}
+
+func newOutputLimiter(maxBytes int64) *outputLimiter {
+	return &outputLimiter{
+		maxBytes: maxBytes,
+	}
+}
+
+// keep records p as written and returns the prefix of p that fits under the limit.
+func (outputLimiter *outputLimiter) keep(p []byte) []byte {
+	outputLimiter.mutex.Lock()
+	defer outputLimiter.mutex.Unlock()
+
+	outputLimiter.totalBytes += int64(len(p))
+
+	remaining := max(outputLimiter.maxBytes-outputLimiter.keptBytes, 0)
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	outputLimiter.keptBytes += int64(len(p))
+	return p
+}
+
+func (outputLimiter *outputLimiter) truncated() bool {
+	outputLimiter.mutex.Lock()
+	defer outputLimiter.mutex.Unlock()
+
+	return outputLimiter.totalBytes > outputLimiter.keptBytes
+}
+
+func (outputLimiter *outputLimiter) total() int64 {
+	outputLimiter.mutex.Lock()
+	defer outputLimiter.mutex.Unlock()
+
+	return outputLimiter.totalBytes
+}
+
+// commandOutputCapture collects stdout, stderr and both interleaved in write order.
+// At most maxBytes of stdout and stderr together are kept, the rest is read and dropped.
+type commandOutputCapture struct {
+	mutex         sync.Mutex
+	outputLimiter *outputLimiter
+	stdout        bytes.Buffer
+	stderr        bytes.Buffer
+	combined      bytes.Buffer
+}
+
+func newCommandOutputCapture(maxBytes int64) *commandOutputCapture {
+	return &commandOutputCapture{
+		outputLimiter: newOutputLimiter(maxBytes),
+	}
+}
+
+type commandOutputCaptureWriter struct {
+	capture *commandOutputCapture
+	buffer  *bytes.Buffer
+}
+
+var _ io.Writer = commandOutputCaptureWriter{}
+
+func (captureWriter commandOutputCaptureWriter) Write(p []byte) (n int, err error) {
+	captureWriter.capture.mutex.Lock()
+	defer captureWriter.capture.mutex.Unlock()
+
+	keep := captureWriter.capture.outputLimiter.keep(p)
+
+	captureWriter.buffer.Write(keep)
+	captureWriter.capture.combined.Write(keep)
+
+	return len(p), nil
+}
+
+func (capture *commandOutputCapture) stdoutWriter() io.Writer {
+	return commandOutputCaptureWriter{
+		capture: capture,
+		buffer:  &capture.stdout,
+	}
+}
+
+func (capture *commandOutputCapture) stderrWriter() io.Writer {
+	return commandOutputCaptureWriter{
+		capture: capture,
+		buffer:  &capture.stderr,
+	}
+}
+
+// commandExitStatus describes how a finished command ended.
+type commandExitStatus struct {
+	exitCode  *int
+	signal    string
+	errorKind commandErrorKind
+}
+
+func classifyCommandExit(
+	ctx context.Context,
+	cmd *exec.Cmd,
+	commandErr error,
+) (exitStatus commandExitStatus) {
+	if processState := cmd.ProcessState; processState != nil {
+		exitStatus.exitCode = new(processState.ExitCode())
+
+		if waitStatus, ok := processState.Sys().(syscall.WaitStatus); ok && waitStatus.Signaled() {
+			exitStatus.signal = waitStatus.Signal().String()
+		}
+	}
+
+	var exitError *exec.ExitError
+
+	switch {
+	case commandErr == nil:
+		exitStatus.errorKind = commandErrorKindNone
+
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		exitStatus.errorKind = commandErrorKindTimeout
+
+	case errors.As(commandErr, &exitError):
+		exitStatus.errorKind = commandErrorKindNonZeroExit
+
+	default:
+		exitStatus.errorKind = commandErrorKindExecFailure
+	}
+
+	return
+}
@@ -0,0 +1,134 @@
+package command
+
+import (
+	"context"
+	"net/http"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestClassifyCommandExit(
+	t *testing.T,
+) {
+	tests := map[string]struct {
+		command        string
+		args           []string
+		wantErrorKind  commandErrorKind
+		wantExitCode   int
+		wantSignal     bool
+		wantNotStarted bool
+	}{
+		"success":       {command: "/bin/sh", args: []string{"-c", "exit 0"}, wantErrorKind: commandErrorKindNone, wantExitCode: 0},
+		"non zero exit": {command: "/bin/sh", args: []string{"-c", "exit 3"}, wantErrorKind: commandErrorKindNonZeroExit, wantExitCode: 3},
+		"signal":        {command: "/bin/sh", args: []string{"-c", "kill -TERM $$"}, wantErrorKind: commandErrorKindNonZeroExit, wantExitCode: -1, wantSignal: true},
+		"timeout":       {command: "/bin/sh", args: []string{"-c", "sleep 5"}, wantErrorKind: commandErrorKindTimeout, wantExitCode: -1, wantSignal: true},
+		"exec failure":  {command: "/nonexistent/command", wantErrorKind: commandErrorKindExecFailure, wantNotStarted: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			cmd := exec.CommandContext(ctx, tc.command, tc.args...)
+			err := cmd.Run()
+
+			exitStatus := classifyCommandExit(ctx, cmd, err)
+
+			if exitStatus.errorKind != tc.wantErrorKind {
+				t.Errorf("got errorKind %q want %q", exitStatus.errorKind, tc.wantErrorKind)
+			}
+
+			if tc.wantNotStarted {
+				if exitStatus.exitCode != nil {
+					t.Errorf("got exitCode %v want nil", *exitStatus.exitCode)
+				}
+			} else if exitStatus.exitCode == nil || *exitStatus.exitCode != tc.wantExitCode {
+				t.Errorf("got exitCode %v want %v", exitStatus.exitCode, tc.wantExitCode)
+			}
+
+			if (exitStatus.signal != "") != tc.wantSignal {
+				t.Errorf("got signal %q want signal %v", exitStatus.signal, tc.wantSignal)
+			}
+		})
+	}
+}
+
+func TestEncodeOutput(t *testing.T) {
+	if text, encoding := encodeOutput([]byte("héllo\n")); text != "héllo\n" || encoding != "" {
+		t.Errorf("utf-8 output got text %q encoding %q", text, encoding)
+	}
+
+	if text, encoding := encodeOutput([]byte{0xff, 0xfe}); text != "//4=" || encoding != outputEncodingBase64 {
+		t.Errorf("binary output got text %q encoding %q", text, encoding)
+	}
+}
+
+func TestNewErrorKindHTTPStatusCodes(t *testing.T) {
+	statusCodes, err := newErrorKindHTTPStatusCodes(map[string]int{"timeout": http.StatusGatewayTimeout})
+	if err != nil {
+		t.Fatalf("newErrorKindHTTPStatusCodes error: %v", err)
+	}
+
+	if statusCodes[commandErrorKindTimeout] != http.StatusGatewayTimeout {
+		t.Errorf("got timeout status %d", statusCodes[commandErrorKindTimeout])
+	}
+
+	if statusCodes[commandErrorKindSemaphoreRejection] != http.StatusTooManyRequests {
+		t.Errorf("got semaphore_rejection status %d", statusCodes[commandErrorKindSemaphoreRejection])
+	}
+
+	if _, err := newErrorKindHTTPStatusCodes(map[string]int{"bogus": 500}); err == nil {
+		t.Errorf("expected error for unknown error kind")
+	}
+
+	if _, err := newErrorKindHTTPStatusCodes(map[string]int{"timeout": 999}); err == nil {
+		t.Errorf("expected error for invalid status code")
+	}
+}
+
+func TestOutputLimiter(t *testing.T) {
+	outputLimiter := newOutputLimiter(5)
+
+	if keep := outputLimiter.keep([]byte("abc")); string(keep) != "abc" {
+		t.Errorf("got keep %q want %q", keep, "abc")
+	}
+
+	if keep := outputLimiter.keep([]byte("defgh")); string(keep) != "de" {
+		t.Errorf("got keep %q want %q", keep, "de")
+	}
+
+	if keep := outputLimiter.keep([]byte("ijk")); len(keep) != 0 {
+		t.Errorf("got keep %q want empty", keep)
+	}
+
+	if !outputLimiter.truncated() {
+		t.Errorf("outputLimiter should be truncated")
+	}
+
+	if total := outputLimiter.total(); total != 11 {
+		t.Errorf("got total %d want 11", total)
+	}
+}
+
+func TestTrimIncompleteRune(t *testing.T) {
+	tests := map[string]struct {
+		output string
+		want   string
+	}{
+		"ascii":           {output: "abc", want: "abc"},
+		"complete rune":   {output: "aé", want: "aé"},
+		"incomplete rune": {output: "aé"[:2], want: "a"},
+		"incomplete 4":    {output: "a😀"[:3], want: "a"},
+		"empty":           {output: "", want: ""},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := string(trimIncompleteRune([]byte(tc.output))); got != tc.want {
+				t.Fatalf("got %q want %q", got, tc.want)
+			}
+		})
+	}
+}
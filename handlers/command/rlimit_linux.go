@@ -0,0 +1,35 @@
+package command
+
+import "syscall"
+
+func checkExecutionSettingsSupported(
+	executionSettings *executionSettings,
+) error {
+	return nil
+}
+
+// setRlimits applies the rlimits of sandboxExec to this process.
+func setRlimits(sandboxExec *sandboxExec) error {
+	for _, rlimit := range []struct {
+		resource int
+		value    uint64
+	}{
+		{resource: syscall.RLIMIT_CPU, value: sandboxExec.RlimitCPUSeconds},
+		{resource: syscall.RLIMIT_AS, value: sandboxExec.RlimitAddressSpaceBytes},
+		{resource: syscall.RLIMIT_NOFILE, value: sandboxExec.RlimitOpenFiles},
+	} {
+		if rlimit.value == 0 {
+			continue
+		}
+
+		err := syscall.Setrlimit(rlimit.resource, &syscall.Rlimit{
+			Cur: rlimit.value,
+			Max: rlimit.value,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,21 @@
+//go:build unix && !linux
+
+package command
+
+import "errors"
+
+func checkExecutionSettingsSupported(
+	executionSettings *executionSettings,
+) error {
+	if executionSettings.hasRlimits() {
+		return errors.New("rlimits are only supported on linux")
+	}
+	return nil
+}
+
+func setRlimits(sandboxExec *sandboxExec) error {
+	if sandboxExec.RlimitCPUSeconds > 0 || sandboxExec.RlimitAddressSpaceBytes > 0 || sandboxExec.RlimitOpenFiles > 0 {
+		return errors.New("rlimits are only supported on linux")
+	}
+	return nil
+}
@@ -0,0 +1,398 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/aaronriekenberg/go-api/config"
+	"github.com/aaronriekenberg/go-api/request"
+	"github.com/aaronriekenberg/go-api/utils"
+)
+
+const commandWaitDelay = 500 * time.Millisecond
+
+var errorAcquiringCommandSemaphore = errors.New("error acquiring command semaphore")
+
+type commandRunner struct {
+	requestIsExternal        request.IsExternal
+	admissionController      *admissionController
+	runningCommands          *runningCommands
+	idToCommandInfo          map[string]config.CommandInfo
+	idToParameters           map[string]commandParameters
+	idToLimits               map[string]commandLimits
+	idToExecutionSettings    map[string]*executionSettings
+	idToOutputParser         map[string]outputParser
+	idToStats                map[string]*commandStats
+	errorKindHTTPStatusCodes map[commandErrorKind]int
+}
+
+// newCommandRunner validates commandConfiguration.
+// The statistics, running commands and, if its configuration is unchanged, the admission controller
+// of previous are kept.
+func newCommandRunner(
+	commandConfiguration config.CommandConfiguration,
+	requestIsExternal request.IsExternal,
+	previous *commandRunner,
+) (*commandRunner, error) {
+
+	idToCommandInfo := make(map[string]config.CommandInfo)
+	idToParameters := make(map[string]commandParameters)
+	idToLimits := make(map[string]commandLimits)
+	idToExecutionSettings := make(map[string]*executionSettings)
+	idToOutputParser := make(map[string]outputParser)
+	idToStats := make(map[string]*commandStats)
+	serverEnviron := os.Environ()
+	for _, commandInfo := range commandConfiguration.Commands {
+		if _, ok := idToCommandInfo[commandInfo.ID]; ok {
+			return nil, fmt.Errorf("duplicate command ID %q", commandInfo.ID)
+		}
+		if slices.Contains(config.ReservedCommandIDs, commandInfo.ID) {
+			return nil, fmt.Errorf("command ID %q is reserved", commandInfo.ID)
+		}
+		idToCommandInfo[commandInfo.ID] = commandInfo
+		idToLimits[commandInfo.ID] = newCommandLimits(commandConfiguration, commandInfo)
+		idToStats[commandInfo.ID] = newCommandStats()
+		if previous != nil {
+			if previousStats, ok := previous.idToStats[commandInfo.ID]; ok {
+				idToStats[commandInfo.ID] = previousStats
+			}
+		}
+
+		parameters, err := newCommandParameters(commandInfo)
+		if err != nil {
+			return nil, fmt.Errorf("newCommandParameters error: %w", err)
+		}
+		idToParameters[commandInfo.ID] = parameters
+
+		executionSettings, err := newExecutionSettings(
+			commandConfiguration.ExecutionConfiguration,
+			commandInfo,
+			serverEnviron,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("newExecutionSettings error: %w", err)
+		}
+		idToExecutionSettings[commandInfo.ID] = executionSettings
+
+		outputParser, err := newOutputParser(commandInfo.Parser)
+		if err != nil {
+			return nil, fmt.Errorf("command %q newOutputParser error: %w", commandInfo.ID, err)
+		}
+		if outputParser != nil {
+			idToOutputParser[commandInfo.ID] = outputParser
+		}
+	}
+
+	admissionController, err := newAdmissionController(
+		commandConfiguration.MaxConcurrentCommands,
+		commandConfiguration.AdmissionConfiguration,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("newAdmissionController error: %w", err)
+	}
+
+	if previous != nil && previous.admissionController.hasConfiguration(
+		commandConfiguration.MaxConcurrentCommands,
+		commandConfiguration.AdmissionConfiguration,
+	) {
+		admissionController = previous.admissionController
+	}
+
+	runningCommands := newRunningCommands()
+	if previous != nil {
+		runningCommands = previous.runningCommands
+	}
+
+	errorKindHTTPStatusCodes, err := newErrorKindHTTPStatusCodes(commandConfiguration.ErrorKindHTTPStatusCodes)
+	if err != nil {
+		return nil, fmt.Errorf("newErrorKindHTTPStatusCodes error: %w", err)
+	}
+
+	return &commandRunner{
+		requestIsExternal:        requestIsExternal,
+		admissionController:      admissionController,
+		runningCommands:          runningCommands,
+		idToCommandInfo:          idToCommandInfo,
+		idToParameters:           idToParameters,
+		idToLimits:               idToLimits,
+		idToExecutionSettings:    idToExecutionSettings,
+		idToOutputParser:         idToOutputParser,
+		idToStats:                idToStats,
+		errorKindHTTPStatusCodes: errorKindHTTPStatusCodes,
+	}, nil
+}
+
+// commandInfoForRequest looks up the command named by the "id" path value,
+// hiding internal only commands from external requests.
+func (commandRunner *commandRunner) commandInfoForRequest(
+	r *http.Request,
+) (commandInfo config.CommandInfo, ok bool) {
+	id := r.PathValue("id")
+
+	commandInfo, ok = commandRunner.idToCommandInfo[id]
+	if !ok {
+		slog.Warn("commandRunner unable to find comand",
+			"id", id,
+		)
+		return
+	}
+
+	if commandInfo.InternalOnly && commandRunner.requestIsExternal(r) {
+		slog.Warn("commandRunner external request for internal only command",
+			"id", id,
+		)
+		ok = false
+		return
+	}
+
+	return
+}
+
+// runnableCommandInfoForRequest is commandInfoForRequest followed by substitution of
+// the request's query parameters into the command's args.
+// On failure an error response has been written and ok is false.
+func (commandRunner *commandRunner) runnableCommandInfoForRequest(
+	w http.ResponseWriter,
+	r *http.Request,
+) (commandInfo config.CommandInfo, ok bool) {
+	commandInfo, ok = commandRunner.commandInfoForRequest(r)
+	if !ok {
+		utils.HTTPErrorStatusCode(w, http.StatusNotFound)
+		return
+	}
+
+	resolvedArgs, err := commandRunner.idToParameters[commandInfo.ID].resolveArgs(
+		commandInfo.Args,
+		r.URL.Query(),
+	)
+	if err != nil {
+		slog.Warn("commandRunner resolveArgs error",
+			"id", commandInfo.ID,
+			"error", err,
+		)
+		utils.RespondWithJSONDTOAndStatusCode(err, http.StatusBadRequest, w)
+		ok = false
+		return
+	}
+
+	commandInfo.Args = resolvedArgs
+	return
+}
+
+// commandInfoWithDefaultArgs is commandInfo with its parameters' defaults substituted into its args,
+// for runs that do not come from a request.
+func (commandRunner *commandRunner) commandInfoWithDefaultArgs(
+	commandInfo config.CommandInfo,
+) (config.CommandInfo, error) {
+	resolvedArgs, err := commandRunner.idToParameters[commandInfo.ID].resolveArgs(
+		commandInfo.Args,
+		url.Values{},
+	)
+	if err != nil {
+		return commandInfo, err
+	}
+
+	commandInfo.Args = resolvedArgs
+	return commandInfo, nil
+}
+
+func (commandRunner *commandRunner) requestTimeout(commandInfo config.CommandInfo) time.Duration {
+	return commandRunner.idToLimits[commandInfo.ID].requestTimeout
+}
+
+func (commandRunner *commandRunner) maxOutputBytes(commandInfo config.CommandInfo) int64 {
+	return commandRunner.idToLimits[commandInfo.ID].maxOutputBytes
+}
+
+func (commandRunner *commandRunner) admissionClassForRequest(r *http.Request) admissionClass {
+	return admissionClassForRequest(commandRunner.requestIsExternal, r)
+}
+
+// acquireCommandSemaphore waits up to the command's semaphore acquire timeout
+// for both the command's own concurrency limit and a global slot for admissionClass.
+func (commandRunner *commandRunner) acquireCommandSemaphore(
+	ctx context.Context,
+	commandInfo config.CommandInfo,
+	admissionClass admissionClass,
+) error {
+	ctx, cancel := context.WithTimeout(ctx, commandRunner.idToLimits[commandInfo.ID].semaphoreAcquireTimeout)
+	defer cancel()
+
+	return commandRunner.waitForCommandSemaphore(ctx, commandInfo, admissionClass)
+}
+
+// waitForCommandSemaphore is acquireCommandSemaphore bounded only by ctx.
+// The command's own limit is acquired first so waiting on it never holds a global slot.
+func (commandRunner *commandRunner) waitForCommandSemaphore(
+	ctx context.Context,
+	commandInfo config.CommandInfo,
+	admissionClass admissionClass,
+) (err error) {
+	defer func() {
+		if err != nil {
+			commandRunner.idToStats[commandInfo.ID].recordSemaphoreRejection(err)
+		}
+	}()
+
+	commandConcurrencyLimit := commandRunner.idToLimits[commandInfo.ID].concurrencyLimit
+
+	if commandConcurrencyLimit != nil {
+		err = commandConcurrencyLimit.acquire(ctx)
+		if err != nil {
+			return fmt.Errorf("%w: command limit: %w", errorAcquiringCommandSemaphore, err)
+		}
+	}
+
+	err = commandRunner.admissionController.acquire(ctx, admissionClass)
+	if err != nil {
+		if commandConcurrencyLimit != nil {
+			commandConcurrencyLimit.release()
+		}
+		return fmt.Errorf("%w: %w", errorAcquiringCommandSemaphore, err)
+	}
+
+	return nil
+}
+
+func (commandRunner *commandRunner) releaseCommandSemaphore(
+	commandInfo config.CommandInfo,
+	admissionClass admissionClass,
+) {
+	commandRunner.admissionController.release(admissionClass)
+
+	if commandConcurrencyLimit := commandRunner.idToLimits[commandInfo.ID].concurrencyLimit; commandConcurrencyLimit != nil {
+		commandConcurrencyLimit.release()
+	}
+}
+
+func (commandRunner *commandRunner) newCmd(
+	ctx context.Context,
+	commandInfo config.CommandInfo,
+) *sandboxedCmd {
+	return newSandboxedCmd(
+		ctx,
+		commandInfo,
+		commandRunner.idToExecutionSettings[commandInfo.ID],
+	)
+}
+
+func (commandRunner *commandRunner) recordCommandRun(
+	commandInfo config.CommandInfo,
+	commandDuration time.Duration,
+	exitStatus commandExitStatus,
+	commandErr error,
+) {
+	commandRunner.idToStats[commandInfo.ID].recordRun(commandDuration, exitStatus.errorKind, commandErr)
+}
+
+// executeCommand runs commandInfo to completion.  The caller must hold the command semaphore.
+// commandErr is the raw error from the command, the response is always populated.
+func (commandRunner *commandRunner) executeCommand(
+	ctx context.Context,
+	commandInfo config.CommandInfo,
+) (response commandAPIResponse, commandErr error) {
+	ctx, done, err := commandRunner.runningCommands.start(ctx)
+	if err != nil {
+		return semaphoreRejectionResponse(commandInfo, err), err
+	}
+	defer done()
+
+	outputCapture := newCommandOutputCapture(commandRunner.maxOutputBytes(commandInfo))
+
+	cmd := commandRunner.newCmd(ctx, commandInfo)
+	cmd.Stdout = outputCapture.stdoutWriter()
+	cmd.Stderr = outputCapture.stderrWriter()
+
+	commandStartTime := time.Now()
+	commandErr = cmd.Run()
+	commandEndTime := time.Now()
+
+	commandDuration := commandEndTime.Sub(commandStartTime)
+
+	exitStatus := classifyCommandExit(ctx, cmd.Cmd, commandErr)
+
+	commandRunner.recordCommandRun(commandInfo, commandDuration, exitStatus, commandErr)
+
+	response = commandAPIResponse{
+		CommandInfo:                 commandInfoToDTO(commandInfo),
+		Now:                         commandEndTime,
+		CommandDurationMilliseconds: commandDuration.Milliseconds(),
+		ExitCode:                    exitStatus.exitCode,
+		Signal:                      exitStatus.signal,
+		ErrorKind:                   exitStatus.errorKind,
+		Truncated:                   outputCapture.outputLimiter.truncated(),
+		TotalOutputBytes:            outputCapture.outputLimiter.total(),
+	}
+
+	combinedOutput, stdout, stderr := outputCapture.combined.Bytes(), outputCapture.stdout.Bytes(), outputCapture.stderr.Bytes()
+	if response.Truncated {
+		combinedOutput, stdout, stderr = trimIncompleteRune(combinedOutput), trimIncompleteRune(stdout), trimIncompleteRune(stderr)
+	}
+
+	response.CommandOutput, response.CommandOutputEncoding = encodeOutput(combinedOutput)
+	response.Stdout, response.StdoutEncoding = encodeOutput(stdout)
+	response.Stderr, response.StderrEncoding = encodeOutput(stderr)
+
+	if commandErr != nil {
+		response.Error = commandErr.Error()
+	}
+
+	commandRunner.parseOutput(commandInfo, &response)
+
+	return
+}
+
+// parseOutput sets response.ParsedOutput from stdout if the command has a parser.
+func (commandRunner *commandRunner) parseOutput(
+	commandInfo config.CommandInfo,
+	response *commandAPIResponse,
+) {
+	outputParser, ok := commandRunner.idToOutputParser[commandInfo.ID]
+	if !ok {
+		return
+	}
+
+	if response.StdoutEncoding != "" {
+		response.ParseError = "stdout is not valid UTF-8"
+		return
+	}
+
+	parsedOutput, err := outputParser.parse(response.Stdout)
+	if err != nil {
+		response.ParseError = err.Error()
+		return
+	}
+
+	response.ParsedOutput = parsedOutput
+}
+
+func timeoutResponse(
+	commandInfo config.CommandInfo,
+	err error,
+) commandAPIResponse {
+	return commandAPIResponse{
+		CommandInfo: commandInfoToDTO(commandInfo),
+		Now:         time.Now(),
+		ErrorKind:   commandErrorKindTimeout,
+		Error:       err.Error(),
+	}
+}
+
+func semaphoreRejectionResponse(
+	commandInfo config.CommandInfo,
+	err error,
+) commandAPIResponse {
+	return commandAPIResponse{
+		CommandInfo: commandInfoToDTO(commandInfo),
+		Now:         time.Now(),
+		ErrorKind:   commandErrorKindSemaphoreRejection,
+		Error:       err.Error(),
+	}
+}
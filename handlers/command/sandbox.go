@@ -0,0 +1,169 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aaronriekenberg/go-api/config"
+)
+
+// executionSettings are the effective process settings of one command,
+// per-command ExecutionConfiguration layered over the global one.
+type executionSettings struct {
+	env                     []string
+	workingDirectory        string
+	uid                     *uint32
+	gid                     *uint32
+	niceLevel               int
+	rlimitCPUSeconds        uint64
+	rlimitAddressSpaceBytes uint64
+	rlimitOpenFiles         uint64
+	killGracePeriod         time.Duration
+}
+
+func (executionSettings *executionSettings) hasRlimits() bool {
+	return executionSettings.rlimitCPUSeconds > 0 ||
+		executionSettings.rlimitAddressSpaceBytes > 0 ||
+		executionSettings.rlimitOpenFiles > 0
+}
+
+func (executionSettings *executionSettings) hasCredential() bool {
+	return executionSettings.uid != nil || executionSettings.gid != nil
+}
+
+// buildCommandEnv returns nil (inherit everything) when there is no allowlist and no overrides.
+func buildCommandEnv(
+	serverEnviron []string,
+	envAllowlist []string,
+	envOverrides map[string]string,
+) []string {
+	if len(envAllowlist) == 0 && len(envOverrides) == 0 {
+		return nil
+	}
+
+	envMap := make(map[string]string)
+
+	for _, env := range serverEnviron {
+		key, value, ok := strings.Cut(env, "=")
+		if !ok {
+			continue
+		}
+		if len(envAllowlist) == 0 || slices.Contains(envAllowlist, key) {
+			envMap[key] = value
+		}
+	}
+
+	maps.Copy(envMap, envOverrides)
+
+	env := make([]string, 0, len(envMap))
+	for _, key := range slices.Sorted(maps.Keys(envMap)) {
+		env = append(env, key+"="+envMap[key])
+	}
+	return env
+}
+
+func newExecutionSettings(
+	global config.ExecutionConfiguration,
+	commandInfo config.CommandInfo,
+	serverEnviron []string,
+) (*executionSettings, error) {
+	merged := global
+	merged.EnvOverrides = maps.Clone(global.EnvOverrides)
+
+	if command := commandInfo.ExecutionConfiguration; command != nil {
+		if len(command.EnvAllowlist) > 0 {
+			merged.EnvAllowlist = command.EnvAllowlist
+		}
+		if len(command.EnvOverrides) > 0 {
+			if merged.EnvOverrides == nil {
+				merged.EnvOverrides = make(map[string]string)
+			}
+			maps.Copy(merged.EnvOverrides, command.EnvOverrides)
+		}
+		if command.WorkingDirectory != "" {
+			merged.WorkingDirectory = command.WorkingDirectory
+		}
+		if command.UID != nil {
+			merged.UID = command.UID
+		}
+		if command.GID != nil {
+			merged.GID = command.GID
+		}
+		if command.NiceLevel != 0 {
+			merged.NiceLevel = command.NiceLevel
+		}
+		if command.RlimitCPUSeconds > 0 {
+			merged.RlimitCPUSeconds = command.RlimitCPUSeconds
+		}
+		if command.RlimitAddressSpaceBytes > 0 {
+			merged.RlimitAddressSpaceBytes = command.RlimitAddressSpaceBytes
+		}
+		if command.RlimitOpenFiles > 0 {
+			merged.RlimitOpenFiles = command.RlimitOpenFiles
+		}
+		if command.KillGracePeriodDuration > 0 {
+			merged.KillGracePeriodDuration = command.KillGracePeriodDuration
+		}
+	}
+
+	executionSettings := &executionSettings{
+		env:                     buildCommandEnv(serverEnviron, merged.EnvAllowlist, merged.EnvOverrides),
+		workingDirectory:        merged.WorkingDirectory,
+		uid:                     merged.UID,
+		gid:                     merged.GID,
+		niceLevel:               merged.NiceLevel,
+		rlimitCPUSeconds:        merged.RlimitCPUSeconds,
+		rlimitAddressSpaceBytes: merged.RlimitAddressSpaceBytes,
+		rlimitOpenFiles:         merged.RlimitOpenFiles,
+		killGracePeriod:         merged.KillGracePeriodDuration,
+	}
+
+	if err := checkExecutionSettingsSupported(executionSettings); err != nil {
+		return nil, fmt.Errorf("command %q: %w", commandInfo.ID, err)
+	}
+
+	return executionSettings, nil
+}
+
+// sandboxedCmd is a command created by newSandboxedCmd, run with Run.
+type sandboxedCmd struct {
+	*exec.Cmd
+	afterWait func()
+}
+
+// Run starts the command and waits for it to complete.
+func (sandboxedCmd *sandboxedCmd) Run() error {
+	err := sandboxedCmd.Cmd.Run()
+
+	if sandboxedCmd.afterWait != nil {
+		sandboxedCmd.afterWait()
+	}
+
+	return err
+}
+
+// newSandboxedCmd creates a command applying everything in executionSettings before the command starts.
+func newSandboxedCmd(
+	ctx context.Context,
+	commandInfo config.CommandInfo,
+	executionSettings *executionSettings,
+) *sandboxedCmd {
+	cmd := exec.CommandContext(
+		ctx,
+		commandInfo.Command,
+		commandInfo.Args...,
+	)
+	cmd.Env = executionSettings.env
+	cmd.Dir = executionSettings.workingDirectory
+	cmd.WaitDelay = executionSettings.killGracePeriod + commandWaitDelay
+
+	return &sandboxedCmd{
+		Cmd:       cmd,
+		afterWait: configurePlatformCmd(cmd, executionSettings),
+	}
+}
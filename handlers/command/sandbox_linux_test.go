@@ -0,0 +1,131 @@
+package command
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aaronriekenberg/go-api/config"
+)
+
+func runTestScript(
+	t *testing.T,
+	timeout time.Duration,
+	executionConfiguration config.ExecutionConfiguration,
+	script string,
+) (output string, err error) {
+	t.Helper()
+
+	commandInfo := config.CommandInfo{
+		ID:      "test",
+		Command: "/bin/sh",
+		Args:    []string{"-c", script},
+	}
+
+	executionSettings, err := newExecutionSettings(executionConfiguration, commandInfo, os.Environ())
+	if err != nil {
+		t.Fatalf("newExecutionSettings error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var outputBuilder strings.Builder
+
+	cmd := newSandboxedCmd(ctx, commandInfo, executionSettings)
+	cmd.Stdout = &outputBuilder
+
+	err = cmd.Run()
+	return outputBuilder.String(), err
+}
+
+func TestSandboxKillsProcessGroupOnTimeout(t *testing.T) {
+	markerFile := filepath.Join(t.TempDir(), "marker")
+
+	// the grandchild would create markerFile if it survived the timeout
+	_, err := runTestScript(
+		t,
+		200*time.Millisecond,
+		config.ExecutionConfiguration{KillGracePeriodDuration: 100 * time.Millisecond},
+		"(sleep 1; touch "+markerFile+") & sleep 5",
+	)
+	if err == nil {
+		t.Fatalf("expected error from timed out command")
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+
+	if _, err := os.Stat(markerFile); err == nil {
+		t.Fatalf("grandchild process survived timeout")
+	}
+}
+
+func TestSandboxKillsProcessGroupAfterWait(t *testing.T) {
+	markerFile := filepath.Join(t.TempDir(), "marker")
+
+	// the grandchild ignores SIGTERM and does not hold the output open, so the wait
+	// returns long before the grace period ends
+	startTime := time.Now()
+	_, err := runTestScript(
+		t,
+		200*time.Millisecond,
+		config.ExecutionConfiguration{KillGracePeriodDuration: 5 * time.Second},
+		"(trap '' TERM; sleep 1; touch "+markerFile+") >/dev/null 2>&1 & sleep 5",
+	)
+	if err == nil {
+		t.Fatalf("expected error from timed out command")
+	}
+	if elapsed := time.Since(startTime); elapsed > time.Second {
+		t.Fatalf("command took %v, want the wait to return before the grace period", elapsed)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+
+	if _, err := os.Stat(markerFile); err == nil {
+		t.Fatalf("grandchild process survived the wait")
+	}
+}
+
+func TestSandboxGracePeriodAllowsCleanup(t *testing.T) {
+	output, err := runTestScript(
+		t,
+		200*time.Millisecond,
+		config.ExecutionConfiguration{KillGracePeriodDuration: time.Second},
+		"trap 'echo terminated; exit 1' TERM; while true; do sleep 0.05; done",
+	)
+	if err == nil {
+		t.Fatalf("expected error from timed out command")
+	}
+
+	if !strings.Contains(output, "terminated") {
+		t.Fatalf("SIGTERM trap did not run, output %q", output)
+	}
+}
+
+func TestSandboxSettings(t *testing.T) {
+	workingDirectory := t.TempDir()
+
+	output, err := runTestScript(
+		t,
+		5*time.Second,
+		config.ExecutionConfiguration{
+			EnvAllowlist:     []string{"PATH"},
+			EnvOverrides:     map[string]string{"GO_API_TEST": "value"},
+			WorkingDirectory: workingDirectory,
+			NiceLevel:        3,
+			RlimitOpenFiles:  32,
+		},
+		"pwd; echo $GO_API_TEST; echo ${HOME:-nohome}; ulimit -n; cut -d' ' -f19 /proc/$$/stat",
+	)
+	if err != nil {
+		t.Fatalf("runTestScript error: %v", err)
+	}
+
+	wantLines := []string{workingDirectory, "value", "nohome", "32", "3"}
+	if lines := strings.Fields(output); strings.Join(lines, ",") != strings.Join(wantLines, ",") {
+		t.Fatalf("got output lines %q want %q", lines, wantLines)
+	}
+}
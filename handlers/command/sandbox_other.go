@@ -0,0 +1,28 @@
+//go:build !unix
+
+package command
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func configurePlatformCmd(
+	cmd *exec.Cmd,
+	executionSettings *executionSettings,
+) (afterWait func()) {
+	return nil
+}
+
+// RunSandboxExec returns, the nice level and rlimits are not supported on this platform.
+func RunSandboxExec() {
+}
+
+func checkExecutionSettingsSupported(
+	executionSettings *executionSettings,
+) error {
+	if executionSettings.hasCredential() || executionSettings.hasRlimits() || executionSettings.niceLevel != 0 {
+		return errors.New("uid, gid, nice level and rlimits are not supported on this platform")
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package command
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aaronriekenberg/go-api/config"
+)
+
+// TestMain lets the test binary apply the nice level and rlimits of the commands tests start.
+func TestMain(m *testing.M) {
+	RunSandboxExec()
+
+	os.Exit(m.Run())
+}
+
+func TestBuildCommandEnv(t *testing.T) {
+	serverEnviron := []string{"PATH=/usr/bin", "HOME=/home/user", "SECRET=hunter2", "LANG=C"}
+
+	if env := buildCommandEnv(serverEnviron, nil, nil); env != nil {
+		t.Errorf("no allowlist or overrides should inherit, got %q", env)
+	}
+
+	env := buildCommandEnv(serverEnviron, []string{"PATH", "LANG"}, map[string]string{"LANG": "en_US.UTF-8", "TZ": "UTC"})
+
+	wantEnv := []string{"LANG=en_US.UTF-8", "PATH=/usr/bin", "TZ=UTC"}
+	if !slices.Equal(env, wantEnv) {
+		t.Errorf("got env %q want %q", env, wantEnv)
+	}
+}
+
+func TestNewExecutionSettingsLayering(t *testing.T) {
+	global := config.ExecutionConfiguration{
+		EnvAllowlist:            []string{"PATH"},
+		EnvOverrides:            map[string]string{"A": "global", "B": "global"},
+		WorkingDirectory:        "/",
+		NiceLevel:               5,
+		KillGracePeriodDuration: time.Second,
+	}
+
+	commandInfo := config.CommandInfo{
+		ID: "test",
+		ExecutionConfiguration: &config.ExecutionConfiguration{
+			EnvOverrides:     map[string]string{"B": "command"},
+			WorkingDirectory: "/tmp",
+		},
+	}
+
+	executionSettings, err := newExecutionSettings(global, commandInfo, []string{"PATH=/bin", "HOME=/root"})
+	if err != nil {
+		t.Fatalf("newExecutionSettings error: %v", err)
+	}
+
+	wantEnv := []string{"A=global", "B=command", "PATH=/bin"}
+	if !slices.Equal(executionSettings.env, wantEnv) {
+		t.Errorf("got env %q want %q", executionSettings.env, wantEnv)
+	}
+	if executionSettings.workingDirectory != "/tmp" {
+		t.Errorf("got workingDirectory %q", executionSettings.workingDirectory)
+	}
+	if executionSettings.niceLevel != 5 {
+		t.Errorf("got niceLevel %d", executionSettings.niceLevel)
+	}
+	if executionSettings.killGracePeriod != time.Second {
+		t.Errorf("got killGracePeriod %v", executionSettings.killGracePeriod)
+	}
+
+	// the per-command overrides must not leak into the global configuration
+	if global.EnvOverrides["B"] != "global" {
+		t.Errorf("global EnvOverrides modified: %v", global.EnvOverrides)
+	}
+}
@@ -0,0 +1,188 @@
+//go:build unix
+
+package command
+
+import (
+	"encoding/json/v2"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// configurePlatformCmd runs the command in its own process group so a timeout
+// terminates the whole group, not just the direct child.  It returns the function to call
+// after the command was waited for.
+func configurePlatformCmd(
+	cmd *exec.Cmd,
+	executionSettings *executionSettings,
+) (afterWait func()) {
+	sysProcAttr := &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+
+	if executionSettings.hasCredential() {
+		// the id not configured stays the server's own
+		credential := &syscall.Credential{
+			Uid: uint32(syscall.Getuid()),
+			Gid: uint32(syscall.Getgid()),
+			// only root may set the supplementary groups
+			NoSetGroups: syscall.Getuid() != 0,
+		}
+		if executionSettings.uid != nil {
+			credential.Uid = *executionSettings.uid
+		}
+		if executionSettings.gid != nil {
+			credential.Gid = *executionSettings.gid
+		}
+		sysProcAttr.Credential = credential
+	}
+
+	cmd.SysProcAttr = sysProcAttr
+
+	configureSandboxExec(cmd, executionSettings)
+
+	processGroupKiller := &processGroupKiller{
+		killGracePeriod: executionSettings.killGracePeriod,
+	}
+
+	cmd.Cancel = func() error {
+		return processGroupKiller.cancel(cmd.Process.Pid)
+	}
+
+	return processGroupKiller.afterWait
+}
+
+// processGroupKiller terminates the process group of a command when its context is done,
+// and kills it if it is still running after the kill grace period.
+// exec.Cmd.Wait returns after cancel, so afterWait needs no lock.
+type processGroupKiller struct {
+	killGracePeriod time.Duration
+	processGroupID  int
+	killTimer       *time.Timer
+}
+
+func (processGroupKiller *processGroupKiller) cancel(processGroupID int) error {
+	if processGroupKiller.killGracePeriod <= 0 {
+		return syscall.Kill(-processGroupID, syscall.SIGKILL)
+	}
+
+	err := syscall.Kill(-processGroupID, syscall.SIGTERM)
+
+	processGroupKiller.processGroupID = processGroupID
+	processGroupKiller.killTimer = time.AfterFunc(processGroupKiller.killGracePeriod, func() {
+		syscall.Kill(-processGroupID, syscall.SIGKILL)
+	})
+
+	return err
+}
+
+// afterWait stops the kill timer, as the process group ID can be reused once the group has exited.
+// A group still running, with only descendants of the waited for command left, is killed now.
+func (processGroupKiller *processGroupKiller) afterWait() {
+	if processGroupKiller.killTimer == nil || !processGroupKiller.killTimer.Stop() {
+		return
+	}
+
+	// signal 0 only checks that the group exists
+	if syscall.Kill(-processGroupKiller.processGroupID, 0) == nil {
+		syscall.Kill(-processGroupKiller.processGroupID, syscall.SIGKILL)
+	}
+}
+
+// sandboxExecEnvironmentVariable marks a start of the server executable that applies
+// the nice level and rlimits to itself and then execs the command, a sandboxExec in JSON.
+const sandboxExecEnvironmentVariable = "GO_API_SANDBOX_EXEC"
+
+// sandboxExecFailedExitCode is the exit code when the settings could not be applied.
+const sandboxExecFailedExitCode = 126
+
+// sandboxExec is what the server executable applies before it execs Path.
+type sandboxExec struct {
+	Path                    string
+	NiceLevel               int
+	RlimitCPUSeconds        uint64
+	RlimitAddressSpaceBytes uint64
+	RlimitOpenFiles         uint64
+}
+
+// RunSandboxExec execs the command if this process was started by configureSandboxExec,
+// exiting if that fails, and otherwise returns.  It is called before anything else runs.
+func RunSandboxExec() {
+	value, ok := os.LookupEnv(sandboxExecEnvironmentVariable)
+	if !ok {
+		return
+	}
+
+	err := runSandboxExec(value)
+	fmt.Fprintf(os.Stderr, "go-api sandbox exec error: %v\n", err)
+	os.Exit(sandboxExecFailedExitCode)
+}
+
+// configureSandboxExec makes cmd start the server executable, which applies the nice level and rlimits
+// and then execs the command, so they are in effect before the command runs.
+func configureSandboxExec(
+	cmd *exec.Cmd,
+	executionSettings *executionSettings,
+) {
+	if cmd.Err != nil || (executionSettings.niceLevel == 0 && !executionSettings.hasRlimits()) {
+		return
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		cmd.Err = fmt.Errorf("os.Executable error: %w", err)
+		return
+	}
+
+	sandboxExecJSON, err := json.Marshal(&sandboxExec{
+		Path:                    cmd.Path,
+		NiceLevel:               executionSettings.niceLevel,
+		RlimitCPUSeconds:        executionSettings.rlimitCPUSeconds,
+		RlimitAddressSpaceBytes: executionSettings.rlimitAddressSpaceBytes,
+		RlimitOpenFiles:         executionSettings.rlimitOpenFiles,
+	})
+	if err != nil {
+		cmd.Err = fmt.Errorf("json.Marshal error: %w", err)
+		return
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+
+	cmd.Env = append(slices.Clone(env), sandboxExecEnvironmentVariable+"="+string(sandboxExecJSON))
+	cmd.Path = executable
+}
+
+// runSandboxExec applies the settings in value to this process and execs the command.
+// It only returns on error.
+func runSandboxExec(value string) error {
+	var sandboxExec sandboxExec
+	if err := json.Unmarshal([]byte(value), &sandboxExec); err != nil {
+		return fmt.Errorf("invalid %s: %w", sandboxExecEnvironmentVariable, err)
+	}
+
+	os.Unsetenv(sandboxExecEnvironmentVariable)
+
+	// the nice level is per thread on linux, exec keeps the one of the calling thread
+	runtime.LockOSThread()
+
+	if sandboxExec.NiceLevel != 0 {
+		err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, sandboxExec.NiceLevel)
+		if err != nil {
+			return fmt.Errorf("syscall.Setpriority error: %w", err)
+		}
+	}
+
+	if err := setRlimits(&sandboxExec); err != nil {
+		return fmt.Errorf("setRlimits error: %w", err)
+	}
+
+	err := syscall.Exec(sandboxExec.Path, os.Args, os.Environ())
+	return fmt.Errorf("syscall.Exec error: %w", err)
+}
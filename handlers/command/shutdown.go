@@ -0,0 +1,99 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// killedCommandsWaitTimeout bounds the wait for killed commands to exit on shutdown.
+const killedCommandsWaitTimeout = 5 * time.Second
+
+var (
+	errorShuttingDown     = fmt.Errorf("%w: shutting down", errorAcquiringCommandSemaphore)
+	errorKilledOnShutdown = errors.New("command killed on shutdown")
+)
+
+// runningCommands tracks the command processes of every configuration so shutdown can wait for them.
+type runningCommands struct {
+	mutex        sync.Mutex
+	shuttingDown bool
+	waitGroup    sync.WaitGroup
+	killCtx      context.Context
+	kill         context.CancelCauseFunc
+}
+
+func newRunningCommands() *runningCommands {
+	killCtx, kill := context.WithCancelCause(context.Background())
+
+	return &runningCommands{
+		killCtx: killCtx,
+		kill:    kill,
+	}
+}
+
+// start registers a command about to run.  The returned context is also cancelled if the
+// command is killed on shutdown, done must be called when the command has exited.
+// After shutdown has begun no more commands are started.
+func (runningCommands *runningCommands) start(
+	ctx context.Context,
+) (commandCtx context.Context, done func(), err error) {
+	runningCommands.mutex.Lock()
+	defer runningCommands.mutex.Unlock()
+
+	if runningCommands.shuttingDown {
+		return nil, nil, errorShuttingDown
+	}
+
+	runningCommands.waitGroup.Add(1)
+
+	commandCtx, cancel := context.WithCancelCause(ctx)
+	stop := context.AfterFunc(runningCommands.killCtx, func() {
+		cancel(errorKilledOnShutdown)
+	})
+
+	done = func() {
+		stop()
+		cancel(nil)
+		runningCommands.waitGroup.Done()
+	}
+
+	return commandCtx, done, nil
+}
+
+// shutdown waits for the running commands until ctx is done, then kills them.
+func (runningCommands *runningCommands) shutdown(ctx context.Context) error {
+	runningCommands.mutex.Lock()
+	runningCommands.shuttingDown = true
+	runningCommands.mutex.Unlock()
+
+	idle := make(chan struct{})
+	go func() {
+		runningCommands.waitGroup.Wait()
+		close(idle)
+	}()
+
+	select {
+	case <-idle:
+		return nil
+
+	case <-ctx.Done():
+	}
+
+	slog.Warn("runningCommands.shutdown killing running commands",
+		"error", ctx.Err(),
+	)
+
+	runningCommands.kill(errorKilledOnShutdown)
+
+	select {
+	case <-idle:
+	case <-time.After(killedCommandsWaitTimeout):
+		slog.Warn("runningCommands.shutdown timed out waiting for killed commands")
+	}
+
+	return ctx.Err()
+}
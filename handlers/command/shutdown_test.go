@@ -0,0 +1,52 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunningCommandsShutdownWaits(t *testing.T) {
+	runningCommands := newRunningCommands()
+
+	_, done, err := runningCommands.start(context.Background())
+	if err != nil {
+		t.Fatalf("start error: %v", err)
+	}
+
+	time.AfterFunc(50*time.Millisecond, done)
+
+	if err := runningCommands.shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown error: %v", err)
+	}
+
+	if _, _, err := runningCommands.start(context.Background()); !errors.Is(err, errorAcquiringCommandSemaphore) {
+		t.Fatalf("start after shutdown error = %v, want %v", err, errorShuttingDown)
+	}
+}
+
+func TestRunningCommandsShutdownKills(t *testing.T) {
+	runningCommands := newRunningCommands()
+
+	commandCtx, done, err := runningCommands.start(context.Background())
+	if err != nil {
+		t.Fatalf("start error: %v", err)
+	}
+
+	go func() {
+		<-commandCtx.Done()
+		done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := runningCommands.shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("shutdown error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if cause := context.Cause(commandCtx); !errors.Is(cause, errorKilledOnShutdown) {
+		t.Fatalf("command context cause = %v, want %v", cause, errorKilledOnShutdown)
+	}
+}
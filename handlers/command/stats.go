@@ -0,0 +1,263 @@
+package command
+
+import (
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aaronriekenberg/go-api/utils"
+)
+
+// durationHistogramBounds are the upper bounds of the histogram buckets.
+// Durations above the last bound go in an overflow bucket.
+var durationHistogramBounds = []time.Duration{
+	1 * time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	60 * time.Second,
+}
+
+type durationHistogram struct {
+	bucketCounts []int64
+	count        int64
+	maxDuration  time.Duration
+}
+
+func newDurationHistogram() durationHistogram {
+	return durationHistogram{
+		bucketCounts: make([]int64, len(durationHistogramBounds)+1),
+	}
+}
+
+func (durationHistogram *durationHistogram) observe(duration time.Duration) {
+	bucket, _ := slices.BinarySearch(durationHistogramBounds, duration)
+	durationHistogram.bucketCounts[bucket]++
+	durationHistogram.count++
+	durationHistogram.maxDuration = max(durationHistogram.maxDuration, duration)
+}
+
+func (durationHistogram *durationHistogram) merge(other *durationHistogram) {
+	for i, bucketCount := range other.bucketCounts {
+		durationHistogram.bucketCounts[i] += bucketCount
+	}
+	durationHistogram.count += other.count
+	durationHistogram.maxDuration = max(durationHistogram.maxDuration, other.maxDuration)
+}
+
+// percentile estimates the duration below which fraction of observations fall,
+// as the upper bound of the bucket it lands in, never more than the max observed.
+func (durationHistogram *durationHistogram) percentile(fraction float64) time.Duration {
+	if durationHistogram.count == 0 {
+		return 0
+	}
+
+	rank := max(int64(math.Ceil(fraction*float64(durationHistogram.count))), 1)
+
+	var cumulativeCount int64
+	for bucket, bucketCount := range durationHistogram.bucketCounts {
+		cumulativeCount += bucketCount
+		if cumulativeCount >= rank && bucket < len(durationHistogramBounds) {
+			return min(durationHistogramBounds[bucket], durationHistogram.maxDuration)
+		}
+	}
+
+	return durationHistogram.maxDuration
+}
+
+// commandStats counts the runs and semaphore rejections of one command.
+type commandStats struct {
+	mutex             sync.Mutex
+	runs              int64
+	errorKindCounts   map[commandErrorKind]int64
+	durationHistogram durationHistogram
+	totalDuration     time.Duration
+	lastRunTime       time.Time
+	lastError         string
+	lastErrorTime     time.Time
+}
+
+func newCommandStats() *commandStats {
+	return &commandStats{
+		errorKindCounts:   make(map[commandErrorKind]int64),
+		durationHistogram: newDurationHistogram(),
+	}
+}
+
+func (commandStats *commandStats) recordErrorLocked(
+	errorKind commandErrorKind,
+	err error,
+	now time.Time,
+) {
+	commandStats.errorKindCounts[errorKind]++
+
+	if err != nil {
+		commandStats.lastError = err.Error()
+		commandStats.lastErrorTime = now
+	}
+}
+
+func (commandStats *commandStats) recordRun(
+	duration time.Duration,
+	errorKind commandErrorKind,
+	err error,
+) {
+	commandStats.mutex.Lock()
+	defer commandStats.mutex.Unlock()
+
+	now := time.Now()
+
+	commandStats.runs++
+	commandStats.durationHistogram.observe(duration)
+	commandStats.totalDuration += duration
+	commandStats.lastRunTime = now
+
+	commandStats.recordErrorLocked(errorKind, err, now)
+}
+
+func (commandStats *commandStats) recordSemaphoreRejection(err error) {
+	commandStats.mutex.Lock()
+	defer commandStats.mutex.Unlock()
+
+	commandStats.recordErrorLocked(commandErrorKindSemaphoreRejection, err, time.Now())
+}
+
+// merge adds other into commandStats, keeping the most recent last error.
+func (commandStats *commandStats) merge(other *commandStats) {
+	other.mutex.Lock()
+	defer other.mutex.Unlock()
+
+	commandStats.runs += other.runs
+	for errorKind, count := range other.errorKindCounts {
+		commandStats.errorKindCounts[errorKind] += count
+	}
+	commandStats.durationHistogram.merge(&other.durationHistogram)
+	commandStats.totalDuration += other.totalDuration
+
+	if other.lastRunTime.After(commandStats.lastRunTime) {
+		commandStats.lastRunTime = other.lastRunTime
+	}
+
+	if other.lastErrorTime.After(commandStats.lastErrorTime) {
+		commandStats.lastError = other.lastError
+		commandStats.lastErrorTime = other.lastErrorTime
+	}
+}
+
+type commandStatsDTO struct {
+	ID                  string     `json:"id,omitempty"`
+	Runs                int64      `json:"runs"`
+	Successes           int64      `json:"successes"`
+	Timeouts            int64      `json:"timeouts"`
+	NonZeroExits        int64      `json:"non_zero_exits"`
+	ExecFailures        int64      `json:"exec_failures"`
+	SemaphoreRejections int64      `json:"semaphore_rejections"`
+	MeanDurationMillis  int64      `json:"mean_duration_ms"`
+	P50DurationMillis   int64      `json:"p50_duration_ms"`
+	P90DurationMillis   int64      `json:"p90_duration_ms"`
+	P99DurationMillis   int64      `json:"p99_duration_ms"`
+	MaxDurationMillis   int64      `json:"max_duration_ms"`
+	LastRunTime         *time.Time `json:"last_run_time,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastErrorTime       *time.Time `json:"last_error_time,omitempty"`
+}
+
+func (commandStats *commandStats) toDTO(id string) commandStatsDTO {
+	commandStats.mutex.Lock()
+	defer commandStats.mutex.Unlock()
+
+	durationHistogram := &commandStats.durationHistogram
+
+	commandStatsDTO := commandStatsDTO{
+		ID:                  id,
+		Runs:                commandStats.runs,
+		Successes:           commandStats.errorKindCounts[commandErrorKindNone],
+		Timeouts:            commandStats.errorKindCounts[commandErrorKindTimeout],
+		NonZeroExits:        commandStats.errorKindCounts[commandErrorKindNonZeroExit],
+		ExecFailures:        commandStats.errorKindCounts[commandErrorKindExecFailure],
+		SemaphoreRejections: commandStats.errorKindCounts[commandErrorKindSemaphoreRejection],
+		P50DurationMillis:   durationHistogram.percentile(0.50).Milliseconds(),
+		P90DurationMillis:   durationHistogram.percentile(0.90).Milliseconds(),
+		P99DurationMillis:   durationHistogram.percentile(0.99).Milliseconds(),
+		MaxDurationMillis:   durationHistogram.maxDuration.Milliseconds(),
+		LastError:           commandStats.lastError,
+	}
+
+	if commandStats.runs > 0 {
+		commandStatsDTO.MeanDurationMillis = (commandStats.totalDuration / time.Duration(commandStats.runs)).Milliseconds()
+	}
+
+	if !commandStats.lastRunTime.IsZero() {
+		commandStatsDTO.LastRunTime = new(commandStats.lastRunTime)
+	}
+
+	if !commandStats.lastErrorTime.IsZero() {
+		commandStatsDTO.LastErrorTime = new(commandStats.lastErrorTime)
+	}
+
+	return commandStatsDTO
+}
+
+type allCommandStatsDTO struct {
+	Total    commandStatsDTO   `json:"total"`
+	Commands []commandStatsDTO `json:"commands"`
+}
+
+func (commands *Commands) NewAllCommandStatsHandler() http.Handler {
+	commandRunner := commands.commandRunner
+	commandInfos := commands.commandConfiguration.Commands
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestIsExternal := commandRunner.requestIsExternal(r)
+
+		totalStats := newCommandStats()
+
+		response := allCommandStatsDTO{
+			Commands: make([]commandStatsDTO, 0, len(commandInfos)),
+		}
+
+		for _, commandInfo := range commandInfos {
+			if commandInfo.InternalOnly && requestIsExternal {
+				continue
+			}
+
+			commandStats := commandRunner.idToStats[commandInfo.ID]
+
+			totalStats.merge(commandStats)
+
+			response.Commands = append(response.Commands, commandStats.toDTO(commandInfo.ID))
+		}
+
+		response.Total = totalStats.toDTO("")
+
+		utils.RespondWithJSONDTO(&response, w)
+	})
+}
+
+func (commands *Commands) NewCommandStatsHandler() http.Handler {
+	commandRunner := commands.commandRunner
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commandInfo, ok := commandRunner.commandInfoForRequest(r)
+		if !ok {
+			utils.HTTPErrorStatusCode(w, http.StatusNotFound)
+			return
+		}
+
+		commandStatsDTO := commandRunner.idToStats[commandInfo.ID].toDTO(commandInfo.ID)
+
+		utils.RespondWithJSONDTO(&commandStatsDTO, w)
+	})
+}
@@ -0,0 +1,83 @@
+package command
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDurationHistogramPercentile(t *testing.T) {
+	durationHistogram := newDurationHistogram()
+
+	if got := durationHistogram.percentile(0.5); got != 0 {
+		t.Errorf("empty histogram got p50 %v want 0", got)
+	}
+
+	// 90 fast observations, 9 medium, 1 slow
+	for range 90 {
+		durationHistogram.observe(3 * time.Millisecond)
+	}
+	for range 9 {
+		durationHistogram.observe(400 * time.Millisecond)
+	}
+	durationHistogram.observe(90 * time.Second)
+
+	tests := map[string]struct {
+		fraction float64
+		want     time.Duration
+	}{
+		"p50":  {fraction: 0.50, want: 5 * time.Millisecond},
+		"p90":  {fraction: 0.90, want: 5 * time.Millisecond},
+		"p99":  {fraction: 0.99, want: 500 * time.Millisecond},
+		"p100": {fraction: 1, want: 90 * time.Second},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := durationHistogram.percentile(tc.fraction); got != tc.want {
+				t.Fatalf("got %v want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDurationHistogramPercentileCappedAtMax(t *testing.T) {
+	durationHistogram := newDurationHistogram()
+	durationHistogram.observe(3 * time.Millisecond)
+
+	if got := durationHistogram.percentile(0.99); got != 3*time.Millisecond {
+		t.Fatalf("got %v want %v", got, 3*time.Millisecond)
+	}
+}
+
+func TestCommandStats(t *testing.T) {
+	commandStats := newCommandStats()
+
+	commandStats.recordRun(10*time.Millisecond, commandErrorKindNone, nil)
+	commandStats.recordRun(30*time.Millisecond, commandErrorKindTimeout, errors.New("signal: killed"))
+	commandStats.recordRun(20*time.Millisecond, commandErrorKindNonZeroExit, errors.New("exit status 1"))
+	commandStats.recordSemaphoreRejection(errors.New("rejected"))
+
+	commandStatsDTO := commandStats.toDTO("test")
+
+	if commandStatsDTO.Runs != 3 || commandStatsDTO.Successes != 1 || commandStatsDTO.Timeouts != 1 ||
+		commandStatsDTO.NonZeroExits != 1 || commandStatsDTO.SemaphoreRejections != 1 {
+		t.Errorf("got counts %+v", commandStatsDTO)
+	}
+
+	if commandStatsDTO.MeanDurationMillis != 20 || commandStatsDTO.MaxDurationMillis != 30 {
+		t.Errorf("got mean %d max %d", commandStatsDTO.MeanDurationMillis, commandStatsDTO.MaxDurationMillis)
+	}
+
+	if commandStatsDTO.LastError != "rejected" || commandStatsDTO.LastErrorTime == nil {
+		t.Errorf("got last error %q time %v", commandStatsDTO.LastError, commandStatsDTO.LastErrorTime)
+	}
+
+	totalStats := newCommandStats()
+	totalStats.merge(commandStats)
+	totalStats.merge(commandStats)
+
+	if totalDTO := totalStats.toDTO(""); totalDTO.Runs != 6 || totalDTO.SemaphoreRejections != 2 || totalDTO.LastError != "rejected" {
+		t.Errorf("got merged %+v", totalDTO)
+	}
+}
@@ -0,0 +1,238 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aaronriekenberg/go-api/config"
+	"github.com/aaronriekenberg/go-api/utils"
+)
+
+const (
+	contentTypeTextEventStream = "text/event-stream"
+
+	streamEventStdout = "stdout"
+	streamEventStderr = "stderr"
+	streamEventExit   = "exit"
+
+	streamEventChannelCapacity = 100
+)
+
+type streamEvent struct {
+	name string
+	data string
+}
+
+// lineEventWriter splits process output into lines and sends each
+// complete line as a streamEvent.  Output beyond outputLimiter is dropped.
+type lineEventWriter struct {
+	eventName     string
+	eventChannel  chan<- streamEvent
+	outputLimiter *outputLimiter
+	buffer        bytes.Buffer
+}
+
+var _ io.Writer = (*lineEventWriter)(nil)
+
+func (lineEventWriter *lineEventWriter) Write(p []byte) (n int, err error) {
+	lineEventWriter.buffer.Write(lineEventWriter.outputLimiter.keep(p))
+
+	for {
+		line, readErr := lineEventWriter.buffer.ReadString('\n')
+		if readErr != nil {
+			// incomplete line, keep it for the next Write
+			lineEventWriter.buffer.WriteString(line)
+			break
+		}
+		lineEventWriter.sendLine(line)
+	}
+
+	return len(p), nil
+}
+
+func (lineEventWriter *lineEventWriter) flush() {
+	if lineEventWriter.buffer.Len() > 0 {
+		line := lineEventWriter.buffer.Bytes()
+		if lineEventWriter.outputLimiter.truncated() {
+			line = trimIncompleteRune(line)
+		}
+		lineEventWriter.sendLine(string(line))
+		lineEventWriter.buffer.Reset()
+	}
+}
+
+func (lineEventWriter *lineEventWriter) sendLine(line string) {
+	lineEventWriter.eventChannel <- streamEvent{
+		name: lineEventWriter.eventName,
+		// a bare CR would end the SSE data line early
+		data: strings.ReplaceAll(strings.TrimRight(line, "\r\n"), "\r", ""),
+	}
+}
+
+type streamExitDTO struct {
+	ExitCode                    *int             `json:"exit_code,omitempty"`
+	Signal                      string           `json:"signal,omitempty"`
+	ErrorKind                   commandErrorKind `json:"error_kind,omitempty"`
+	CommandDurationMilliseconds int64            `json:"command_duration_ms"`
+	Truncated                   bool             `json:"truncated,omitzero"`
+	TotalOutputBytes            int64            `json:"total_output_bytes"`
+	Error                       string           `json:"error,omitempty"`
+}
+
+type streamCommandHandler struct {
+	commandRunner *commandRunner
+}
+
+func (commands *Commands) NewStreamCommandHandler() http.Handler {
+	return &streamCommandHandler{
+		commandRunner: commands.commandRunner,
+	}
+}
+
+func (streamCommandHandler *streamCommandHandler) ServeHTTP(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	commandRunner := streamCommandHandler.commandRunner
+
+	commandInfo, ok := commandRunner.runnableCommandInfoForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), commandRunner.requestTimeout(commandInfo))
+	defer cancel()
+
+	admissionClass := commandRunner.admissionClassForRequest(r)
+
+	err := commandRunner.acquireCommandSemaphore(ctx, commandInfo, admissionClass)
+	if err != nil {
+		slog.Warn("StreamCommandHandler.acquireCommandSemaphore returned error",
+			"error", err,
+		)
+		utils.HTTPErrorStatusCode(w, http.StatusTooManyRequests)
+		return
+	}
+	defer commandRunner.releaseCommandSemaphore(commandInfo, admissionClass)
+
+	ctx, done, err := commandRunner.runningCommands.start(ctx)
+	if err != nil {
+		slog.Warn("StreamCommandHandler.runningCommands.start returned error",
+			"error", err,
+		)
+		utils.HTTPErrorStatusCode(w, http.StatusServiceUnavailable)
+		return
+	}
+	defer done()
+
+	streamCommandHandler.streamCommand(ctx, commandInfo, w)
+}
+
+func (streamCommandHandler *streamCommandHandler) streamCommand(
+	ctx context.Context,
+	commandInfo config.CommandInfo,
+	w http.ResponseWriter,
+) {
+	responseController := http.NewResponseController(w)
+
+	w.Header().Set(utils.ContentTypeHeaderKey, contentTypeTextEventStream)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	responseController.Flush()
+
+	eventChannel := make(chan streamEvent, streamEventChannelCapacity)
+
+	commandRunner := streamCommandHandler.commandRunner
+
+	outputLimiter := newOutputLimiter(commandRunner.maxOutputBytes(commandInfo))
+
+	stdoutWriter := &lineEventWriter{
+		eventName:     streamEventStdout,
+		eventChannel:  eventChannel,
+		outputLimiter: outputLimiter,
+	}
+	stderrWriter := &lineEventWriter{
+		eventName:     streamEventStderr,
+		eventChannel:  eventChannel,
+		outputLimiter: outputLimiter,
+	}
+
+	cmd := commandRunner.newCmd(ctx, commandInfo)
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
+
+	commandStartTime := time.Now()
+
+	var commandErr error
+	var waitGroup sync.WaitGroup
+	waitGroup.Go(func() {
+		defer close(eventChannel)
+
+		commandErr = cmd.Run()
+
+		stdoutWriter.flush()
+		stderrWriter.flush()
+	})
+
+	writeOK := true
+	for event := range eventChannel {
+		// keep draining after a write failure so the command is never blocked
+		if writeOK {
+			writeOK = writeStreamEvent(w, responseController, event)
+		}
+	}
+	waitGroup.Wait()
+
+	commandDuration := time.Since(commandStartTime)
+
+	exitStatus := classifyCommandExit(ctx, cmd.Cmd, commandErr)
+
+	commandRunner.recordCommandRun(commandInfo, commandDuration, exitStatus, commandErr)
+
+	exitDTO := streamExitDTO{
+		ExitCode:                    exitStatus.exitCode,
+		Signal:                      exitStatus.signal,
+		ErrorKind:                   exitStatus.errorKind,
+		CommandDurationMilliseconds: commandDuration.Milliseconds(),
+		Truncated:                   outputLimiter.truncated(),
+		TotalOutputBytes:            outputLimiter.total(),
+	}
+
+	if commandErr != nil {
+		exitDTO.Error = commandErr.Error()
+	}
+
+	if writeOK {
+		writeStreamEvent(w, responseController, streamEvent{
+			name: streamEventExit,
+			data: string(utils.MustMarshalJSON(&exitDTO)),
+		})
+	}
+}
+
+func writeStreamEvent(
+	w io.Writer,
+	responseController *http.ResponseController,
+	event streamEvent,
+) bool {
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.name, event.data)
+	if err == nil {
+		err = responseController.Flush()
+	}
+
+	if err != nil {
+		slog.Warn("writeStreamEvent error",
+			"error", err,
+		)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,220 @@
+package command
+
+import (
+	"context"
+	"encoding/json/v2"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aaronriekenberg/go-api/config"
+	"github.com/aaronriekenberg/go-api/utils"
+)
+
+func TestLineEventWriter(t *testing.T) {
+	eventChannel := make(chan streamEvent, 10)
+
+	writer := &lineEventWriter{
+		eventName:     streamEventStdout,
+		eventChannel:  eventChannel,
+		outputLimiter: newOutputLimiter(1024),
+	}
+
+	writer.Write([]byte("first li"))
+	writer.Write([]byte("ne\r\nsecond line\nthi"))
+	writer.Write([]byte("rd\rline"))
+	writer.flush()
+	close(eventChannel)
+
+	var lines []string
+	for event := range eventChannel {
+		if event.name != streamEventStdout {
+			t.Errorf("got event name %q want %q", event.name, streamEventStdout)
+		}
+		lines = append(lines, event.data)
+	}
+
+	wantLines := []string{"first line", "second line", "thirdline"}
+	if !slices.Equal(lines, wantLines) {
+		t.Fatalf("got lines %q want %q", lines, wantLines)
+	}
+}
+
+const externalHeaderKey = "X-Test-External"
+
+func requestHasExternalHeader(r *http.Request) bool {
+	return r.Header.Get(externalHeaderKey) != ""
+}
+
+func newTestStreamCommands(
+	t *testing.T,
+	commandInfos ...config.CommandInfo,
+) *Commands {
+	t.Helper()
+
+	commands, err := NewCommands(
+		config.CommandConfiguration{
+			MaxConcurrentCommands:           1,
+			RequestTimeoutDuration:          5 * time.Second,
+			SemaphoreAcquireTimeoutDuration: 100 * time.Millisecond,
+			Commands:                        commandInfos,
+		},
+		requestHasExternalHeader,
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("NewCommands error: %v", err)
+	}
+
+	return commands
+}
+
+// serveStream runs the stream handler of commands for command id to completion.
+func serveStream(
+	commands *Commands,
+	id string,
+	external bool,
+) *httptest.ResponseRecorder {
+	serveMux := http.NewServeMux()
+	serveMux.Handle("GET /commands/{id}/stream", commands.NewStreamCommandHandler())
+
+	r := httptest.NewRequest(http.MethodGet, "/commands/"+id+"/stream", nil)
+	if external {
+		r.Header.Set(externalHeaderKey, "true")
+	}
+
+	w := httptest.NewRecorder()
+	serveMux.ServeHTTP(w, r)
+	return w
+}
+
+// parseStreamEvents returns the events of a text/event-stream body and the decoded exit event.
+func parseStreamEvents(
+	t *testing.T,
+	body string,
+) (events []streamEvent, exitDTO streamExitDTO) {
+	t.Helper()
+
+	for block := range strings.SplitSeq(strings.TrimSpace(body), "\n\n") {
+		name, data, ok := strings.Cut(block, "\n")
+		if !ok || !strings.HasPrefix(name, "event: ") || !strings.HasPrefix(data, "data: ") {
+			t.Fatalf("malformed event %q", block)
+		}
+
+		events = append(events, streamEvent{
+			name: strings.TrimPrefix(name, "event: "),
+			data: strings.TrimPrefix(data, "data: "),
+		})
+	}
+
+	if len(events) == 0 || events[len(events)-1].name != streamEventExit {
+		t.Fatalf("got events %+v want exit event last", events)
+	}
+
+	if err := json.Unmarshal([]byte(events[len(events)-1].data), &exitDTO); err != nil {
+		t.Fatalf("json.Unmarshal exit event error: %v", err)
+	}
+
+	return events[:len(events)-1], exitDTO
+}
+
+func TestStreamCommandHandlerInternalOnly(t *testing.T) {
+	commands := newTestStreamCommands(t, config.CommandInfo{
+		ID:           "internal",
+		InternalOnly: true,
+		Command:      "/bin/echo",
+		Args:         []string{"hello"},
+	})
+
+	if w := serveStream(commands, "internal", true); w.Code != http.StatusNotFound {
+		t.Fatalf("external request got status %d want %d", w.Code, http.StatusNotFound)
+	}
+
+	if w := serveStream(commands, "internal", false); w.Code != http.StatusOK {
+		t.Fatalf("internal request got status %d want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestStreamCommandHandlerSemaphoreExhausted(t *testing.T) {
+	commandInfo := config.CommandInfo{
+		ID:      "echo",
+		Command: "/bin/echo",
+	}
+	commands := newTestStreamCommands(t, commandInfo)
+
+	commandRunner := commands.commandRunner
+	if err := commandRunner.acquireCommandSemaphore(context.Background(), commandInfo, admissionClassInternal); err != nil {
+		t.Fatalf("acquireCommandSemaphore error: %v", err)
+	}
+	defer commandRunner.releaseCommandSemaphore(commandInfo, admissionClassInternal)
+
+	if w := serveStream(commands, "echo", false); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("got status %d want %d", w.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestStreamCommandHandlerTimeout(t *testing.T) {
+	commands := newTestStreamCommands(t, config.CommandInfo{
+		ID:                     "sleep",
+		Command:                "/bin/sh",
+		Args:                   []string{"-c", "echo started; sleep 5; echo finished"},
+		RequestTimeoutDuration: 200 * time.Millisecond,
+	})
+
+	startTime := time.Now()
+	w := serveStream(commands, "sleep", false)
+	duration := time.Since(startTime)
+
+	if duration >= 5*time.Second {
+		t.Fatalf("stream took %v, command was not killed on timeout", duration)
+	}
+
+	events, exitDTO := parseStreamEvents(t, w.Body.String())
+
+	wantEvents := []streamEvent{{name: streamEventStdout, data: "started"}}
+	if !slices.Equal(events, wantEvents) {
+		t.Fatalf("got events %+v want %+v", events, wantEvents)
+	}
+
+	if exitDTO.ErrorKind != commandErrorKindTimeout || exitDTO.Signal == "" {
+		t.Fatalf("got exit %+v want timeout killed by a signal", exitDTO)
+	}
+}
+
+func TestStreamCommandHandlerExitEvent(t *testing.T) {
+	commands := newTestStreamCommands(t, config.CommandInfo{
+		ID:      "fail",
+		Command: "/bin/sh",
+		Args:    []string{"-c", "echo out; echo err >&2; exit 3"},
+	})
+
+	w := serveStream(commands, "fail", false)
+
+	if contentType := w.Header().Get(utils.ContentTypeHeaderKey); contentType != contentTypeTextEventStream {
+		t.Fatalf("got content type %q want %q", contentType, contentTypeTextEventStream)
+	}
+
+	events, exitDTO := parseStreamEvents(t, w.Body.String())
+
+	slices.SortFunc(events, func(a, b streamEvent) int {
+		return strings.Compare(a.name, b.name)
+	})
+	wantEvents := []streamEvent{
+		{name: streamEventStderr, data: "err"},
+		{name: streamEventStdout, data: "out"},
+	}
+	if !slices.Equal(events, wantEvents) {
+		t.Fatalf("got events %+v want %+v", events, wantEvents)
+	}
+
+	if exitDTO.ExitCode == nil || *exitDTO.ExitCode != 3 || exitDTO.ErrorKind != commandErrorKindNonZeroExit {
+		t.Fatalf("got exit %+v want exit code 3 and error kind %q", exitDTO, commandErrorKindNonZeroExit)
+	}
+
+	if exitDTO.TotalOutputBytes != int64(len("out\nerr\n")) {
+		t.Fatalf("got total output bytes %d want %d", exitDTO.TotalOutputBytes, len("out\nerr\n"))
+	}
+}
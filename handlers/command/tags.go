@@ -0,0 +1,93 @@
+package command
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/aaronriekenberg/go-api/config"
+	"github.com/aaronriekenberg/go-api/utils"
+)
+
+const (
+	tagQueryParameter    = "tag"
+	searchQueryParameter = "q"
+)
+
+// filterCommandDTOs returns the commands having every tag in tags whose id or description
+// contains search, ignoring case.
+func filterCommandDTOs(
+	commandDTOs []commandInfoDTO,
+	tags []string,
+	search string,
+) []commandInfoDTO {
+	search = strings.ToLower(search)
+
+	filteredCommandDTOs := make([]commandInfoDTO, 0, len(commandDTOs))
+
+	for _, commandDTO := range commandDTOs {
+		hasAllTags := true
+		for _, tag := range tags {
+			if !slices.Contains(commandDTO.Tags, tag) {
+				hasAllTags = false
+				break
+			}
+		}
+		if !hasAllTags {
+			continue
+		}
+
+		if search != "" &&
+			!strings.Contains(strings.ToLower(commandDTO.ID), search) &&
+			!strings.Contains(strings.ToLower(commandDTO.Description), search) {
+			continue
+		}
+
+		filteredCommandDTOs = append(filteredCommandDTOs, commandDTO)
+	}
+
+	return filteredCommandDTOs
+}
+
+type commandTagDTO struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// commandTagDTOs counts the commands with each tag, sorted by tag.
+func commandTagDTOs(
+	commandInfos []config.CommandInfo,
+) []commandTagDTO {
+	tagToCount := make(map[string]int)
+
+	for _, commandInfo := range commandInfos {
+		for _, tag := range commandInfo.Tags {
+			tagToCount[tag]++
+		}
+	}
+
+	tagDTOs := make([]commandTagDTO, 0, len(tagToCount))
+	for _, tag := range slices.Sorted(maps.Keys(tagToCount)) {
+		tagDTOs = append(tagDTOs, commandTagDTO{
+			Tag:   tag,
+			Count: tagToCount[tag],
+		})
+	}
+
+	return tagDTOs
+}
+
+func (commands *Commands) NewCommandTagsHandler() http.Handler {
+	commandInfos := commands.commandConfiguration.Commands
+
+	externalCommandInfos := slices.DeleteFunc(slices.Clone(commandInfos), func(commandInfo config.CommandInfo) bool {
+		return commandInfo.InternalOnly
+	})
+
+	return &externalAwareHandler{
+		requestIsExternal: commands.commandRunner.requestIsExternal,
+		allHandler:        utils.JSONBytesHandlerFunc(utils.MustMarshalJSON(commandTagDTOs(commandInfos))),
+		externalHandler:   utils.JSONBytesHandlerFunc(utils.MustMarshalJSON(commandTagDTOs(externalCommandInfos))),
+	}
+}
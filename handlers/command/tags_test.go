@@ -0,0 +1,64 @@
+package command
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aaronriekenberg/go-api/config"
+)
+
+func TestFilterCommandDTOs(t *testing.T) {
+	commandDTOs := []commandInfoDTO{
+		{ID: "chronyc_tracking", Description: "chronyc tracking", Tags: []string{"time"}},
+		{ID: "df", Description: "df", Tags: []string{"disk"}},
+		{ID: "ip_addr", Description: "ip addr", Tags: []string{"network"}},
+		{ID: "netstat_an", Description: "netstat -an", Tags: []string{"network", "sockets"}},
+		{ID: "uptime", Description: "Uptime"},
+	}
+
+	tests := map[string]struct {
+		tags    []string
+		search  string
+		wantIDs []string
+	}{
+		"tag":                 {tags: []string{"network"}, wantIDs: []string{"ip_addr", "netstat_an"}},
+		"all tags":            {tags: []string{"network", "sockets"}, wantIDs: []string{"netstat_an"}},
+		"unknown tag":         {tags: []string{"bogus"}, wantIDs: []string{}},
+		"search id":           {search: "chrony", wantIDs: []string{"chronyc_tracking"}},
+		"search ignores case": {search: "UPTIME", wantIDs: []string{"uptime"}},
+		"search description":  {search: "-an", wantIDs: []string{"netstat_an"}},
+		"tag and search":      {tags: []string{"network"}, search: "ip", wantIDs: []string{"ip_addr"}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ids := []string{}
+			for _, commandDTO := range filterCommandDTOs(commandDTOs, tc.tags, tc.search) {
+				ids = append(ids, commandDTO.ID)
+			}
+
+			if !reflect.DeepEqual(ids, tc.wantIDs) {
+				t.Fatalf("got ids %q want %q", ids, tc.wantIDs)
+			}
+		})
+	}
+}
+
+func TestCommandTagDTOs(t *testing.T) {
+	got := commandTagDTOs([]config.CommandInfo{
+		{ID: "ip_addr", Tags: []string{"network"}},
+		{ID: "netstat_an", Tags: []string{"sockets", "network"}},
+		{ID: "df", Tags: []string{"disk"}},
+		{ID: "uptime"},
+	})
+
+	want := []commandTagDTO{
+		{Tag: "disk", Count: 1},
+		{Tag: "network", Count: 2},
+		{Tag: "sockets", Count: 1},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package configinfo
+
+import (
+	"encoding/json/jsontext"
+	"fmt"
+	"net/http"
+
+	"github.com/aaronriekenberg/go-api/config"
+	"github.com/aaronriekenberg/go-api/request"
+	"github.com/aaronriekenberg/go-api/utils"
+)
+
+type configInfoDTO struct {
+	ConfigFile    string         `json:"config_file"`
+	Files         []config.File  `json:"files"`
+	Configuration jsontext.Value `json:"configuration"`
+}
+
+// NewConfigInfoHandler shows layeredConfiguration with secret values redacted to internal requests.
+func NewConfigInfoHandler(
+	layeredConfiguration *config.LayeredConfiguration,
+	requestIsExternal request.IsExternal,
+) (http.Handler, error) {
+	redactedConfiguration, err := config.MarshalRedacted(layeredConfiguration.Configuration)
+	if err != nil {
+		return nil, fmt.Errorf("config.MarshalRedacted error: %w", err)
+	}
+
+	dto := configInfoDTO{
+		Files:         layeredConfiguration.Files,
+		Configuration: redactedConfiguration,
+	}
+	if len(layeredConfiguration.Files) > 0 {
+		dto.ConfigFile = layeredConfiguration.Files[0].Path
+	}
+
+	jsonBytesHandler := utils.JSONBytesHandlerFunc(utils.MustMarshalJSON(&dto))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requestIsExternal(r) {
+			utils.HTTPErrorStatusCode(w, http.StatusNotFound)
+			return
+		}
+
+		jsonBytesHandler(w, r)
+	}), nil
+}
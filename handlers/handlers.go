@@ -1,30 +1,102 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"path"
+	"sync"
+	"sync/atomic"
 
 	"github.com/aaronriekenberg/go-api/config"
 	"github.com/aaronriekenberg/go-api/handlers/command"
+	"github.com/aaronriekenberg/go-api/handlers/configinfo"
 	"github.com/aaronriekenberg/go-api/handlers/connectioninfo"
 	"github.com/aaronriekenberg/go-api/handlers/health"
 	"github.com/aaronriekenberg/go-api/handlers/requestinfo"
 	"github.com/aaronriekenberg/go-api/handlers/requestlogging"
 	"github.com/aaronriekenberg/go-api/handlers/versioninfo"
+	"github.com/aaronriekenberg/go-api/request"
 )
 
-func CreateHandlers() http.Handler {
+// Handlers serves requests with the handlers for the current configuration.
+type Handlers struct {
+	reloadMutex   sync.Mutex
+	apiHandlers   atomic.Pointer[apiHandlers]
+	requestLogger *requestlogging.RequestLogger
+	draining      atomic.Bool
+	upgrade       UpgradeFunc
+}
+
+// apiHandlers are the handlers created from one configuration.
+type apiHandlers struct {
+	layeredConfiguration *config.LayeredConfiguration
+	commands             *command.Commands
+	mux                  *http.ServeMux
+}
+
+// UpgradeFunc replaces the process with a new one serving on the same listeners, returning the new process ID.
+type UpgradeFunc func() (pid int, err error)
+
+func CreateHandlers(
+	upgrade UpgradeFunc,
+) (*Handlers, error) {
+
+	layeredConfiguration := config.LayeredInstance()
+
+	handlers := &Handlers{
+		upgrade: upgrade,
+	}
+
+	apiHandlers, err := handlers.newAPIHandlers(layeredConfiguration, nil)
+	if err != nil {
+		return nil, fmt.Errorf("CreateHandlers: newAPIHandlers error: %w", err)
+	}
+
+	handlers.apiHandlers.Store(apiHandlers)
+	apiHandlers.commands.Start()
+
+	handlers.requestLogger = requestlogging.NewRequestLogger(
+		layeredConfiguration.Configuration.RequestLoggingConfiguration,
+		http.HandlerFunc(handlers.serveAPI),
+	)
+
+	return handlers, nil
+}
+
+func (handlers *Handlers) newAPIHandlers(
+	layeredConfiguration *config.LayeredConfiguration,
+	previousCommands *command.Commands,
+) (*apiHandlers, error) {
+
+	configuration := layeredConfiguration.Configuration
+
+	requestIsExternal := request.NewExternalCheck(configuration.RequestConfiguration)
+
+	commands, err := command.NewCommands(
+		configuration.CommandConfiguration,
+		requestIsExternal,
+		previousCommands,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("command.NewCommands error: %w", err)
+	}
+
+	configInfoHandler, err := configinfo.NewConfigInfoHandler(layeredConfiguration, requestIsExternal)
+	if err != nil {
+		return nil, fmt.Errorf("configinfo.NewConfigInfoHandler error: %w", err)
+	}
 
 	mux := http.NewServeMux()
 
-	apiContext := config.Instance().ServerConfiguration.APIContext
+	apiContext := configuration.ServerConfiguration.APIContext
 
 	slog.Info("CreateHandlers",
 		"apiContext", apiContext,
 	)
 
-	mux.Handle("GET /health", health.NewHealthHandler())
+	mux.Handle("GET /health", health.NewHealthHandler(&handlers.draining))
 
 	handleAPIGET := func(
 		relativePath string,
@@ -33,9 +105,42 @@ func CreateHandlers() http.Handler {
 		mux.Handle("GET "+path.Join(apiContext, relativePath), handler)
 	}
 
-	handleAPIGET("/commands", command.NewAllCommandsHandler())
+	handleAPIPOST := func(
+		relativePath string,
+		handler http.Handler,
+	) {
+		mux.Handle("POST "+path.Join(apiContext, relativePath), handler)
+	}
+
+	handleAPIPOST("/admin/reload_config", handlers.newReloadConfigurationHandler(requestIsExternal))
+
+	handleAPIPOST("/admin/upgrade", handlers.newUpgradeHandler(requestIsExternal))
+
+	handleAPIGET("/commands", commands.NewAllCommandsHandler())
+
+	handleAPIGET("/commands/limits", commands.NewCommandLimitsHandler())
+
+	handleAPIGET("/commands/tags", commands.NewCommandTagsHandler())
+
+	handleAPIGET("/commands/stats", commands.NewAllCommandStatsHandler())
+
+	handleAPIGET("/commands/{id}", commands.NewRunCommandsHandler())
+
+	handleAPIGET("/commands/{id}/stream", commands.NewStreamCommandHandler())
+
+	handleAPIGET("/commands/{id}/history", commands.NewCommandHistoryHandler())
+
+	handleAPIGET("/commands/{id}/stats", commands.NewCommandStatsHandler())
+
+	handleAPIPOST("/commands/{id}/jobs", commands.NewCreateJobHandler())
+
+	handleAPIGET("/commands/{id}/jobs/{jobID}", commands.NewGetJobHandler())
+
+	handleAPIGET("/composite_commands", commands.NewAllCompositeCommandsHandler())
+
+	handleAPIGET("/composite_commands/{id}", commands.NewRunCompositeCommandHandler())
 
-	handleAPIGET("/commands/{id}", command.NewRunCommandsHandler())
+	handleAPIGET("/config_info", configInfoHandler)
 
 	handleAPIGET("/connection_info", connectioninfo.NewConnectionInfoHandler())
 
@@ -43,5 +148,46 @@ func CreateHandlers() http.Handler {
 
 	handleAPIGET("/version_info", versioninfo.NewVersionInfoHandler())
 
-	return requestlogging.NewRequestLogger(mux)
+	return &apiHandlers{
+		layeredConfiguration: layeredConfiguration,
+		commands:             commands,
+		mux:                  mux,
+	}, nil
+}
+
+func (handlers *Handlers) serveAPI(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	handlers.apiHandlers.Load().mux.ServeHTTP(w, r)
+}
+
+func (handlers *Handlers) ServeHTTP(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	handlers.requestLogger.ServeHTTP(w, r)
+}
+
+// StartDraining makes /health report draining, before the server stops accepting requests on shutdown.
+func (handlers *Handlers) StartDraining() {
+	slog.Info("Handlers.StartDraining")
+
+	handlers.draining.Store(true)
+}
+
+// Shutdown waits for running commands until ctx is done, killing them after that,
+// then flushes and closes the request log.  It is called after the server has shut down.
+func (handlers *Handlers) Shutdown(ctx context.Context) error {
+	handlers.reloadMutex.Lock()
+	defer handlers.reloadMutex.Unlock()
+
+	err := handlers.apiHandlers.Load().commands.Shutdown(ctx)
+
+	handlers.requestLogger.Close()
+
+	if err != nil {
+		return fmt.Errorf("commands.Shutdown error: %w", err)
+	}
+	return nil
 }
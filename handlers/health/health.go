@@ -2,14 +2,31 @@ package health
 
 import (
 	"net/http"
+	"sync/atomic"
 
 	"github.com/aaronriekenberg/go-api/utils"
 )
 
 const (
 	responseBodyString = "all good"
+
+	drainingResponseBodyString = "draining"
 )
 
-func NewHealthHandler() http.Handler {
-	return utils.PlainTextHandlerFunc(responseBodyString)
+// NewHealthHandler responds 503 "draining" once draining is set, so load balancers stop sending requests.
+func NewHealthHandler(
+	draining *atomic.Bool,
+) http.Handler {
+	healthyHandler := utils.PlainTextHandlerFunc(responseBodyString)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if draining.Load() {
+			w.Header().Set(utils.ContentTypeHeaderKey, utils.ContentTypeTextPlain)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(drainingResponseBodyString))
+			return
+		}
+
+		healthyHandler.ServeHTTP(w, r)
+	})
 }
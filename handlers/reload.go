@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/aaronriekenberg/go-api/config"
+	"github.com/aaronriekenberg/go-api/request"
+	"github.com/aaronriekenberg/go-api/utils"
+)
+
+// ReloadConfiguration reads the config file again and switches to handlers created from it.
+// If the new configuration has problems it is rejected before any handler is created
+// and the current one is kept, the error is then a *config.ValidationError.
+// Requests already being handled finish with the handlers they started with.
+func (handlers *Handlers) ReloadConfiguration() ([]config.Change, error) {
+	handlers.reloadMutex.Lock()
+	defer handlers.reloadMutex.Unlock()
+
+	slog.Info("begin ReloadConfiguration")
+
+	previousAPIHandlers := handlers.apiHandlers.Load()
+
+	layeredConfiguration, err := config.ReadConfiguration()
+	if err != nil {
+		return nil, fmt.Errorf("config.ReadConfiguration error: %w", err)
+	}
+
+	changes, err := config.Diff(previousAPIHandlers.layeredConfiguration.Configuration, layeredConfiguration.Configuration)
+	if err != nil {
+		return nil, fmt.Errorf("config.Diff error: %w", err)
+	}
+
+	apiHandlers, err := handlers.newAPIHandlers(layeredConfiguration, previousAPIHandlers.commands)
+	if err != nil {
+		return nil, fmt.Errorf("newAPIHandlers error: %w", err)
+	}
+
+	config.SetInstance(layeredConfiguration)
+	handlers.requestLogger.UpdateConfiguration(layeredConfiguration.Configuration.RequestLoggingConfiguration)
+	handlers.apiHandlers.Store(apiHandlers)
+
+	previousAPIHandlers.commands.Stop()
+	apiHandlers.commands.Start()
+
+	for _, change := range changes {
+		if change.RequiresRestart {
+			slog.Warn("configuration change requires restart",
+				"path", change.Path,
+				"old", change.Old,
+				"new", change.New,
+			)
+		} else {
+			slog.Info("configuration change",
+				"path", change.Path,
+				"old", change.Old,
+				"new", change.New,
+			)
+		}
+	}
+
+	slog.Info("end ReloadConfiguration",
+		"changes", len(changes),
+	)
+
+	return changes, nil
+}
+
+type reloadConfigurationDTO struct {
+	Reloaded    bool                `json:"reloaded"`
+	Changes     []config.Change     `json:"changes"`
+	Error       string              `json:"error,omitempty"`
+	Diagnostics []config.Diagnostic `json:"diagnostics,omitempty"`
+}
+
+func (handlers *Handlers) newReloadConfigurationHandler(
+	requestIsExternal request.IsExternal,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requestIsExternal(r) {
+			utils.HTTPErrorStatusCode(w, http.StatusNotFound)
+			return
+		}
+
+		changes, err := handlers.ReloadConfiguration()
+		if err != nil {
+			slog.Warn("reload configuration handler error",
+				"error", err,
+			)
+
+			response := reloadConfigurationDTO{
+				Error: err.Error(),
+			}
+
+			var validationError *config.ValidationError
+			if errors.As(err, &validationError) {
+				response.Diagnostics = validationError.Diagnostics
+			}
+			utils.RespondWithJSONDTOAndStatusCode(&response, http.StatusUnprocessableEntity, w)
+			return
+		}
+
+		response := reloadConfigurationDTO{
+			Reloaded: true,
+			Changes:  changes,
+		}
+		utils.RespondWithJSONDTO(&response, w)
+	})
+}
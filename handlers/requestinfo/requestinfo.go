@@ -20,6 +20,7 @@ type requestFieldsDTO struct {
 	Protocol      string                  `json:"protocol"`
 	RemoteAddress string                  `json:"remote_address"`
 	URL           string                  `json:"url"`
+	TLS           *request.TLSInfo        `json:"tls,omitempty"`
 }
 
 type requestInfoDTO struct {
@@ -61,6 +62,7 @@ func requestInfoHandlerFunc() http.HandlerFunc {
 				Protocol:      r.Proto,
 				RemoteAddress: r.RemoteAddr,
 				URL:           urlString,
+				TLS:           request.TLSInfoFromRequest(r),
 			},
 			RequestHeaders: httpHeaderToRequestHeaders(r.Header),
 		}
@@ -18,6 +18,7 @@ type requestLogData struct {
 	Protocol      string                  `json:"protocol"`
 	RemoteAddress string                  `json:"remote_address"`
 	URL           string                  `json:"url"`
+	TLS           *request.TLSInfo        `json:"tls,omitempty"`
 }
 
 type responseLogData struct {
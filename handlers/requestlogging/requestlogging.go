@@ -5,6 +5,7 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -18,23 +19,29 @@ import (
 
 const writeChannelCapacity = 1_000
 
+// RequestLogger logs each request to the request log file when enabled.
+// Its configuration can be updated while serving.
+type RequestLogger struct {
+	mutex             sync.Mutex
+	configuration     config.RequestLoggingConfiguration
+	closed            bool
+	enabled           atomic.Bool
+	fileWriterChannel chan<- io.WriteCloser
+	closeChannel      chan<- chan<- struct{}
+	loggingHandler    http.Handler
+	nextHandler       http.Handler
+}
+
 func NewRequestLogger(
+	requestLoggerConfig config.RequestLoggingConfiguration,
 	nextHandler http.Handler,
-) http.Handler {
+) *RequestLogger {
 
-	requestLoggerConfig := config.Instance().RequestLoggingConfiguration
-
-	if !requestLoggerConfig.Enabled {
-		return nextHandler
-	}
+	channel := make(chan []byte, writeChannelCapacity)
 
-	fileWriter := &lumberjack.Logger{
-		Filename:   requestLoggerConfig.RequestLogFile,
-		MaxSize:    requestLoggerConfig.MaxSizeMegabytes,
-		MaxBackups: requestLoggerConfig.MaxBackups,
-	}
+	fileWriterChannel := make(chan io.WriteCloser)
 
-	channel := make(chan []byte, writeChannelCapacity)
+	closeChannel := make(chan chan<- struct{})
 
 	channelWriter := &channelWriter{
 		writeChannel: channel,
@@ -42,21 +49,120 @@ func NewRequestLogger(
 
 	go runAsyncWriter(
 		channel,
-		fileWriter,
+		fileWriterChannel,
+		closeChannel,
 	)
 
 	go channelWriter.runLogDropMonitor()
 
-	return newLoggingHandler(channelWriter, nextHandler)
+	requestLogger := &RequestLogger{
+		fileWriterChannel: fileWriterChannel,
+		closeChannel:      closeChannel,
+		loggingHandler:    newLoggingHandler(channelWriter, nextHandler),
+		nextHandler:       nextHandler,
+	}
+
+	requestLogger.UpdateConfiguration(requestLoggerConfig)
+
+	return requestLogger
 }
 
+// UpdateConfiguration reopens the request log file if requestLoggerConfig changed.
+func (requestLogger *RequestLogger) UpdateConfiguration(
+	requestLoggerConfig config.RequestLoggingConfiguration,
+) {
+	requestLogger.mutex.Lock()
+	defer requestLogger.mutex.Unlock()
+
+	if requestLogger.closed || requestLoggerConfig == requestLogger.configuration {
+		return
+	}
+
+	slog.Info("RequestLogger.UpdateConfiguration",
+		"requestLoggerConfig", requestLoggerConfig,
+	)
+
+	var fileWriter io.WriteCloser
+	if requestLoggerConfig.Enabled {
+		fileWriter = &lumberjack.Logger{
+			Filename:   requestLoggerConfig.RequestLogFile,
+			MaxSize:    requestLoggerConfig.MaxSizeMegabytes,
+			MaxBackups: requestLoggerConfig.MaxBackups,
+		}
+	}
+
+	requestLogger.fileWriterChannel <- fileWriter
+	requestLogger.enabled.Store(requestLoggerConfig.Enabled)
+	requestLogger.configuration = requestLoggerConfig
+}
+
+func (requestLogger *RequestLogger) ServeHTTP(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	if requestLogger.enabled.Load() {
+		requestLogger.loggingHandler.ServeHTTP(w, r)
+	} else {
+		requestLogger.nextHandler.ServeHTTP(w, r)
+	}
+}
+
+// Close writes the requests logged so far and closes the request log file.
+// Requests logged after Close are dropped.
+func (requestLogger *RequestLogger) Close() {
+	requestLogger.mutex.Lock()
+	defer requestLogger.mutex.Unlock()
+
+	if requestLogger.closed {
+		return
+	}
+
+	slog.Info("RequestLogger.Close")
+
+	requestLogger.closed = true
+
+	closedChannel := make(chan struct{})
+	requestLogger.closeChannel <- closedChannel
+	<-closedChannel
+}
+
+// runAsyncWriter writes buffers to the latest writer from fileWriterChannel, closing the one it replaces.
+// Buffers are dropped while the writer is nil.
+// On a message from closeChannel the buffers already queued are written, the writer is closed and
+// runAsyncWriter returns after closing the received channel.
 func runAsyncWriter(
 	channel <-chan []byte,
-	writer io.Writer,
+	fileWriterChannel <-chan io.WriteCloser,
+	closeChannel <-chan chan<- struct{},
 ) {
+	var writer io.WriteCloser
+
 	for {
-		buffer := <-channel
-		writer.Write(buffer)
+		select {
+		case buffer := <-channel:
+			if writer != nil {
+				writer.Write(buffer)
+			}
+
+		case fileWriter := <-fileWriterChannel:
+			if writer != nil {
+				writer.Close()
+			}
+			writer = fileWriter
+
+		case closedChannel := <-closeChannel:
+			for range len(channel) {
+				buffer := <-channel
+				if writer != nil {
+					writer.Write(buffer)
+				}
+			}
+			if writer != nil {
+				writer.Close()
+			}
+			close(closedChannel)
+			return
+		}
 	}
 }
 
@@ -128,6 +234,7 @@ func newLoggingHandler(
 				Protocol:      r.Proto,
 				RemoteAddress: r.RemoteAddr,
 				URL:           r.URL.String(),
+				TLS:           request.TLSInfoFromRequest(r),
 			},
 			ResponseLogData: responseLogData{
 				Headers:      w.Header(),
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/aaronriekenberg/go-api/request"
+	"github.com/aaronriekenberg/go-api/utils"
+)
+
+type upgradeDTO struct {
+	Upgraded bool   `json:"upgraded"`
+	PID      int    `json:"pid,omitzero"`
+	Error    string `json:"error,omitempty"`
+}
+
+// newUpgradeHandler upgrades to a new process like SIGUSR2.  This process drains after responding.
+func (handlers *Handlers) newUpgradeHandler(
+	requestIsExternal request.IsExternal,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requestIsExternal(r) {
+			utils.HTTPErrorStatusCode(w, http.StatusNotFound)
+			return
+		}
+
+		pid, err := handlers.upgrade()
+		if err != nil {
+			slog.Warn("upgrade handler error",
+				"error", err,
+			)
+
+			response := upgradeDTO{
+				Error: err.Error(),
+			}
+			utils.RespondWithJSONDTOAndStatusCode(&response, http.StatusInternalServerError, w)
+			return
+		}
+
+		response := upgradeDTO{
+			Upgraded: true,
+			PID:      pid,
+		}
+		utils.RespondWithJSONDTO(&response, w)
+	})
+}
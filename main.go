@@ -1,20 +1,29 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
+	"os/signal"
 	"runtime"
 	"runtime/debug"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/aaronriekenberg/go-api/config"
 	"github.com/aaronriekenberg/go-api/handlers"
+	"github.com/aaronriekenberg/go-api/handlers/command"
 	"github.com/aaronriekenberg/go-api/profiling"
 	"github.com/aaronriekenberg/go-api/server"
 	"github.com/aaronriekenberg/go-api/version"
 )
 
 func main() {
+	// a start to exec a command with its nice level and rlimits does not return
+	command.RunSandboxExec()
+
 	defer func() {
 		if err := recover(); err != nil {
 			slog.Error("panic in main",
@@ -25,9 +34,19 @@ func main() {
 		}
 	}()
 
-	setupSlog()
+	os.Exit(run(os.Args[1:]))
+}
+
+// serve runs the server until it fails, or until SIGTERM, SIGINT or an upgrade to a new process
+// shut it down gracefully.
+func serve(
+	configFile string,
+	logLevel slog.Level,
+	logFormat logFormat,
+) error {
+	setupSlog(logLevel, logFormat)
 
-	slog.Info("begin main",
+	slog.Info("begin serve",
 		"os.Args", os.Args,
 		"buildInfoMap", version.BuildInfoMap(),
 		"goEnvironVariables", goEnvironVariables(),
@@ -35,37 +54,191 @@ func main() {
 		"NumCPU", runtime.NumCPU(),
 	)
 
-	profiling.Start()
+	err := config.Load(configFile)
+	if err != nil {
+		return fmt.Errorf("config.Load error: %w", err)
+	}
+
+	err = server.InheritListeners()
+	if err != nil {
+		return fmt.Errorf("server.InheritListeners error: %w", err)
+	}
+
+	err = profiling.Start(server.Listen)
+	if err != nil {
+		return fmt.Errorf("profiling.Start error: %w", err)
+	}
+
+	upgrader := newUpgrader()
+
+	handlers, err := handlers.CreateHandlers(upgrader.upgrade)
+	if err != nil {
+		return fmt.Errorf("handlers.CreateHandlers error: %w", err)
+	}
+
+	server, err := server.New(handlers)
+	if err != nil {
+		return fmt.Errorf("server.New error: %w", err)
+	}
+
+	go reloadConfigurationOnSIGHUP(handlers)
+
+	go upgradeOnSIGUSR2(upgrader)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	serveErrorChannel := make(chan error, 1)
+	go func() {
+		serveErrorChannel <- server.Serve()
+	}()
+
+	// after an upgrade the new process serves /health, there is nothing to drain
+	var drainDelay time.Duration
+
+	select {
+	case err := <-serveErrorChannel:
+		slog.Error("server.Serve error",
+			"error", err,
+		)
+		return fmt.Errorf("server.Serve error: %w", err)
+
+	case <-ctx.Done():
+		drainDelay = config.Instance().ServerConfiguration.DrainDelayDuration
+
+	case <-upgrader.upgraded:
+		profiling.Stop()
+		server.StopAccepting()
+	}
+
+	// a second signal terminates immediately
+	stop()
+
+	shutdown(server, handlers, drainDelay)
+
+	return nil
+}
+
+// shutdown reports draining on /health for drainDelay, stops the pprof server and accepting connections,
+// waits for active requests and then for running commands, each for up to its configured
+// timeout, and then flushes the request log.
+func shutdown(
+	server *server.Server,
+	handlers *handlers.Handlers,
+	drainDelay time.Duration,
+) {
+	serverConfiguration := config.Instance().ServerConfiguration
+	shutdownTimeout := serverConfiguration.ShutdownTimeout()
+	commandShutdownTimeout := serverConfiguration.CommandShutdownTimeout()
+
+	slog.Info("begin shutdown",
+		"drainDelay", drainDelay,
+		"shutdownTimeout", shutdownTimeout,
+		"commandShutdownTimeout", commandShutdownTimeout,
+	)
+
+	handlers.StartDraining()
+
+	profiling.Stop()
+
+	time.Sleep(drainDelay)
 
-	handlers := handlers.CreateHandlers()
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 
-	err := server.Run(handlers)
-	panic(fmt.Errorf("main: server.Run error: %w", err))
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Warn("server.Shutdown error",
+			"error", err,
+		)
+	}
+
+	commandsCtx, commandsCancel := context.WithTimeout(context.Background(), commandShutdownTimeout)
+	defer commandsCancel()
+
+	if err := handlers.Shutdown(commandsCtx); err != nil {
+		slog.Warn("handlers.Shutdown error",
+			"error", err,
+		)
+	}
+
+	slog.Info("end shutdown")
 }
 
-func setupSlog() {
-	level := slog.LevelInfo
+func reloadConfigurationOnSIGHUP(handlers *handlers.Handlers) {
+	signalChannel := make(chan os.Signal, 1)
+	signal.Notify(signalChannel, syscall.SIGHUP)
 
-	if levelString, ok := os.LookupEnv("LOG_LEVEL"); ok {
-		err := level.UnmarshalText([]byte(levelString))
+	for range signalChannel {
+		slog.Info("received SIGHUP")
+
+		_, err := handlers.ReloadConfiguration()
 		if err != nil {
-			panic(fmt.Errorf("level.UnmarshalText error %w", err))
+			slog.Warn("ReloadConfiguration error, keeping current configuration",
+				"error", err,
+			)
 		}
 	}
+}
 
-	slog.SetDefault(
-		slog.New(
-			slog.NewJSONHandler(
-				os.Stdout,
-				&slog.HandlerOptions{
-					Level: level,
-				},
-			),
-		),
-	)
+// upgrader runs server.Upgrade, upgraded is closed after it succeeds.
+type upgrader struct {
+	upgraded chan struct{}
+}
+
+func newUpgrader() *upgrader {
+	return &upgrader{
+		upgraded: make(chan struct{}),
+	}
+}
+
+func (upgrader *upgrader) upgrade() (pid int, err error) {
+	pid, err = server.Upgrade()
+	if err != nil {
+		return 0, err
+	}
+
+	close(upgrader.upgraded)
+
+	return pid, nil
+}
+
+func upgradeOnSIGUSR2(upgrader *upgrader) {
+	signalChannel := make(chan os.Signal, 1)
+	signal.Notify(signalChannel, syscall.SIGUSR2)
+
+	for range signalChannel {
+		slog.Info("received SIGUSR2")
+
+		_, err := upgrader.upgrade()
+		if err != nil {
+			slog.Warn("upgrade error, continuing to serve",
+				"error", err,
+			)
+		}
+	}
+}
+
+func setupSlog(
+	level slog.Level,
+	logFormat logFormat,
+) {
+	handlerOptions := &slog.HandlerOptions{
+		Level: level,
+	}
+
+	var handler slog.Handler
+	switch logFormat {
+	case logFormatText:
+		handler = slog.NewTextHandler(os.Stdout, handlerOptions)
+	default:
+		handler = slog.NewJSONHandler(os.Stdout, handlerOptions)
+	}
+
+	slog.SetDefault(slog.New(handler))
 
 	slog.Info("setupSlog",
 		"configuredLevel", level,
+		"logFormat", logFormat,
 	)
 }
 
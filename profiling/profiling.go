@@ -1,27 +1,86 @@
 package profiling
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/http/pprof"
 	"os"
 	"runtime/debug"
+	"sync"
 
 	"github.com/aaronriekenberg/go-api/config"
 )
 
-func Start() {
+var (
+	pprofServerMutex sync.Mutex
+	pprofServer      *http.Server
+)
+
+// Start starts the pprof server if it is enabled, on a listener from listen.
+// It fails if the listen address can not be bound, later errors are logged.
+func Start(
+	listen func(network string, address string) (net.Listener, error),
+) error {
 	config := config.Instance().ProfilingConfiguration
 
 	if !config.Enabled {
+		return nil
+	}
+
+	listener, err := listen("tcp", config.ListenAddress)
+	if err != nil {
+		return fmt.Errorf("profiling: listen error: %w", err)
+	}
+
+	pprofServerMutex.Lock()
+	defer pprofServerMutex.Unlock()
+
+	pprofServer = newPprofServer()
+
+	go runPprofServer(config, pprofServer, listener)
+
+	return nil
+}
+
+// Stop closes the pprof server if it was started.  After an upgrade the new process
+// serves on the same socket, so the old one stops accepting on it.
+func Stop() {
+	pprofServerMutex.Lock()
+	defer pprofServerMutex.Unlock()
+
+	if pprofServer == nil {
 		return
 	}
 
-	go runPprofServer(config)
+	if err := pprofServer.Close(); err != nil {
+		slog.Warn("profiling: pprofServer.Close error",
+			"error", err,
+		)
+	}
+}
+
+func newPprofServer() *http.Server {
+	serveMux := http.NewServeMux()
+
+	serveMux.Handle("/debug/pprof/", http.HandlerFunc(pprof.Index))
+	serveMux.Handle("/debug/pprof/cmdline", http.HandlerFunc(pprof.Cmdline))
+	serveMux.Handle("/debug/pprof/profile", http.HandlerFunc(pprof.Profile))
+	serveMux.Handle("/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol))
+	serveMux.Handle("/debug/pprof/trace", http.HandlerFunc(pprof.Trace))
+
+	return &http.Server{
+		Handler: serveMux,
+	}
 }
 
-func runPprofServer(config config.ProfilingConfiguration) {
+func runPprofServer(
+	config config.ProfilingConfiguration,
+	pprofServer *http.Server,
+	listener net.Listener,
+) {
 	defer func() {
 		if err := recover(); err != nil {
 			slog.Error("panic in runPprofServer",
@@ -36,14 +95,13 @@ func runPprofServer(config config.ProfilingConfiguration) {
 		"config", config,
 	)
 
-	serveMux := http.NewServeMux()
-
-	serveMux.Handle("/debug/pprof/", http.HandlerFunc(pprof.Index))
-	serveMux.Handle("/debug/pprof/cmdline", http.HandlerFunc(pprof.Cmdline))
-	serveMux.Handle("/debug/pprof/profile", http.HandlerFunc(pprof.Profile))
-	serveMux.Handle("/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol))
-	serveMux.Handle("/debug/pprof/trace", http.HandlerFunc(pprof.Trace))
+	err := pprofServer.Serve(listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		slog.Info("end runPprofServer")
+		return
+	}
 
-	err := http.ListenAndServe(config.ListenAddress, serveMux)
-	panic(fmt.Errorf("runPprofServer: http.ListenAndServe returned error %w", err))
+	slog.Error("runPprofServer: http.Serve error, profiling stopped",
+		"error", err,
+	)
 }
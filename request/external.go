@@ -4,7 +4,6 @@ import (
 	"log/slog"
 	"net/http"
 	"strings"
-	"sync"
 
 	"github.com/aaronriekenberg/go-api/config"
 )
@@ -31,12 +30,15 @@ func newExternalCheck(
 	}
 }
 
-var ExternalCheckInstance = sync.OnceValue(func() IsExternal {
-	externalHost := config.Instance().RequestConfiguration.ExternalHost
+// NewExternalCheck returns the check for requestConfiguration.ExternalHost.
+func NewExternalCheck(
+	requestConfiguration config.RequestConfiguration,
+) IsExternal {
+	externalHost := requestConfiguration.ExternalHost
 
 	slog.Info("calling newExternalCheck",
 		"externalHost", externalHost,
 	)
 
 	return newExternalCheck(externalHost)
-})
+}
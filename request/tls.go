@@ -0,0 +1,30 @@
+package request
+
+import (
+	"crypto/tls"
+	"net/http"
+)
+
+// TLSInfo is what was negotiated for a request's TLS connection.
+type TLSInfo struct {
+	Version            string `json:"version"`
+	CipherSuite        string `json:"cipher_suite"`
+	ServerName         string `json:"server_name"`
+	NegotiatedProtocol string `json:"negotiated_protocol"`
+	DidResume          bool   `json:"did_resume"`
+}
+
+// TLSInfoFromRequest returns nil if r was not received over TLS.
+func TLSInfoFromRequest(r *http.Request) *TLSInfo {
+	if r.TLS == nil {
+		return nil
+	}
+
+	return &TLSInfo{
+		Version:            tls.VersionName(r.TLS.Version),
+		CipherSuite:        tls.CipherSuiteName(r.TLS.CipherSuite),
+		ServerName:         r.TLS.ServerName,
+		NegotiatedProtocol: r.TLS.NegotiatedProtocol,
+		DidResume:          r.TLS.DidResume,
+	}
+}
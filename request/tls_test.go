@@ -0,0 +1,45 @@
+package request
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestTLSInfoFromRequest(t *testing.T) {
+	tests := map[string]struct {
+		connectionState *tls.ConnectionState
+		want            *TLSInfo
+	}{
+		"plaintext": {
+			connectionState: nil,
+			want:            nil,
+		},
+		"tls 1.3": {
+			connectionState: &tls.ConnectionState{
+				Version:            tls.VersionTLS13,
+				CipherSuite:        tls.TLS_AES_128_GCM_SHA256,
+				ServerName:         "example.com",
+				NegotiatedProtocol: "h2",
+			},
+			want: &TLSInfo{
+				Version:            "TLS 1.3",
+				CipherSuite:        "TLS_AES_128_GCM_SHA256",
+				ServerName:         "example.com",
+				NegotiatedProtocol: "h2",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.TLS = test.connectionState
+
+			if got := TLSInfoFromRequest(r); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("TLSInfoFromRequest = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
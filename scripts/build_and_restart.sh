@@ -5,13 +5,18 @@ set -x
 
 cd ~/go-api
 
-systemctl --user stop go-api.service
-
 git pull -v
 
 time go test -test.v ./...
 time go build -x
 
+# not needed with socket activation, see systemd/go-api.socket
 #sudo setcap cap_net_bind_service=+ep ./go-api
 
-systemctl --user restart go-api.service
+# the running process starts the new binary on its listeners and drains,
+# so no connections are refused
+if systemctl --user is-active --quiet go-api.service; then
+    systemctl --user kill --kill-whom=main --signal=USR2 go-api.service
+else
+    systemctl --user restart go-api.service
+fi
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aaronriekenberg/go-api/config"
+)
+
+// certificateCheckInterval is how often the certificate files are checked for changes.
+const certificateCheckInterval = 10 * time.Second
+
+// certificateLoader serves the certificate in certFile and keyFile.
+// The files are checked for changes during handshakes at most every certificateCheckInterval,
+// so a renewed certificate is used without a restart.
+type certificateLoader struct {
+	certFile string
+	keyFile  string
+
+	mutex       sync.Mutex
+	certificate *tls.Certificate
+	lastCheck   time.Time
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+func newCertificateLoader(
+	certFile string,
+	keyFile string,
+) (*certificateLoader, error) {
+	certificateLoader := &certificateLoader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	// not shared yet, no need to lock
+	if err := certificateLoader.loadLocked(time.Now()); err != nil {
+		return nil, err
+	}
+
+	return certificateLoader, nil
+}
+
+func (certificateLoader *certificateLoader) modTimes() (time.Time, time.Time, error) {
+	certFileInfo, err := os.Stat(certificateLoader.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	keyFileInfo, err := os.Stat(certificateLoader.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	return certFileInfo.ModTime(), keyFileInfo.ModTime(), nil
+}
+
+// loadLocked loads the certificate if the files changed since it was last loaded.
+func (certificateLoader *certificateLoader) loadLocked(now time.Time) error {
+	certificateLoader.lastCheck = now
+
+	certModTime, keyModTime, err := certificateLoader.modTimes()
+	if err != nil {
+		return fmt.Errorf("certificate file os.Stat error: %w", err)
+	}
+
+	if certificateLoader.certificate != nil &&
+		certModTime.Equal(certificateLoader.certModTime) &&
+		keyModTime.Equal(certificateLoader.keyModTime) {
+		return nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(certificateLoader.certFile, certificateLoader.keyFile)
+	if err != nil {
+		return fmt.Errorf("tls.LoadX509KeyPair error: %w", err)
+	}
+
+	certificateLoader.certificate = &certificate
+	certificateLoader.certModTime = certModTime
+	certificateLoader.keyModTime = keyModTime
+
+	slog.Info("loaded certificate",
+		"certFile", certificateLoader.certFile,
+		"subject", certificate.Leaf.Subject.String(),
+		"dnsNames", certificate.Leaf.DNSNames,
+		"notAfter", certificate.Leaf.NotAfter,
+	)
+
+	return nil
+}
+
+// getCertificate is the tls.Config GetCertificate function.
+// If reloading fails, for example while the files are being replaced, the previous certificate is served.
+func (certificateLoader *certificateLoader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certificateLoader.mutex.Lock()
+	defer certificateLoader.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(certificateLoader.lastCheck) >= certificateCheckInterval {
+		if err := certificateLoader.loadLocked(now); err != nil {
+			slog.Warn("certificate reload error, serving previous certificate",
+				"certFile", certificateLoader.certFile,
+				"error", err,
+			)
+		}
+	}
+
+	return certificateLoader.certificate, nil
+}
+
+func createTLSConfig(
+	listenerConfig config.ServerListenerConfiguration,
+) (*tls.Config, error) {
+	tlsConfiguration := listenerConfig.TLS
+
+	if err := tlsConfiguration.Check(); err != nil {
+		return nil, err
+	}
+
+	minVersion, _ := tlsConfiguration.TLSMinVersion()
+	cipherSuites, _ := tlsConfiguration.TLSCipherSuites()
+
+	certificateLoader, err := newCertificateLoader(tlsConfiguration.CertFile, tlsConfiguration.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   cipherSuites,
+		NextProtos:     tlsConfiguration.NextProtos(),
+		GetCertificate: certificateLoader.getCertificate,
+	}, nil
+}
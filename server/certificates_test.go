@@ -0,0 +1,180 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aaronriekenberg/go-api/config"
+)
+
+func writeCertificate(
+	t *testing.T,
+	certFile string,
+	keyFile string,
+	commonName string,
+	modTime time.Time,
+) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey error: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate error: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("x509.MarshalECPrivateKey error: %v", err)
+	}
+
+	for file, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: certDER},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		if err := os.WriteFile(file, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatalf("os.WriteFile error: %v", err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatalf("os.Chtimes error: %v", err)
+		}
+	}
+}
+
+func servedCommonName(
+	t *testing.T,
+	certificateLoader *certificateLoader,
+) string {
+	t.Helper()
+
+	certificate, err := certificateLoader.getCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatalf("getCertificate error: %v", err)
+	}
+	return certificate.Leaf.Subject.CommonName
+}
+
+func TestCertificateLoaderReload(t *testing.T) {
+	directory := t.TempDir()
+	certFile := filepath.Join(directory, "cert.pem")
+	keyFile := filepath.Join(directory, "key.pem")
+
+	modTime := time.Now().Add(-time.Hour)
+	writeCertificate(t, certFile, keyFile, "first.example.com", modTime)
+
+	certificateLoader, err := newCertificateLoader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("newCertificateLoader error: %v", err)
+	}
+
+	if got := servedCommonName(t, certificateLoader); got != "first.example.com" {
+		t.Fatalf("served %q, want first.example.com", got)
+	}
+
+	writeCertificate(t, certFile, keyFile, "second.example.com", modTime.Add(time.Minute))
+
+	// the files are not checked again within certificateCheckInterval
+	if got := servedCommonName(t, certificateLoader); got != "first.example.com" {
+		t.Fatalf("served %q before the check interval, want first.example.com", got)
+	}
+
+	certificateLoader.lastCheck = time.Time{}
+	if got := servedCommonName(t, certificateLoader); got != "second.example.com" {
+		t.Fatalf("served %q after reload, want second.example.com", got)
+	}
+
+	// a broken certificate is not loaded, the previous one is served
+	if err := os.WriteFile(certFile, []byte("broken"), 0o600); err != nil {
+		t.Fatalf("os.WriteFile error: %v", err)
+	}
+	certificateLoader.lastCheck = time.Time{}
+	if got := servedCommonName(t, certificateLoader); got != "second.example.com" {
+		t.Fatalf("served %q after broken reload, want second.example.com", got)
+	}
+}
+
+func TestCreateTLSConfig(t *testing.T) {
+	directory := t.TempDir()
+	certFile := filepath.Join(directory, "cert.pem")
+	keyFile := filepath.Join(directory, "key.pem")
+	writeCertificate(t, certFile, keyFile, "example.com", time.Now())
+
+	tests := map[string]struct {
+		tlsConfiguration config.TLSConfiguration
+		wantErr          bool
+		wantMinVersion   uint16
+		wantNextProtos   []string
+	}{
+		"defaults": {
+			tlsConfiguration: config.TLSConfiguration{CertFile: certFile, KeyFile: keyFile},
+			wantMinVersion:   tls.VersionTLS12,
+			wantNextProtos:   []string{"h2", "http/1.1"},
+		},
+		"tls 1.3 http/1.1 only": {
+			tlsConfiguration: config.TLSConfiguration{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.3", ALPNProtocols: []string{"http/1.1"}},
+			wantMinVersion:   tls.VersionTLS13,
+			wantNextProtos:   []string{"http/1.1"},
+		},
+		"bad min version": {
+			tlsConfiguration: config.TLSConfiguration{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.0"},
+			wantErr:          true,
+		},
+		"insecure cipher suite": {
+			tlsConfiguration: config.TLSConfiguration{CertFile: certFile, KeyFile: keyFile, CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+			wantErr:          true,
+		},
+		"no http alpn protocol": {
+			tlsConfiguration: config.TLSConfiguration{CertFile: certFile, KeyFile: keyFile, ALPNProtocols: []string{"acme-tls/1"}},
+			wantErr:          true,
+		},
+		"missing key file": {
+			tlsConfiguration: config.TLSConfiguration{CertFile: certFile, KeyFile: filepath.Join(directory, "missing.pem")},
+			wantErr:          true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tlsConfig, err := createTLSConfig(config.ServerListenerConfiguration{TLS: &test.tlsConfiguration})
+
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("createTLSConfig error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("createTLSConfig error: %v", err)
+			}
+
+			if tlsConfig.MinVersion != test.wantMinVersion {
+				t.Errorf("MinVersion = %x, want %x", tlsConfig.MinVersion, test.wantMinVersion)
+			}
+			if !slices.Equal(tlsConfig.NextProtos, test.wantNextProtos) {
+				t.Errorf("NextProtos = %v, want %v", tlsConfig.NextProtos, test.wantNextProtos)
+			}
+		})
+	}
+}
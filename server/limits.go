@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/aaronriekenberg/go-api/config"
+)
+
+func newHTTP2Config(
+	http2Configuration config.HTTP2Configuration,
+) *http.HTTP2Config {
+	return &http.HTTP2Config{
+		MaxConcurrentStreams:          http2Configuration.MaxConcurrentStreams,
+		MaxDecoderHeaderTableSize:     http2Configuration.MaxDecoderHeaderTableSize,
+		MaxEncoderHeaderTableSize:     http2Configuration.MaxEncoderHeaderTableSize,
+		MaxReadFrameSize:              http2Configuration.MaxReadFrameSize,
+		MaxReceiveBufferPerConnection: http2Configuration.MaxReceiveBufferPerConnection,
+		MaxReceiveBufferPerStream:     http2Configuration.MaxReceiveBufferPerStream,
+		SendPingTimeout:               http2Configuration.SendPingTimeoutDuration,
+		PingTimeout:                   http2Configuration.PingTimeoutDuration,
+		WriteByteTimeout:              http2Configuration.WriteByteTimeoutDuration,
+	}
+}
+
+// newHTTPServer returns an http.Server with the limits of listenerConfig.
+func newHTTPServer(
+	listenerConfig config.ServerListenerConfiguration,
+) *http.Server {
+	limits := listenerConfig.Limits.WithDefaults()
+
+	return &http.Server{
+		ReadTimeout:       limits.ReadTimeoutDuration,
+		ReadHeaderTimeout: limits.ReadHeaderTimeoutDuration,
+		WriteTimeout:      limits.WriteTimeoutDuration,
+		IdleTimeout:       limits.IdleTimeoutDuration,
+		MaxHeaderBytes:    limits.MaxHeaderBytes,
+		HTTP2:             newHTTP2Config(limits.HTTP2),
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aaronriekenberg/go-api/config"
+)
+
+func TestNewHTTPServer(t *testing.T) {
+	httpServer := newHTTPServer(config.ServerListenerConfiguration{
+		Network:       "unix",
+		ListenAddress: "socket",
+		Limits: config.ServerLimitsConfiguration{
+			WriteTimeoutDuration: 10 * time.Minute,
+			MaxHeaderBytes:       16 << 10,
+			HTTP2: config.HTTP2Configuration{
+				MaxConcurrentStreams: 50,
+				MaxReadFrameSize:     1 << 20,
+				PingTimeoutDuration:  5 * time.Second,
+			},
+		},
+	})
+
+	if httpServer.ReadTimeout != config.DefaultReadTimeout {
+		t.Errorf("ReadTimeout = %v, want %v", httpServer.ReadTimeout, config.DefaultReadTimeout)
+	}
+	if httpServer.ReadHeaderTimeout != config.DefaultReadHeaderTimeout {
+		t.Errorf("ReadHeaderTimeout = %v, want %v", httpServer.ReadHeaderTimeout, config.DefaultReadHeaderTimeout)
+	}
+	if httpServer.WriteTimeout != 10*time.Minute {
+		t.Errorf("WriteTimeout = %v, want 10m", httpServer.WriteTimeout)
+	}
+	if httpServer.MaxHeaderBytes != 16<<10 {
+		t.Errorf("MaxHeaderBytes = %d, want %d", httpServer.MaxHeaderBytes, 16<<10)
+	}
+
+	http2Config := httpServer.HTTP2
+	if http2Config.MaxConcurrentStreams != 50 {
+		t.Errorf("HTTP2.MaxConcurrentStreams = %d, want 50", http2Config.MaxConcurrentStreams)
+	}
+	if http2Config.MaxReadFrameSize != 1<<20 {
+		t.Errorf("HTTP2.MaxReadFrameSize = %d, want %d", http2Config.MaxReadFrameSize, 1<<20)
+	}
+	if http2Config.PingTimeout != 5*time.Second {
+		t.Errorf("HTTP2.PingTimeout = %v, want 5s", http2Config.PingTimeout)
+	}
+	if http2Config.SendPingTimeout != 0 {
+		t.Errorf("HTTP2.SendPingTimeout = %v, want 0", http2Config.SendPingTimeout)
+	}
+}
@@ -1,22 +1,46 @@
 package server
 
 import (
-	"fmt"
+	"errors"
 	"log/slog"
 	"net"
 	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/aaronriekenberg/go-api/config"
 )
 
 type listenerWrapper struct {
 	net.Listener
+	stoppedAccepting atomic.Bool
+	closeOnce        sync.Once
+	closed           chan struct{}
+}
+
+// stopAccepting makes Accept wait for Close, leaving new connections to other processes sharing the socket.
+func (lw *listenerWrapper) stopAccepting() error {
+	deadlineListener, ok := lw.Listener.(interface{ SetDeadline(time.Time) error })
+	if !ok {
+		return errors.New("listener does not support SetDeadline")
+	}
+
+	lw.stoppedAccepting.Store(true)
+
+	return deadlineListener.SetDeadline(time.Now())
 }
 
 func (lw *listenerWrapper) Accept() (net.Conn, error) {
 	conn, err := lw.Listener.Accept()
 
 	if err != nil {
+		if lw.stoppedAccepting.Load() && errors.Is(err, os.ErrDeadlineExceeded) {
+			// http.Server retries timeouts, so wait until it closes the listener on shutdown
+			<-lw.closed
+			return nil, net.ErrClosed
+		}
+
 		slog.Warn("listenerWrapper.Accept error",
 			"error", err,
 		)
@@ -38,20 +62,25 @@ func (lw *listenerWrapper) Accept() (net.Conn, error) {
 	}
 }
 
-func createListener(
-	config config.ServerListenerConfiguration,
-) (net.Listener, error) {
-	if config.Network == "unix" {
-		os.Remove(config.ListenAddress)
-	}
+func (lw *listenerWrapper) Close() error {
+	lw.closeOnce.Do(func() {
+		close(lw.closed)
+	})
 
-	listener, err := net.Listen(config.Network, config.ListenAddress)
+	return lw.Listener.Close()
+}
+
+func createListener(
+	listenerConfig config.ServerListenerConfiguration,
+) (*listenerWrapper, error) {
+	listener, err := Listen(listenerConfig.Network, listenerConfig.ListenAddress)
 	if err != nil {
-		return nil, fmt.Errorf("net.Listen error: %w", err)
+		return nil, err
 	}
 
 	listenerWrapper := &listenerWrapper{
 		Listener: listener,
+		closed:   make(chan struct{}),
 	}
 
 	return listenerWrapper, nil
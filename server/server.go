@@ -2,10 +2,14 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/aaronriekenberg/go-api/config"
@@ -17,6 +21,10 @@ func addConnectionInfoToContext(
 	ctx context.Context,
 	c net.Conn,
 ) context.Context {
+	if tlsConn, ok := c.(*tls.Conn); ok {
+		c = tlsConn.NetConn()
+	}
+
 	if connWrapper, ok := c.(connectionInfoWrapper); ok {
 		connectionInfo := connWrapper.connectionInfo()
 		return connection.AddConnectionInfoToContext(ctx, connectionInfo)
@@ -45,82 +53,198 @@ func updateContextForRequestHandler(
 	}
 }
 
-func runListener(
+// acceptedConnectionsDelay is the time StopAccepting gives connections already accepted to send a request.
+const acceptedConnectionsDelay = 1 * time.Second
+
+// listenerServer is the http.Server for one configured listener.
+type listenerServer struct {
+	listenerConfig config.ServerListenerConfiguration
+	listener       *listenerWrapper
+	httpServer     *http.Server
+}
+
+func newListenerServer(
 	listenerConfig config.ServerListenerConfiguration,
 	handler http.Handler,
-	errorChannel chan<- error,
-) {
+) (*listenerServer, error) {
 	logger := slog.Default().With(
 		"listenerConfig", listenerConfig,
 	)
 
-	logger.Info("begin server.runListener")
+	logger.Info("begin server.newListenerServer")
+
+	var tlsConfig *tls.Config
+	if listenerConfig.TLS != nil {
+		var err error
+		tlsConfig, err = createTLSConfig(listenerConfig)
+		if err != nil {
+			return nil, fmt.Errorf("server.createTLSConfig error: %w", err)
+		}
+	}
 
 	listener, err := createListener(
 		listenerConfig,
 	)
 	if err != nil {
-		logger.Warn("server.createListener error",
-			"error", err,
-		)
-		errorChannel <- fmt.Errorf("server.createListener error: %w", err)
-		return
+		return nil, fmt.Errorf("server.createListener error: %w", err)
 	}
 
 	handler = updateContextForRequestHandler(handler)
 
 	protocols := new(http.Protocols)
-	protocols.SetHTTP1(true)
 
-	if listenerConfig.H2CEnabled {
-		logger.Info("server.runListener enabling h2c")
+	switch {
+	case tlsConfig != nil:
+		protocols.SetHTTP1(slices.Contains(tlsConfig.NextProtos, config.ALPNProtocolHTTP1))
+		protocols.SetHTTP2(slices.Contains(tlsConfig.NextProtos, config.ALPNProtocolHTTP2))
 
+	case listenerConfig.H2CEnabled:
+		logger.Info("server.newListenerServer enabling h2c")
+
+		protocols.SetHTTP1(true)
 		protocols.SetUnencryptedHTTP2(true)
+
+	default:
+		protocols.SetHTTP1(true)
 	}
 
 	logger.Info("creating httpServer",
 		"protocols", protocols.String(),
+		"limits", listenerConfig.Limits.WithDefaults(),
 	)
 
-	httpServer := &http.Server{
-		IdleTimeout:  5 * time.Minute,
-		ReadTimeout:  1 * time.Minute,
-		WriteTimeout: 1 * time.Minute,
-		ConnContext:  addConnectionInfoToContext,
-		Handler:      handler,
-		Protocols:    protocols,
+	httpServer := newHTTPServer(listenerConfig)
+	httpServer.ConnContext = addConnectionInfoToContext
+	httpServer.Handler = handler
+	httpServer.Protocols = protocols
+	httpServer.TLSConfig = tlsConfig
+
+	return &listenerServer{
+		listenerConfig: listenerConfig,
+		listener:       listener,
+		httpServer:     httpServer,
+	}, nil
+}
+
+// serve returns nil after Shutdown.
+func (listenerServer *listenerServer) serve() error {
+	var err error
+	if listenerServer.httpServer.TLSConfig != nil {
+		err = listenerServer.httpServer.ServeTLS(listenerServer.listener, "", "")
+	} else {
+		err = listenerServer.httpServer.Serve(listenerServer.listener)
 	}
 
-	err = httpServer.Serve(listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
 
-	logger.Warn("httpServer.serve error",
+	slog.Warn("httpServer.serve error",
+		"listenerConfig", listenerServer.listenerConfig,
 		"error", err,
 	)
-	errorChannel <- fmt.Errorf("httpServer.Serve error: %w", err)
+	return fmt.Errorf("httpServer.Serve error: %w", err)
+}
+
+// Server serves handler on every configured listener.
+type Server struct {
+	listenerServers []*listenerServer
 }
 
-func Run(
+// New creates the configured listeners, so a listener that can not be created fails before serving.
+func New(
 	handler http.Handler,
-) error {
+) (*Server, error) {
 
 	serverConfig := config.Instance().ServerConfiguration
 
-	slog.Info("begin server.Run")
+	slog.Info("begin server.New")
 
 	if len(serverConfig.Listeners) < 1 {
-		return fmt.Errorf("no listeners configured")
+		return nil, fmt.Errorf("no listeners configured")
 	}
 
-	errorChannel := make(chan error, len(serverConfig.Listeners))
+	server := new(Server)
 
 	for _, listenerConfig := range serverConfig.Listeners {
-		go runListener(
-			listenerConfig,
-			handler,
-			errorChannel,
-		)
+		listenerServer, err := newListenerServer(listenerConfig, handler)
+		if err != nil {
+			for _, listenerServer := range server.listenerServers {
+				listenerServer.listener.Close()
+			}
+			return nil, fmt.Errorf("server.newListenerServer error: %w", err)
+		}
+
+		server.listenerServers = append(server.listenerServers, listenerServer)
+	}
+
+	return server, nil
+}
+
+// Serve serves every listener.  It returns nil once all of them are shut down,
+// or the error of the first listener that fails.
+// If this process was started by an upgrade, the upgrading process is told it is serving.
+func (server *Server) Serve() error {
+	errorChannel := make(chan error, len(server.listenerServers))
+
+	for _, listenerServer := range server.listenerServers {
+		go func() {
+			errorChannel <- listenerServer.serve()
+		}()
+	}
+
+	// the listeners are bound, so the upgrading process can start draining
+	if err := notifyUpgradeReady(); err != nil {
+		return fmt.Errorf("notifyUpgradeReady error: %w", err)
+	}
+
+	for range server.listenerServers {
+		if err := <-errorChannel; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// StopAccepting stops every listener accepting connections, leaving them to the process this one
+// upgraded to, then waits for the connections already accepted to send a request.
+// Shutdown drops a connection whose first request arrives after it started.
+func (server *Server) StopAccepting() {
+	slog.Info("begin server.StopAccepting")
+
+	for _, listenerServer := range server.listenerServers {
+		if err := listenerServer.listener.stopAccepting(); err != nil {
+			slog.Warn("listenerWrapper.stopAccepting error",
+				"listenerConfig", listenerServer.listenerConfig,
+				"error", err,
+			)
+		}
+	}
+
+	time.Sleep(acceptedConnectionsDelay)
+
+	slog.Info("end server.StopAccepting")
+}
+
+// Shutdown stops every listener accepting connections and waits for active requests until ctx is done.
+func (server *Server) Shutdown(ctx context.Context) error {
+	slog.Info("begin server.Shutdown")
+
+	var waitGroup sync.WaitGroup
+	errs := make([]error, len(server.listenerServers))
+
+	for i, listenerServer := range server.listenerServers {
+		waitGroup.Go(func() {
+			if err := listenerServer.httpServer.Shutdown(ctx); err != nil {
+				errs[i] = fmt.Errorf("listener %s %s: httpServer.Shutdown error: %w",
+					listenerServer.listenerConfig.Network, listenerServer.listenerConfig.ListenAddress, err)
+			}
+		})
 	}
+	waitGroup.Wait()
+
+	slog.Info("end server.Shutdown")
 
-	err := <-errorChannel
-	return fmt.Errorf("server.runListener error: %w", err)
+	return errors.Join(errs...)
 }
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// systemdListenFDsStart is the first file descriptor passed by systemd socket activation.
+const systemdListenFDsStart = 3
+
+// systemdDefaultFDName is the name of sockets when LISTEN_FDNAMES is not set.
+const systemdDefaultFDName = "unknown"
+
+// parseSystemdSockets returns the file descriptors passed by systemd socket activation by their
+// FileDescriptorName, from the LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES environment variables.
+// There are none if LISTEN_PID is not pid.
+func parseSystemdSockets(
+	getenv func(string) string,
+	pid int,
+) (map[string][]int, error) {
+	nameToFDs := make(map[string][]int)
+
+	listenPID := getenv("LISTEN_PID")
+	if listenPID == "" || listenPID != strconv.Itoa(pid) {
+		return nameToFDs, nil
+	}
+
+	numFDs, err := strconv.Atoi(getenv("LISTEN_FDS"))
+	if err != nil || numFDs < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", getenv("LISTEN_FDS"))
+	}
+
+	var names []string
+	if listenFDNames := getenv("LISTEN_FDNAMES"); listenFDNames != "" {
+		names = strings.Split(listenFDNames, ":")
+		if len(names) != numFDs {
+			return nil, fmt.Errorf("LISTEN_FDNAMES %q does not name %d sockets", listenFDNames, numFDs)
+		}
+	}
+
+	for i := range numFDs {
+		name := systemdDefaultFDName
+		if names != nil {
+			name = names[i]
+		}
+		nameToFDs[name] = append(nameToFDs[name], systemdListenFDsStart+i)
+	}
+
+	return nameToFDs, nil
+}
+
+// systemdSockets are the sockets passed by systemd socket activation.
+// The environment variables are unset and the file descriptors are marked close on exec,
+// so commands do not inherit them.
+var systemdSockets = sync.OnceValues(func() (map[string][]int, error) {
+	nameToFDs, err := parseSystemdSockets(os.Getenv, os.Getpid())
+
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	if err != nil {
+		return nil, err
+	}
+
+	for name, fds := range nameToFDs {
+		for _, fd := range fds {
+			syscall.CloseOnExec(fd)
+		}
+
+		slog.Info("systemd socket activation",
+			"name", name,
+			"fds", fds,
+		)
+	}
+
+	return nameToFDs, nil
+})
+
+// systemdListener returns a listener on the socket systemd passed with FileDescriptorName name.
+func systemdListener(name string) (net.Listener, error) {
+	nameToFDs, err := systemdSockets()
+	if err != nil {
+		return nil, err
+	}
+
+	fds := nameToFDs[name]
+	if len(fds) == 0 {
+		return nil, fmt.Errorf("no systemd socket named %q", name)
+	}
+	if len(fds) > 1 {
+		return nil, fmt.Errorf("%d systemd sockets named %q, give each a distinct FileDescriptorName", len(fds), name)
+	}
+
+	file := os.NewFile(uintptr(fds[0]), name)
+
+	// net.FileListener uses a duplicate of the file descriptor
+	listener, err := net.FileListener(file)
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("net.FileListener error: %w", err)
+	}
+
+	return listener, nil
+}
+
+// notifySystemd sends state to the systemd service manager if it is listening on NOTIFY_SOCKET.
+func notifySystemd(state string) error {
+	notifySocket := os.Getenv("NOTIFY_SOCKET")
+	if notifySocket == "" {
+		return nil
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: notifySocket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("net.DialUnix error: %w", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSystemdSockets(t *testing.T) {
+	const pid = 1234
+
+	tests := map[string]struct {
+		environ       map[string]string
+		wantNameToFDs map[string][]int
+		wantErr       bool
+	}{
+		"not activated": {
+			environ:       map[string]string{},
+			wantNameToFDs: map[string][]int{},
+		},
+		"other process": {
+			environ:       map[string]string{"LISTEN_PID": "1", "LISTEN_FDS": "1"},
+			wantNameToFDs: map[string][]int{},
+		},
+		"named": {
+			environ:       map[string]string{"LISTEN_PID": "1234", "LISTEN_FDS": "2", "LISTEN_FDNAMES": "http:https"},
+			wantNameToFDs: map[string][]int{"http": {3}, "https": {4}},
+		},
+		"unnamed": {
+			environ:       map[string]string{"LISTEN_PID": "1234", "LISTEN_FDS": "2"},
+			wantNameToFDs: map[string][]int{"unknown": {3, 4}},
+		},
+		"invalid count": {
+			environ: map[string]string{"LISTEN_PID": "1234", "LISTEN_FDS": "two"},
+			wantErr: true,
+		},
+		"names do not match count": {
+			environ: map[string]string{"LISTEN_PID": "1234", "LISTEN_FDS": "2", "LISTEN_FDNAMES": "http"},
+			wantErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			getenv := func(key string) string {
+				return test.environ[key]
+			}
+
+			nameToFDs, err := parseSystemdSockets(getenv, pid)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("parseSystemdSockets error = %v, wantErr %v", err, test.wantErr)
+			}
+
+			if !test.wantErr && !reflect.DeepEqual(nameToFDs, test.wantNameToFDs) {
+				t.Fatalf("parseSystemdSockets = %v, want %v", nameToFDs, test.wantNameToFDs)
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aaronriekenberg/go-api/config"
+)
+
+// upgradeEnvironmentVariable passes an upgradeEnvironment to the new process.
+// It does not start with config.EnvironmentPrefix, which is reserved for configuration overrides.
+const upgradeEnvironmentVariable = "GO_API_UPGRADE"
+
+// upgradeReadyTimeout bounds the wait for the new process to report ready.
+const upgradeReadyTimeout = 30 * time.Second
+
+// upgradeEnvironment describes the file descriptors passed to the new process on upgrade.
+// Listeners[i] is the listenerKey of file descriptor 3+i, ReadyFD is the pipe to report ready on.
+type upgradeEnvironment struct {
+	Listeners []string
+	ReadyFD   int
+}
+
+func listenerKey(
+	network string,
+	address string,
+) string {
+	return network + " " + address
+}
+
+// fileListener is a listener whose socket can be passed to the new process.
+type fileListener interface {
+	net.Listener
+	File() (*os.File, error)
+}
+
+// keyedListener is a listener created by Listen.
+type keyedListener struct {
+	key      string
+	listener fileListener
+}
+
+var (
+	listenersMutex sync.Mutex
+	// inheritedListeners are the sockets passed by the upgrading process not yet used by Listen.
+	inheritedListeners = make(map[string]*os.File)
+	upgradeReadyFile   *os.File
+	listeners          []keyedListener
+	upgraded           bool
+)
+
+// parseUpgradeEnvironment returns the upgradeEnvironment passed by the upgrading process, nil if there is none.
+func parseUpgradeEnvironment(getenv func(string) string) (*upgradeEnvironment, error) {
+	value := getenv(upgradeEnvironmentVariable)
+	if value == "" {
+		return nil, nil
+	}
+
+	var upgradeEnvironment upgradeEnvironment
+	if err := json.Unmarshal([]byte(value), &upgradeEnvironment); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", upgradeEnvironmentVariable, err)
+	}
+
+	return &upgradeEnvironment, nil
+}
+
+// InheritListeners takes the sockets passed by systemd socket activation or by the upgrading process.
+// It removes their variables from the environment, so it is called
+// before the environment is copied for commands.
+func InheritListeners() error {
+	if _, err := systemdSockets(); err != nil {
+		return err
+	}
+
+	upgradeEnvironment, err := parseUpgradeEnvironment(os.Getenv)
+	os.Unsetenv(upgradeEnvironmentVariable)
+	if err != nil || upgradeEnvironment == nil {
+		return err
+	}
+
+	listenersMutex.Lock()
+	defer listenersMutex.Unlock()
+
+	for i, key := range upgradeEnvironment.Listeners {
+		fd := systemdListenFDsStart + i
+		syscall.CloseOnExec(fd)
+		inheritedListeners[key] = os.NewFile(uintptr(fd), key)
+	}
+
+	syscall.CloseOnExec(upgradeEnvironment.ReadyFD)
+	upgradeReadyFile = os.NewFile(uintptr(upgradeEnvironment.ReadyFD), "upgrade-ready")
+
+	slog.Info("inherited listeners from upgrading process",
+		"listeners", upgradeEnvironment.Listeners,
+	)
+
+	return nil
+}
+
+// Listen returns a listener on network and address, using the socket passed by the upgrading process
+// if there is one.  Network config.NetworkSystemd is the socket systemd passed with FileDescriptorName address.
+// The listeners are passed on to the new process on upgrade.
+func Listen(
+	network string,
+	address string,
+) (net.Listener, error) {
+	listenersMutex.Lock()
+	defer listenersMutex.Unlock()
+
+	key := listenerKey(network, address)
+
+	var listener net.Listener
+	var err error
+
+	if file, ok := inheritedListeners[key]; ok {
+		delete(inheritedListeners, key)
+
+		listener, err = net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("inherited listener %q net.FileListener error: %w", key, err)
+		}
+	} else {
+		switch network {
+		case config.NetworkSystemd:
+			listener, err = systemdListener(address)
+			if err != nil {
+				return nil, fmt.Errorf("systemdListener error: %w", err)
+			}
+
+		default:
+			if network == "unix" {
+				os.Remove(address)
+			}
+
+			listener, err = net.Listen(network, address)
+			if err != nil {
+				return nil, fmt.Errorf("net.Listen error: %w", err)
+			}
+		}
+	}
+
+	if fileListener, ok := listener.(fileListener); ok {
+		listeners = append(listeners, keyedListener{
+			key:      key,
+			listener: fileListener,
+		})
+	}
+
+	return listener, nil
+}
+
+// notifyUpgradeReady tells the upgrading process that this one is serving,
+// and closes the inherited sockets no listener uses.
+func notifyUpgradeReady() error {
+	listenersMutex.Lock()
+	defer listenersMutex.Unlock()
+
+	for key, file := range inheritedListeners {
+		slog.Info("closing unused inherited listener",
+			"key", key,
+		)
+		file.Close()
+		delete(inheritedListeners, key)
+	}
+
+	if upgradeReadyFile == nil {
+		return nil
+	}
+
+	_, err := upgradeReadyFile.Write([]byte{0})
+	upgradeReadyFile.Close()
+	upgradeReadyFile = nil
+
+	return err
+}
+
+// Upgrade starts the executable again with the current arguments, passing it every listener,
+// and waits for it to report ready.  On success the caller shuts this process down,
+// on failure the new process is killed and this one keeps serving.
+// Only one upgrade succeeds.
+func Upgrade() (pid int, err error) {
+	listenersMutex.Lock()
+	defer listenersMutex.Unlock()
+
+	if upgraded {
+		return 0, errors.New("already upgraded")
+	}
+
+	slog.Info("begin server.Upgrade")
+
+	executable, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("os.Executable error: %w", err)
+	}
+
+	var upgradeEnvironment upgradeEnvironment
+	var listenerFiles []*os.File
+	defer func() {
+		for _, file := range listenerFiles {
+			if err := setNonblock(file); err != nil {
+				slog.Warn("setNonblock error",
+					"file", file.Name(),
+					"error", err,
+				)
+			}
+			file.Close()
+		}
+	}()
+
+	for _, keyedListener := range listeners {
+		file, err := keyedListener.listener.File()
+		if err != nil {
+			return 0, fmt.Errorf("listener %q File error: %w", keyedListener.key, err)
+		}
+		listenerFiles = append(listenerFiles, file)
+		upgradeEnvironment.Listeners = append(upgradeEnvironment.Listeners, keyedListener.key)
+	}
+
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return 0, fmt.Errorf("os.Pipe error: %w", err)
+	}
+	defer readyReader.Close()
+	defer readyWriter.Close()
+	upgradeEnvironment.ReadyFD = systemdListenFDsStart + len(upgradeEnvironment.Listeners)
+
+	upgradeEnvironmentJSON, err := json.Marshal(&upgradeEnvironment)
+	if err != nil {
+		return 0, fmt.Errorf("json.Marshal error: %w", err)
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), upgradeEnvironmentVariable+"="+string(upgradeEnvironmentJSON))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append(slices.Clone(listenerFiles), readyWriter)
+
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("cmd.Start error: %w", err)
+	}
+
+	// the new process has its own copy, so its exit closes the pipe
+	readyWriter.Close()
+
+	if err := waitForUpgradeReady(readyReader); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return 0, fmt.Errorf("new process %d did not become ready: %w", cmd.Process.Pid, err)
+	}
+
+	go cmd.Wait()
+
+	// the new process serves on the unix sockets after this one closes them
+	for _, keyedListener := range listeners {
+		if unixListener, ok := keyedListener.listener.(*net.UnixListener); ok {
+			unixListener.SetUnlinkOnClose(false)
+		}
+	}
+
+	upgraded = true
+
+	// with NotifyAccess=main systemd keeps supervising the new process after this one exits
+	if err := notifySystemd(fmt.Sprintf("MAINPID=%d", cmd.Process.Pid)); err != nil {
+		slog.Warn("notifySystemd error",
+			"error", err,
+		)
+	}
+
+	slog.Info("end server.Upgrade",
+		"pid", cmd.Process.Pid,
+	)
+
+	return cmd.Process.Pid, nil
+}
+
+// setNonblock puts file back into non-blocking mode after os/exec made it blocking.
+// The mode is shared with the listener file is a duplicate of,
+// whose Accept could not be interrupted by Close in blocking mode.
+func setNonblock(file *os.File) error {
+	rawConn, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var setNonblockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		setNonblockErr = syscall.SetNonblock(int(fd), true)
+	})
+	return cmp.Or(err, setNonblockErr)
+}
+
+func waitForUpgradeReady(readyReader *os.File) error {
+	if err := readyReader.SetReadDeadline(time.Now().Add(upgradeReadyTimeout)); err != nil {
+		return fmt.Errorf("SetReadDeadline error: %w", err)
+	}
+
+	_, err := readyReader.Read(make([]byte, 1))
+	if errors.Is(err, io.EOF) {
+		return errors.New("exited")
+	}
+	return err
+}
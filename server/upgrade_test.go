@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseUpgradeEnvironment(t *testing.T) {
+	tests := map[string]struct {
+		value                  string
+		wantUpgradeEnvironment *upgradeEnvironment
+		wantErr                bool
+	}{
+		"not upgrading": {
+			value: "",
+		},
+		"listeners": {
+			value: `{"Listeners":["tcp :8080","unix ./unix/socket"],"ReadyFD":5}`,
+			wantUpgradeEnvironment: &upgradeEnvironment{
+				Listeners: []string{"tcp :8080", "unix ./unix/socket"},
+				ReadyFD:   5,
+			},
+		},
+		"invalid": {
+			value:   `{"Listeners":`,
+			wantErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			getenv := func(key string) string {
+				if key == upgradeEnvironmentVariable {
+					return test.value
+				}
+				return ""
+			}
+
+			upgradeEnvironment, err := parseUpgradeEnvironment(getenv)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("parseUpgradeEnvironment error = %v, wantErr %v", err, test.wantErr)
+			}
+
+			if !reflect.DeepEqual(upgradeEnvironment, test.wantUpgradeEnvironment) {
+				t.Fatalf("parseUpgradeEnvironment = %+v, want %+v", upgradeEnvironment, test.wantUpgradeEnvironment)
+			}
+		})
+	}
+}
+
+// upgradeHelperProcessVariable makes TestUpgradeHelperProcess serve as the new process of an upgrade.
+const upgradeHelperProcessVariable = "GO_API_UPGRADE_HELPER_PROCESS"
+
+// upgradeTestAddress is the listen address of TestUpgrade, the new process finds the socket by it.
+const upgradeTestAddress = "127.0.0.1:0"
+
+func writePID(w http.ResponseWriter, r *http.Request) {
+	io.WriteString(w, strconv.Itoa(os.Getpid()))
+}
+
+// TestUpgradeHelperProcess is the new process started by Upgrade in TestUpgrade.
+// It serves its pid on the inherited listener until it is killed.
+func TestUpgradeHelperProcess(t *testing.T) {
+	if os.Getenv(upgradeHelperProcessVariable) == "" {
+		t.Skip("started by TestUpgrade")
+	}
+
+	if err := InheritListeners(); err != nil {
+		t.Fatalf("InheritListeners error: %v", err)
+	}
+
+	listener, err := Listen("tcp", upgradeTestAddress)
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+
+	go http.Serve(listener, http.HandlerFunc(writePID))
+
+	if err := notifyUpgradeReady(); err != nil {
+		t.Fatalf("notifyUpgradeReady error: %v", err)
+	}
+
+	time.Sleep(time.Minute)
+}
+
+func getPID(
+	client *http.Client,
+	url string,
+) (int, error) {
+	response, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(string(body))
+}
+
+func TestUpgrade(t *testing.T) {
+	t.Cleanup(func() {
+		listenersMutex.Lock()
+		defer listenersMutex.Unlock()
+
+		listeners = nil
+		upgraded = false
+	})
+
+	listener, err := Listen("tcp", upgradeTestAddress)
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+
+	server := &Server{
+		listenerServers: []*listenerServer{{
+			listener: &listenerWrapper{
+				Listener: listener,
+				closed:   make(chan struct{}),
+			},
+			httpServer: &http.Server{
+				Handler: http.HandlerFunc(writePID),
+			},
+		}},
+	}
+
+	serveErrorChannel := make(chan error, 1)
+	go func() {
+		serveErrorChannel <- server.Serve()
+	}()
+
+	// every request is on a new connection, so one refused after the upgrade is an error
+	client := &http.Client{
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+		},
+		Timeout: 5 * time.Second,
+	}
+	url := "http://" + listener.Addr().String()
+
+	var (
+		mutex     sync.Mutex
+		pidToGets = make(map[int]int)
+		getErrs   []error
+	)
+	gets := func(pid int) int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return pidToGets[pid]
+	}
+
+	stopClient := make(chan struct{})
+	var clientWaitGroup sync.WaitGroup
+	clientWaitGroup.Go(func() {
+		for {
+			select {
+			case <-stopClient:
+				return
+			default:
+			}
+
+			pid, err := getPID(client, url)
+
+			mutex.Lock()
+			if err != nil {
+				getErrs = append(getErrs, err)
+			} else {
+				pidToGets[pid]++
+			}
+			mutex.Unlock()
+		}
+	})
+	defer func() {
+		close(stopClient)
+		clientWaitGroup.Wait()
+	}()
+
+	waitFor := func(description string, condition func() bool) {
+		t.Helper()
+
+		for deadline := time.Now().Add(10 * time.Second); !condition(); {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", description)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	waitFor("a response from this process", func() bool {
+		return gets(os.Getpid()) > 0
+	})
+
+	// the new process runs only TestUpgradeHelperProcess
+	t.Setenv(upgradeHelperProcessVariable, "true")
+	args := os.Args
+	os.Args = []string{os.Args[0], "-test.run=^TestUpgradeHelperProcess$"}
+	defer func() {
+		os.Args = args
+	}()
+
+	pid, err := Upgrade()
+	if err != nil {
+		t.Fatalf("Upgrade error: %v", err)
+	}
+	defer func() {
+		if err := syscall.Kill(pid, syscall.SIGKILL); err != nil {
+			t.Errorf("syscall.Kill error: %v", err)
+		}
+	}()
+
+	server.StopAccepting()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown error: %v", err)
+	}
+	if err := <-serveErrorChannel; err != nil {
+		t.Fatalf("Serve error: %v", err)
+	}
+
+	getsAfterShutdown := gets(pid)
+	waitFor("responses from the new process after shutdown", func() bool {
+		return gets(pid) > getsAfterShutdown+10
+	})
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(getErrs) != 0 {
+		t.Fatalf("%d requests failed during the upgrade, first error: %v", len(getErrs), getErrs[0])
+	}
+}
@@ -44,6 +44,26 @@ func RespondWithJSONDTO(
 
 }
 
+func RespondWithJSONDTOAndStatusCode(
+	dto any,
+	statusCode int,
+	w http.ResponseWriter,
+	opts ...json.Options,
+) {
+	jsonBytes, err := json.Marshal(dto, opts...)
+	if err != nil {
+		slog.Warn("utils.RespondWithJSONDTOAndStatusCode: json.Marshal error",
+			"error", err,
+		)
+		HTTPErrorStatusCode(w, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(ContentTypeHeaderKey, ContentTypeApplicationJSON)
+	w.WriteHeader(statusCode)
+	w.Write(jsonBytes)
+}
+
 func JSONBytesHandlerFunc(
 	jsonBytes []byte,
 ) http.HandlerFunc {